@@ -0,0 +1,234 @@
+package infinity
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/rest"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/models"
+	"github.com/grafana/grafana-aws-sdk/pkg/awsds"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"github.com/grafana/grafana-plugin-sdk-go/experimental/errorsource"
+)
+
+const (
+	awsDefaultRegion      = "us-east-2"
+	awsDefaultService     = "monitoring"
+	awsRoleSessionName    = "grafana-infinity-datasource"
+	awsRoleARNEnv         = "AWS_ROLE_ARN"
+	awsWebIdentityFileEnv = "AWS_WEB_IDENTITY_TOKEN_FILE"
+	// awsCallerIdentityTimeout bounds the STS call of the health check
+	awsCallerIdentityTimeout = 5 * time.Second
+	// awsCredentialsExpiryWindow is how long before expiry the web identity credentials are renewed
+	awsCredentialsExpiryWindow = time.Minute
+)
+
+// AWSCredentialsProvider resolves the AWS credentials of the datasource. The sessions are created by the grafana-aws-sdk session cache,
+// which also enforces the auth providers and the assume role setting allowed by the Grafana admin.
+// The requests of the AWS SDK to the STS and instance metadata endpoints don't go through the egress policy of the datasource.
+type AWSCredentialsProvider struct {
+	settings     models.AWSSettings
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	region       string
+	sessions     *awsds.SessionCache
+	mu           sync.Mutex
+	webIdentity  *session.Session
+	// webIdentityExpires is when the web identity session is created again, the same as the sessions of the session cache
+	webIdentityExpires time.Time
+}
+
+func NewAWSCredentialsProvider(settings models.InfinitySettings) *AWSCredentialsProvider {
+	return &AWSCredentialsProvider{
+		settings:     settings.AWSSettings,
+		accessKey:    settings.AWSAccessKey,
+		secretKey:    settings.AWSSecretKey,
+		sessionToken: settings.AWSSessionToken,
+		region:       getAWSRegion(settings.AWSSettings),
+		sessions:     awsds.NewSessionCache(),
+	}
+}
+
+// GetSession returns the session holding the credentials used to sign the requests
+func (p *AWSCredentialsProvider) GetSession(ctx context.Context) (*session.Session, error) {
+	authSettings := awsds.ReadAuthSettings(ctx)
+	switch p.settings.GetAuthType() {
+	case models.AWSAuthTypeWebIdentity:
+		return p.getWebIdentitySession(authSettings)
+	case models.AWSAuthTypeDefault:
+		return p.sessions.GetSession(awsds.SessionConfig{Settings: p.getDatasourceSettings(awsds.AuthTypeDefault), AuthSettings: authSettings})
+	default:
+		return p.sessions.GetSession(awsds.SessionConfig{Settings: p.getDatasourceSettings(awsds.AuthTypeKeys), AuthSettings: authSettings})
+	}
+}
+
+func (p *AWSCredentialsProvider) getDatasourceSettings(authType awsds.AuthType) awsds.AWSDatasourceSettings {
+	settings := awsds.AWSDatasourceSettings{
+		AuthType:      authType,
+		Region:        p.region,
+		AssumeRoleARN: strings.TrimSpace(p.settings.AssumeRoleARN),
+		ExternalID:    p.settings.ExternalID,
+	}
+	if authType == awsds.AuthTypeKeys {
+		settings.AccessKey, settings.SecretKey, settings.SessionToken = p.accessKey, p.secretKey, p.sessionToken
+	}
+	return settings
+}
+
+// getWebIdentitySession assumes the web identity role with the token file and then the assume role, when configured.
+// The token file is read again every time the credentials are renewed, so rotated tokens are picked up.
+// The STS client is created from the default session, so the default auth provider has to be allowed
+func (p *AWSCredentialsProvider) getWebIdentitySession(authSettings *awsds.AuthSettings) (*session.Session, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.webIdentity != nil && time.Now().Before(p.webIdentityExpires) {
+		return p.webIdentity, nil
+	}
+	roleARN := firstNonEmpty(p.settings.WebIdentityRoleARN, os.Getenv(awsRoleARNEnv))
+	tokenFile := firstNonEmpty(p.settings.WebIdentityTokenFile, os.Getenv(awsWebIdentityFileEnv))
+	if roleARN == "" || tokenFile == "" {
+		return nil, fmt.Errorf("web identity role ARN and token file are not configured and the %s and %s environment variables are not set", awsRoleARNEnv, awsWebIdentityFileEnv)
+	}
+	if p.settings.AssumeRoleARN != "" && !authSettings.AssumeRoleEnabled {
+		return nil, fmt.Errorf("attempting to use assume role (ARN) which is disabled in grafana.ini")
+	}
+	settings := p.getDatasourceSettings(awsds.AuthTypeDefault)
+	settings.AssumeRoleARN, settings.ExternalID = "", ""
+	base, err := p.sessions.GetSession(awsds.SessionConfig{Settings: settings, AuthSettings: authSettings})
+	if err != nil {
+		return nil, err
+	}
+	creds := credentials.NewCredentials(stscreds.NewWebIdentityRoleProviderWithOptions(sts.New(base), roleARN, awsRoleSessionName, stscreds.FetchTokenPath(tokenFile), func(wp *stscreds.WebIdentityRoleProvider) {
+		wp.ExpiryWindow = awsCredentialsExpiryWindow
+	}))
+	if p.settings.AssumeRoleARN != "" {
+		creds = stscreds.NewCredentials(base.Copy(&aws.Config{Credentials: creds}), strings.TrimSpace(p.settings.AssumeRoleARN), func(arp *stscreds.AssumeRoleProvider) {
+			arp.RoleSessionName = awsRoleSessionName
+			arp.ExpiryWindow = awsCredentialsExpiryWindow
+			if p.settings.ExternalID != "" {
+				arp.ExternalID = aws.String(p.settings.ExternalID)
+			}
+		})
+	}
+	duration := stscreds.DefaultDuration
+	if authSettings.SessionDuration != nil {
+		duration = *authSettings.SessionDuration
+	}
+	p.webIdentity, p.webIdentityExpires = base.Copy(&aws.Config{Credentials: creds}), time.Now().Add(duration)
+	return p.webIdentity, nil
+}
+
+// GetCallerIdentity returns the ARN of the identity the requests are signed with. When STS can't be reached,
+// only the provider of the credentials is returned
+func (p *AWSCredentialsProvider) GetCallerIdentity(ctx context.Context) (string, error) {
+	sess, err := p.GetSession(ctx)
+	if err != nil {
+		return "", err
+	}
+	creds, err := sess.Config.Credentials.GetWithContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, awsCallerIdentityTimeout)
+	defer cancel()
+	out, err := sts.New(sess).GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		backend.Logger.FromContext(ctx).Warn("failed to get the aws caller identity", "error", err.Error())
+		return fmt.Sprintf("unknown. credentials provided by %s", creds.ProviderName), nil
+	}
+	return aws.StringValue(out.Arn), nil
+}
+
+// AWSSigV4Transport signs the requests with the AWS signature version 4. Only the host and the body of the request are signed,
+// the other headers are copied to the signed request afterwards so that they can still be changed by the base transports
+type AWSSigV4Transport struct {
+	Base                http.RoundTripper
+	CredentialsProvider *AWSCredentialsProvider
+	Region              string
+	Service             string
+}
+
+func (t *AWSSigV4Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	sess, err := t.CredentialsProvider.GetSession(req.Context())
+	if err != nil {
+		backend.Logger.FromContext(req.Context()).Error("failed to get the aws credentials", "error", err.Error())
+		return nil, errorsource.DownstreamError(fmt.Errorf("%w. %w", ErrAWSCredentials, err), false)
+	}
+	body := []byte{}
+	if req.Body != nil {
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+	signedReq, err := http.NewRequestWithContext(req.Context(), req.Method, req.URL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(signedReq.URL.RawPath, "%2C") {
+		signedReq.URL.RawPath = rest.EscapePath(signedReq.URL.RawPath, false)
+	}
+	if _, err := v4.NewSigner(sess.Config.Credentials).Sign(signedReq, bytes.NewReader(body), t.Service, t.Region, time.Now().UTC()); err != nil {
+		backend.Logger.FromContext(req.Context()).Error("failed to sign the request", "error", err.Error())
+		return nil, errorsource.DownstreamError(fmt.Errorf("%w. %w", ErrAWSCredentials, err), false)
+	}
+	for k, vv := range req.Header {
+		if _, ok := signedReq.Header[k]; !ok {
+			signedReq.Header[k] = vv
+		}
+	}
+	signedReq.Header.Add(headerKeyAccept, contentTypeJSON)
+	return t.Base.RoundTrip(signedReq)
+}
+
+// ApplyAWSAuth signs the requests with the AWS credentials. The signed requests go through the retry, rate limit and egress transports of the client
+func ApplyAWSAuth(ctx context.Context, httpClient *http.Client, settings models.InfinitySettings, credentialsProvider *AWSCredentialsProvider) *http.Client {
+	_, span := tracing.DefaultTracer().Start(ctx, "ApplyAWSAuth")
+	defer span.End()
+	if IsAwsAuthConfigured(settings) && credentialsProvider != nil {
+		service := settings.AWSSettings.Service
+		if service == "" {
+			service = awsDefaultService
+		}
+		httpClient.Transport = &AWSSigV4Transport{
+			Base:                httpClient.Transport,
+			CredentialsProvider: credentialsProvider,
+			Region:              getAWSRegion(settings.AWSSettings),
+			Service:             service,
+		}
+	}
+	return httpClient
+}
+
+func IsAwsAuthConfigured(settings models.InfinitySettings) bool {
+	return settings.AuthenticationMethod == models.AuthenticationMethodAWS
+}
+
+// getAWSRegion returns the configured region, the region of the environment or us-east-2
+func getAWSRegion(settings models.AWSSettings) string {
+	return firstNonEmpty(settings.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), awsDefaultRegion)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,156 @@
+package infinity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/experimental/errorsource"
+	"github.com/grafana/infinity-libs/lib/go/transformations"
+)
+
+// azureBlobFetchConcurrency is the number of blobs downloaded in parallel for a single prefix/glob query
+const azureBlobFetchConcurrency = 5
+
+// IsAzureBlobMultiQuery returns true when the query reads more than one blob using prefix or glob matching
+func IsAzureBlobMultiQuery(query models.Query) bool {
+	return query.Source == "azure-blob" && (query.AzBlobMatchMode == models.AzureBlobMatchModePrefix || query.AzBlobMatchMode == models.AzureBlobMatchModeGlob)
+}
+
+// ListAzureBlobs returns the sorted names of the blobs matching the prefix/glob of the query.
+// At most maxBlobs names are returned and truncated is set when more blobs matched.
+func (client *Client) ListAzureBlobs(ctx context.Context, query models.Query, maxBlobs int) (names []string, truncated bool, err error) {
+	ctx, span := tracing.DefaultTracer().Start(ctx, "client.ListAzureBlobs")
+	defer span.End()
+	containerName := strings.TrimSpace(query.AzBlobContainerName)
+	pattern := strings.TrimSpace(query.AzBlobName)
+	if containerName == "" {
+		return nil, false, errorsource.DownstreamError(errors.New("invalid/empty container name"), false)
+	}
+	if client.AzureBlobClient == nil {
+		return nil, false, errorsource.PluginError(errors.New("invalid azure blob client"), false)
+	}
+	prefix := pattern
+	if query.AzBlobMatchMode == models.AzureBlobMatchModeGlob {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, false, errorsource.DownstreamError(fmt.Errorf("invalid blob glob pattern %s. %w", pattern, err), false)
+		}
+		if idx := strings.IndexAny(pattern, `*?[\`); idx >= 0 {
+			prefix = pattern[:idx]
+		}
+	}
+	options := &azblob.ListBlobsFlatOptions{}
+	if prefix != "" {
+		options.Prefix = &prefix
+	}
+	pager := client.AzureBlobClient.NewListBlobsFlatPager(containerName, options)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			span.RecordError(err)
+			return nil, false, errorsource.DownstreamError(fmt.Errorf("error listing blobs in container %s. %w", containerName, err), false)
+		}
+		for _, item := range page.Segment.BlobItems {
+			if item == nil || item.Name == nil {
+				continue
+			}
+			if query.AzBlobMatchMode == models.AzureBlobMatchModeGlob {
+				if ok, _ := path.Match(pattern, *item.Name); !ok {
+					continue
+				}
+			}
+			// blobs are listed in lexical order, so the first maxBlobs matches are deterministic
+			if len(names) >= maxBlobs {
+				return names, true, nil
+			}
+			names = append(names, *item.Name)
+		}
+	}
+	return names, false, nil
+}
+
+// GetAzureBlobMergedResults lists the blobs matching the query, fetches them concurrently and merges them into a single frame
+func GetAzureBlobMergedResults(ctx context.Context, query models.Query, infClient Client, requestHeaders map[string]string) (*data.Frame, error) {
+	ctx, span := tracing.DefaultTracer().Start(ctx, "GetAzureBlobMergedResults")
+	logger := backend.Logger.FromContext(ctx)
+	defer span.End()
+	if query.Parser != models.InfinityParserBackend {
+		return GetDummyFrame(query), errorsource.DownstreamError(errors.New("blob prefix/glob queries require the backend parser"), false)
+	}
+	maxBlobs := query.AzBlobMaxBlobs
+	if maxBlobs <= 0 {
+		maxBlobs = models.AzureBlobMaxBlobsDefault
+	}
+	blobNames, truncated, err := infClient.ListAzureBlobs(ctx, query, maxBlobs)
+	if err != nil {
+		span.RecordError(err)
+		return GetDummyFrame(query), err
+	}
+	if len(blobNames) == 0 {
+		return GetDummyFrame(query), errorsource.DownstreamError(fmt.Errorf("no blobs found matching %s in container %s", query.AzBlobName, query.AzBlobContainerName), false)
+	}
+	logger.Debug("fetching azure blobs", "container", query.AzBlobContainerName, "blob_count", len(blobNames), "truncated", truncated)
+	frames := make([]*data.Frame, len(blobNames))
+	blobErrs := make([]error, len(blobNames))
+	wg := sync.WaitGroup{}
+	semaphore := make(chan struct{}, azureBlobFetchConcurrency)
+	for i, blobName := range blobNames {
+		wg.Add(1)
+		go func(i int, blobName string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			currentQuery := query
+			currentQuery.AzBlobName = blobName
+			currentQuery.AzBlobMatchMode = models.AzureBlobMatchModeExact
+			frame, _, err := GetFrameForURLSourcesWithPostProcessing(ctx, currentQuery, infClient, requestHeaders, false)
+			if err != nil {
+				frames[i], blobErrs[i] = frame, err
+				return
+			}
+			frames[i] = ApplyAzureBlobNameColumn(frame, query.AzBlobNameColumn, blobName)
+		}(i, blobName)
+	}
+	wg.Wait()
+	frames, notices, err := getPartialResults(query.PartialResults, frames, blobErrs, func(i int) string { return fmt.Sprintf("error reading blob %s", blobNames[i]) })
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	mergedFrame, err := transformations.Merge(frames, transformations.MergeFramesOptions{})
+	if err != nil {
+		return nil, err
+	}
+	frame, err := PostProcessFrame(ctx, mergedFrame, query)
+	if frame != nil {
+		frame.AppendNotices(notices...)
+	}
+	if frame != nil && truncated {
+		frame.AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("more than %d blobs matched %s. only the first %d blobs are included in the results", maxBlobs, query.AzBlobName, maxBlobs),
+		})
+	}
+	return frame, err
+}
+
+// ApplyAzureBlobNameColumn adds a column holding the source blob name to every row of the frame
+func ApplyAzureBlobNameColumn(frame *data.Frame, columnName string, blobName string) *data.Frame {
+	if frame == nil || strings.TrimSpace(columnName) == "" {
+		return frame
+	}
+	values := make([]*string, frame.Rows())
+	for i := range values {
+		values[i] = &blobName
+	}
+	frame.Fields = append(frame.Fields, data.NewField(columnName, nil, values))
+	return frame
+}
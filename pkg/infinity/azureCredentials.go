@@ -0,0 +1,75 @@
+package infinity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/models"
+)
+
+// IsAzureCredentialConfigured returns true when the datasource uses azure workload identity or a service principal
+func IsAzureCredentialConfigured(settings models.InfinitySettings) bool {
+	return settings.AuthenticationMethod == models.AuthenticationMethodAzureWorkloadIdentity || settings.AuthenticationMethod == models.AuthenticationMethodAzureServicePrincipal
+}
+
+// GetAzureTokenCredential returns the azidentity credential of the workload identity and service principal authentication methods.
+// Settings left empty for workload identity fall back to the AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_FEDERATED_TOKEN_FILE environment variables.
+func GetAzureTokenCredential(settings models.InfinitySettings) (azcore.TokenCredential, error) {
+	tenantID := strings.TrimSpace(settings.AzureCredentials.TenantID)
+	clientID := strings.TrimSpace(settings.AzureCredentials.ClientID)
+	// token requests to the authority go through the egress policy of the datasource
+	clientOptions := azcore.ClientOptions{Transport: getTokenHTTPClient(settings)}
+	switch settings.AuthenticationMethod {
+	case models.AuthenticationMethodAzureWorkloadIdentity:
+		tokenFilePath := strings.TrimSpace(os.Getenv("AZURE_FEDERATED_TOKEN_FILE"))
+		if tokenFilePath == "" {
+			return nil, errors.New("error creating azure workload identity credential. AZURE_FEDERATED_TOKEN_FILE is not set. check the workload identity configuration of the pod")
+		}
+		cred, err := azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+			TenantID:      tenantID,
+			ClientID:      clientID,
+			TokenFilePath: tokenFilePath,
+			ClientOptions: clientOptions,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error creating azure workload identity credential. %w", err)
+		}
+		return cred, nil
+	case models.AuthenticationMethodAzureServicePrincipal:
+		if strings.TrimSpace(settings.AzureClientCertificate) != "" {
+			certs, key, err := azidentity.ParseCertificates([]byte(settings.AzureClientCertificate), []byte(settings.AzureClientCertPassword))
+			if err != nil {
+				return nil, fmt.Errorf("invalid azure client certificate. %w", err)
+			}
+			cred, err := azidentity.NewClientCertificateCredential(tenantID, clientID, certs, key, &azidentity.ClientCertificateCredentialOptions{ClientOptions: clientOptions})
+			if err != nil {
+				return nil, fmt.Errorf("error creating azure client certificate credential. %w", err)
+			}
+			return cred, nil
+		}
+		cred, err := azidentity.NewClientSecretCredential(tenantID, clientID, settings.AzureClientSecret, &azidentity.ClientSecretCredentialOptions{ClientOptions: clientOptions})
+		if err != nil {
+			return nil, fmt.Errorf("error creating azure client secret credential. %w", err)
+		}
+		return cred, nil
+	}
+	return nil, errors.New("azure credentials are not configured")
+}
+
+// NewAzureCredentialTokenProvider returns a token provider that fetches the tokens for the given resource/scope from the azidentity credential
+func NewAzureCredentialTokenProvider(cred azcore.TokenCredential, resource string) *AzureTokenProvider {
+	_, scope := azureResourceAndScope(resource)
+	return NewAzureTokenProvider(func(ctx context.Context) (AzureToken, error) {
+		token, err := cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{scope}})
+		if err != nil {
+			return AzureToken{}, err
+		}
+		return AzureToken{AccessToken: token.Token, ExpiresOn: token.ExpiresOn}, nil
+	})
+}
@@ -0,0 +1,121 @@
+package infinity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/experimental/errorsource"
+)
+
+const (
+	// azureTokenRefreshWindow is how long before expiry a cached token is refreshed
+	azureTokenRefreshWindow = 5 * time.Minute
+	// azureTokenDefaultLifetime applies to the tokens issued without an expiry. The tokens are reused for 5 minutes before they are refreshed
+	azureTokenDefaultLifetime = azureTokenRefreshWindow + 5*time.Minute
+)
+
+// AzureToken is an Azure access token along with its expiry time
+type AzureToken struct {
+	AccessToken string
+	ExpiresOn   time.Time
+}
+
+type azureTokenCall struct {
+	done  chan struct{}
+	token AzureToken
+	err   error
+}
+
+// AzureTokenProvider caches the Azure access token of a datasource instance.
+// Tokens are refreshed shortly before they expire and concurrent refreshes are coalesced into a single fetch.
+type AzureTokenProvider struct {
+	fetch    func(ctx context.Context) (AzureToken, error)
+	mu       sync.Mutex
+	token    AzureToken
+	inflight *azureTokenCall
+}
+
+func NewAzureTokenProvider(fetch func(ctx context.Context) (AzureToken, error)) *AzureTokenProvider {
+	return &AzureTokenProvider{fetch: fetch}
+}
+
+// GetToken returns the cached token or fetches a new one. Waiting for a token respects the context cancellation.
+func (p *AzureTokenProvider) GetToken(ctx context.Context) (string, error) {
+	for {
+		p.mu.Lock()
+		now := time.Now()
+		if p.token.AccessToken != "" && now.Add(azureTokenRefreshWindow).Before(p.token.ExpiresOn) {
+			token := p.token.AccessToken
+			p.mu.Unlock()
+			return token, nil
+		}
+		if call := p.inflight; call != nil {
+			p.mu.Unlock()
+			select {
+			case <-call.done:
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+			if call.err == nil {
+				return call.token.AccessToken, nil
+			}
+			// the caller which started the refresh went away. try again with the current context
+			if (errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded)) && ctx.Err() == nil {
+				continue
+			}
+			return "", call.err
+		}
+		call := &azureTokenCall{done: make(chan struct{})}
+		p.inflight = call
+		previous := p.token
+		p.mu.Unlock()
+
+		token, err := p.fetch(ctx)
+		if err != nil && previous.AccessToken != "" && time.Now().Before(previous.ExpiresOn) {
+			// refresh failed but the current token is still valid. keep using it until it expires
+			backend.Logger.FromContext(ctx).Warn("failed to refresh Azure token, using the cached token", "error", err.Error())
+			token, err = previous, nil
+		}
+		p.mu.Lock()
+		call.token, call.err = token, err
+		if err == nil {
+			p.token = token
+		}
+		p.inflight = nil
+		p.mu.Unlock()
+		close(call.done)
+		if err != nil {
+			return "", err
+		}
+		return token.AccessToken, nil
+	}
+}
+
+// Dispose releases the cached token
+func (p *AzureTokenProvider) Dispose() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.token = AzureToken{}
+}
+
+// AzureTokenTransport sets the bearer token from the token provider on every outgoing request
+type AzureTokenTransport struct {
+	Base          http.RoundTripper
+	TokenProvider *AzureTokenProvider
+}
+
+func (t *AzureTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.TokenProvider.GetToken(req.Context())
+	if err != nil {
+		backend.Logger.FromContext(req.Context()).Error("failed to get Azure token", "error", err.Error())
+		return nil, errorsource.DownstreamError(fmt.Errorf("failed to get Azure token. %w", err), false)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set(headerKeyAuthorization, fmt.Sprintf("Bearer %s", token))
+	return t.Base.RoundTrip(req)
+}
@@ -0,0 +1,181 @@
+package infinity
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/models"
+)
+
+type CacheStatus string
+
+const (
+	CacheStatusHit         CacheStatus = "hit"
+	CacheStatusMiss        CacheStatus = "miss"
+	CacheStatusRevalidated CacheStatus = "revalidated"
+)
+
+type cacheStatsContextKey struct{}
+
+// CacheStats records whether the response of a request sharing the context was served from the cache
+type CacheStats struct {
+	status atomic.Value
+}
+
+func (s *CacheStats) Status() CacheStatus {
+	if s == nil {
+		return ""
+	}
+	status, _ := s.status.Load().(CacheStatus)
+	return status
+}
+
+// WithCacheStats returns a context which records the cache status of the request using it
+func WithCacheStats(ctx context.Context) (context.Context, *CacheStats) {
+	stats := &CacheStats{}
+	return context.WithValue(ctx, cacheStatsContextKey{}, stats), stats
+}
+
+func recordCacheStatus(ctx context.Context, status CacheStatus) {
+	if stats, ok := ctx.Value(cacheStatsContextKey{}).(*CacheStats); ok {
+		stats.status.Store(status)
+	}
+}
+
+type cacheEntry struct {
+	key          string
+	statusCode   int
+	header       http.Header
+	body         []byte
+	expires      time.Time
+	etag         string
+	lastModified string
+}
+
+func (e *cacheEntry) size() int64 {
+	return int64(len(e.body) + len(e.key))
+}
+
+// ResponseCache is a size bounded in-memory cache of the response bodies of a datasource instance.
+// Entries are kept after they expire so that they can be revalidated with the ETag and Last-Modified headers of the response.
+type ResponseCache struct {
+	mu      sync.Mutex
+	maxSize int64
+	size    int64
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+func NewResponseCache(settings models.CacheSettings) *ResponseCache {
+	return &ResponseCache{maxSize: settings.GetMaxSizeBytes(), entries: map[string]*list.Element{}, lru: list.New()}
+}
+
+func (c *ResponseCache) get(key string) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+	return element.Value.(*cacheEntry), true
+}
+
+func (c *ResponseCache) set(entry *cacheEntry) {
+	if entry.size() > c.maxSize {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[entry.key]; ok {
+		c.size -= element.Value.(*cacheEntry).size()
+		c.lru.Remove(element)
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	c.size += entry.size()
+	for c.size > c.maxSize {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.size -= oldest.Value.(*cacheEntry).size()
+	}
+}
+
+// Clear removes all the cached responses
+func (c *ResponseCache) Clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]*list.Element{}
+	c.lru.Init()
+	c.size = 0
+}
+
+// getCacheTTL returns how long the response of the query is cached. The ttl of the query takes precedence over the datasource ttl
+func getCacheTTL(settings models.InfinitySettings, query models.Query) time.Duration {
+	ttl := settings.Cache.TTLSeconds
+	if query.CacheTTLSeconds != nil {
+		ttl = *query.CacheTTLSeconds
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+// getCacheKey returns the key of the fully resolved request or an empty key when the request can't be cached.
+// The key covers the method, url, body and all the headers of the request, so that requests sent with different
+// credentials, such as the forwarded oauth identity of different users, never share a cached response.
+func getCacheKey(req *http.Request) string {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost:
+	default:
+		return ""
+	}
+	hash := sha256.New()
+	_, _ = io.WriteString(hash, req.Method+"\n"+req.URL.String()+"\n")
+	headerKeys := make([]string, 0, len(req.Header))
+	for key := range req.Header {
+		headerKeys = append(headerKeys, key)
+	}
+	slices.Sort(headerKeys)
+	for _, key := range headerKeys {
+		_, _ = io.WriteString(hash, key+": "+strings.Join(req.Header.Values(key), ", ")+"\n")
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return ""
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return ""
+		}
+		defer body.Close()
+		_, _ = io.WriteString(hash, "\n")
+		if _, err := io.Copy(hash, body); err != nil {
+			return ""
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// isCacheableResponse returns false for unsuccessful responses and responses the server asked not to store
+func isCacheableResponse(res *http.Response) bool {
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return false
+	}
+	for _, directive := range strings.Split(res.Header.Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-store") {
+			return false
+		}
+	}
+	return true
+}
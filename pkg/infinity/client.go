@@ -9,12 +9,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
 	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/models"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
@@ -22,14 +24,21 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
 	"github.com/grafana/grafana-plugin-sdk-go/experimental/errorsource"
 	"github.com/icholy/digest"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/net/html/charset"
 	"golang.org/x/oauth2"
 )
 
 type Client struct {
-	Settings        models.InfinitySettings
-	HttpClient      *http.Client
-	AzureBlobClient *azblob.Client
-	IsMock          bool
+	Settings             models.InfinitySettings
+	HttpClient           *http.Client
+	AzureBlobClient      *azblob.Client
+	AzureTokenProvider   *AzureTokenProvider
+	AWSCredentials       *AWSCredentialsProvider
+	Cache                *ResponseCache
+	Coalescer            *RequestCoalescer
+	OAuth2Authorizations *OAuth2Authorizations
+	IsMock               bool
 }
 
 func GetTLSConfigFromSettings(settings models.InfinitySettings) (*tls.Config, error) {
@@ -64,7 +73,7 @@ func getBaseHTTPClient(ctx context.Context, settings models.InfinitySettings) *h
 	if err != nil {
 		return nil
 	}
-	transport := &http.Transport{TLSClientConfig: tlsConfig}
+	transport := &http.Transport{TLSClientConfig: tlsConfig, DialContext: getDialContext(settings)}
 	switch settings.ProxyType {
 	case models.ProxyTypeNone:
 		logger.Debug("proxy type is set to none. Not using the proxy")
@@ -80,8 +89,11 @@ func getBaseHTTPClient(ctx context.Context, settings models.InfinitySettings) *h
 		transport.Proxy = http.ProxyFromEnvironment
 	}
 
+	var baseTransport http.RoundTripper = &EgressTransport{Base: transport, Policy: NewEgressPolicy(settings)}
+	baseTransport = &RateLimitTransport{Base: baseTransport, Limiter: NewRateLimiter(settings.RateLimits)}
+	baseTransport = &RetryTransport{Base: baseTransport, Settings: settings.Retry}
 	return &http.Client{
-		Transport: transport,
+		Transport: baseTransport,
 		Timeout:   time.Second * time.Duration(settings.TimeoutInSeconds),
 	}
 }
@@ -108,7 +120,32 @@ func NewClient(ctx context.Context, settings models.InfinitySettings) (client *C
 	httpClient = ApplyDigestAuth(ctx, httpClient, settings)
 	httpClient = ApplyOAuthClientCredentials(ctx, httpClient, settings)
 	httpClient = ApplyOAuthJWT(ctx, httpClient, settings)
-	httpClient = ApplyAWSAuth(ctx, httpClient, settings)
+	httpClient = ApplyOAuthAuthorizationCode(ctx, httpClient, settings)
+	var awsCredentialsProvider *AWSCredentialsProvider
+	if IsAwsAuthConfigured(settings) {
+		awsCredentialsProvider = NewAWSCredentialsProvider(settings)
+	}
+	httpClient = ApplyAWSAuth(ctx, httpClient, settings, awsCredentialsProvider)
+	httpClient = ApplyLoginTokenAuth(ctx, httpClient, settings)
+	var azureTokenProvider *AzureTokenProvider
+	var azureCredential azcore.TokenCredential
+	if IsAzureManagedIdentityConfigured(settings) {
+		tokenHttpClient := getTokenHTTPClient(settings)
+		azureTokenProvider = NewAzureTokenProvider(func(ctx context.Context) (AzureToken, error) {
+			return getToken(ctx, tokenHttpClient, settings.AzureManagedIdentity)
+		})
+	}
+	if IsAzureCredentialConfigured(settings) {
+		azureCredential, err = GetAzureTokenCredential(settings)
+		if err != nil {
+			span.RecordError(err)
+			logger.Error("invalid azure credentials", "datasource uid", settings.UID, "datasource name", settings.Name, "error", err.Error())
+			return client, err
+		}
+		azureTokenProvider = NewAzureCredentialTokenProvider(azureCredential, settings.AzureCredentials.Resource)
+	}
+	httpClient = ApplyAzureTokenAuth(ctx, httpClient, settings, azureTokenProvider)
+	httpClient = ApplyTokenExchange(ctx, httpClient, settings)
 
 	httpClient, err = ApplySecureSocksProxyConfiguration(ctx, httpClient, settings)
 	if err != nil {
@@ -117,8 +154,13 @@ func NewClient(ctx context.Context, settings models.InfinitySettings) (client *C
 	}
 
 	client = &Client{
-		Settings:   settings,
-		HttpClient: httpClient,
+		Settings:             settings,
+		HttpClient:           httpClient,
+		AzureTokenProvider:   azureTokenProvider,
+		AWSCredentials:       awsCredentialsProvider,
+		Cache:                NewResponseCache(settings.Cache),
+		Coalescer:            NewRequestCoalescer(),
+		OAuth2Authorizations: NewOAuth2Authorizations(),
 	}
 
 	if settings.AuthenticationMethod == models.AuthenticationMethodAzureBlob {
@@ -129,14 +171,7 @@ func NewClient(ctx context.Context, settings models.InfinitySettings) (client *C
 			logger.Error("invalid azure blob credentials", "datasource uid", settings.UID, "datasource name", settings.Name)
 			return client, errors.New("invalid azure blob credentials")
 		}
-		clientUrl := "https://%s.blob.core.windows.net/"
-		if settings.AzureBlobAccountUrl != "" {
-			clientUrl = settings.AzureBlobAccountUrl
-		}
-		if strings.Contains(clientUrl, "%s") {
-			clientUrl = fmt.Sprintf(clientUrl, settings.AzureBlobAccountName)
-		}
-		azClient, err := azblob.NewClientWithSharedKeyCredential(clientUrl, cred, nil)
+		azClient, err := azblob.NewClientWithSharedKeyCredential(getAzureBlobAccountURL(settings), cred, nil)
 		if err != nil {
 			span.RecordError(err)
 			span.SetStatus(500, err.Error())
@@ -151,24 +186,75 @@ func NewClient(ctx context.Context, settings models.InfinitySettings) (client *C
 		}
 		client.AzureBlobClient = azClient
 	}
+	if azureCredential != nil && (strings.TrimSpace(settings.AzureBlobAccountName) != "" || strings.TrimSpace(settings.AzureBlobAccountUrl) != "") {
+		// workload identity and service principals can also read the azure-blob source. the blob client requests its own storage scoped tokens
+		azClient, err := azblob.NewClient(getAzureBlobAccountURL(settings), azureCredential, nil)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(500, err.Error())
+			logger.Error("error creating azure blob client", "datasource uid", settings.UID, "datasource name", settings.Name)
+			return client, fmt.Errorf("error creating azure blob client. %s", err)
+		}
+		client.AzureBlobClient = azClient
+	}
 	if settings.IsMock {
 		client.IsMock = true
 	}
 	return client, err
 }
 
+// getAzureBlobAccountURL returns the blob service url of the storage account. `%s` in the configured url is replaced by the account name
+func getAzureBlobAccountURL(settings models.InfinitySettings) string {
+	clientUrl := "https://%s.blob.core.windows.net/"
+	if settings.AzureBlobAccountUrl != "" {
+		clientUrl = settings.AzureBlobAccountUrl
+	}
+	if strings.Contains(clientUrl, "%s") {
+		clientUrl = fmt.Sprintf(clientUrl, settings.AzureBlobAccountName)
+	}
+	return clientUrl
+}
+
+// Dispose releases the resources held by the client such as cached tokens and responses
+func (client *Client) Dispose() {
+	if client.AzureTokenProvider != nil {
+		client.AzureTokenProvider.Dispose()
+	}
+	client.Cache.Clear()
+}
+
 func ApplySecureSocksProxyConfiguration(ctx context.Context, httpClient *http.Client, settings models.InfinitySettings) (*http.Client, error) {
 	logger := backend.Logger.FromContext(ctx)
 	if IsAwsAuthConfigured(settings) {
 		return httpClient, nil
 	}
 	t := httpClient.Transport
+	if IsTokenExchangeConfigured(settings) {
+		// if we are exchanging the user tokens, the Transport is 'TokenExchangeTransport' that wraps the authentication transport
+		t = t.(*TokenExchangeTransport).Base
+	}
 	if IsDigestAuthConfigured(settings) {
 		// if we are using Digest, the Transport is 'digest.Transport' that wraps 'http.Transport'
 		t = t.(*digest.Transport).Transport
-	} else if IsOAuthCredentialsConfigured(settings) || IsOAuthJWTConfigured(settings) {
+	} else if IsOAuthCredentialsConfigured(settings) || IsOAuthJWTConfigured(settings) || IsOAuthAuthorizationCodeConfigured(settings) {
 		// if we are using Oauth, the Transport is 'oauth2.Transport' that wraps 'http.Transport'
 		t = t.(*oauth2.Transport).Base
+	} else if IsAzureTokenAuthConfigured(settings) {
+		// if we are using Azure tokens, the Transport is 'AzureTokenTransport' that wraps 'http.Transport'
+		t = t.(*AzureTokenTransport).Base
+	} else if IsLoginTokenAuthConfigured(settings) {
+		// if we are using login tokens, the Transport is 'LoginTokenTransport' that wraps 'http.Transport'
+		t = t.(*LoginTokenTransport).Base
+	}
+	// the base 'http.Transport' is wrapped by the 'EgressTransport', the 'RateLimitTransport' and the 'RetryTransport'
+	if retryTransport, ok := t.(*RetryTransport); ok {
+		t = retryTransport.Base
+	}
+	if rateLimitTransport, ok := t.(*RateLimitTransport); ok {
+		t = rateLimitTransport.Base
+	}
+	if egressTransport, ok := t.(*EgressTransport); ok {
+		t = egressTransport.Base
 	}
 
 	// secure socks proxy configuration - checks if enabled inside the function
@@ -192,64 +278,143 @@ func replaceSect(input string, settings models.InfinitySettings, includeSect boo
 	return input
 }
 
-func (client *Client) req(ctx context.Context, url string, body io.Reader, settings models.InfinitySettings, query models.Query, requestHeaders map[string]string) (obj any, statusCode int, duration time.Duration, err error) {
+func (client *Client) req(ctx context.Context, url string, body io.Reader, settings models.InfinitySettings, query models.Query, requestHeaders map[string]string) (obj any, statusCode int, duration time.Duration, responseHeaders http.Header, responseBody []byte, err error) {
 	ctx, span := tracing.DefaultTracer().Start(ctx, "client.req")
 	logger := backend.Logger.FromContext(ctx)
 	defer span.End()
-	req, _ := GetRequest(ctx, settings, body, query, requestHeaders, true)
+	ctx = WithRetryOverride(ctx, query.Retry)
+	req, err := GetRequest(ctx, settings, body, query, requestHeaders, true)
+	if err != nil {
+		logger.Error("error creating request", "url", url, "error", err.Error())
+		return nil, http.StatusInternalServerError, 0, nil, nil, errorsource.DownstreamError(fmt.Errorf("error creating request for url %s. %w", url, err), false)
+	}
 	startTime := time.Now()
-	if !CanAllowURL(req.URL.String(), settings.AllowedHosts) {
+	if err := NewEgressPolicy(settings).Check(req.URL); err != nil {
 		logger.Error("url is not in the allowed list. make sure to match the base URL with the settings", "url", req.URL.String())
-		return nil, http.StatusUnauthorized, 0, errorsource.DownstreamError(errors.New("requested URL is not allowed. To allow this URL, update the datasource config Security -> Allowed Hosts section"), false)
+		return nil, http.StatusUnauthorized, 0, nil, nil, err
+	}
+	if !settings.IsMethodAllowed(req.Method) {
+		logger.Error("method is not in the allowed list", "method", req.Method)
+		return nil, http.StatusForbidden, 0, nil, nil, errorsource.DownstreamError(fmt.Errorf("%w. method %s", ErrMethodNotAllowed, req.Method), false)
+	}
+	ttl := getCacheTTL(settings, query)
+	cacheKey := ""
+	if client.Cache != nil && ttl > 0 {
+		cacheKey = getCacheKey(req)
+	}
+	var cached *cacheEntry
+	if cacheKey != "" {
+		if entry, ok := client.Cache.get(cacheKey); ok {
+			if time.Now().Before(entry.expires) {
+				logger.Debug("serving response from the cache", "host", req.URL.Hostname(), "url_path", req.URL.Path, "method", req.Method, "type", query.Type)
+				recordCacheStatus(ctx, CacheStatusHit)
+				obj, err = decodeResponse(query, entry.statusCode, entry.header, entry.body)
+				return obj, entry.statusCode, time.Since(startTime), entry.header.Clone(), entry.body, err
+			}
+			// the expired response can still be used when the server confirms it didn't change
+			cached = entry
+			if cached.etag != "" {
+				req.Header.Set("If-None-Match", cached.etag)
+			}
+			if cached.lastModified != "" {
+				req.Header.Set("If-Modified-Since", cached.lastModified)
+			}
+		}
+		recordCacheStatus(ctx, CacheStatusMiss)
 	}
 	logger.Debug("requesting URL", "host", req.URL.Hostname(), "url_path", req.URL.Path, "method", req.Method, "type", query.Type)
-	res, err := client.HttpClient.Do(req)
+	res, shared, err := client.Coalescer.Do(client.HttpClient, req, getCoalescingKey(req))
 	duration = time.Since(startTime)
+	if shared {
+		logger.Debug("shared the response of an identical request in flight", "host", req.URL.Hostname(), "url_path", req.URL.Path, "method", req.Method, "type", query.Type)
+		span.SetAttributes(attribute.Bool("http.request.coalesced", true))
+	}
 	logger.Debug("received response", "host", req.URL.Hostname(), "url_path", req.URL.Path, "method", req.Method, "type", query.Type, "duration_ms", duration.Milliseconds())
 	if res != nil {
 		defer res.Body.Close()
 	}
 	if err != nil {
+		if errors.Is(err, ErrEgressNotAllowed) {
+			// a redirect or an authentication request was about to reach a host which is not allowed
+			return nil, http.StatusUnauthorized, duration, nil, nil, errorsource.DownstreamError(err, false)
+		}
+		if errors.Is(err, ErrLoginFailed) {
+			logger.Error("error logging in to obtain the session token", "url", url, "error", err.Error())
+			return nil, http.StatusUnauthorized, duration, nil, nil, errorsource.DownstreamError(err, false)
+		}
+		if errors.Is(err, ErrAWSCredentials) {
+			logger.Error("error getting the aws credentials", "url", url, "error", err.Error())
+			return nil, http.StatusUnauthorized, duration, nil, nil, errorsource.DownstreamError(err, false)
+		}
+		if errors.Is(err, ErrTokenExchangeRejected) || errors.Is(err, ErrTokenExchangeNoUserToken) {
+			logger.Error("error exchanging the user token", "url", url, "error", err.Error())
+			return nil, http.StatusUnauthorized, duration, nil, nil, errorsource.DownstreamError(err, false)
+		}
+		if errors.Is(err, ErrRateLimitedLocally) {
+			return nil, http.StatusTooManyRequests, duration, nil, nil, errorsource.DownstreamError(err, false)
+		}
+		if errors.Is(err, ErrPrivateNetworkBlocked) {
+			logger.Error("connection to a private network address blocked", "url", url, "error", err.Error())
+			return nil, http.StatusForbidden, duration, nil, nil, errorsource.DownstreamError(fmt.Errorf("error getting response from url %s. %w", url, err), false)
+		}
 		if res != nil {
 			logger.Error("error getting response from server", "url", url, "method", req.Method, "error", err.Error(), "status code", res.StatusCode)
 			// Infinity can query anything and users are responsible for ensuring that endpoint/auth is correct
 			// therefore any incoming error is considered downstream
-			return nil, res.StatusCode, duration, errorsource.DownstreamError(fmt.Errorf("error getting response from %s", url), false)
+			return nil, res.StatusCode, duration, res.Header, nil, errorsource.DownstreamError(fmt.Errorf("error getting response from %s", url), false)
 		}
 		if errors.Is(err, context.Canceled) {
 			logger.Debug("request cancelled", "url", url, "method", req.Method)
-			return nil, http.StatusInternalServerError, duration, errorsource.DownstreamError(err, false)
+			return nil, http.StatusInternalServerError, duration, nil, nil, errorsource.DownstreamError(err, false)
 		}
 		logger.Error("error getting response from server. no response received", "url", url, "error", err.Error())
-		return nil, http.StatusInternalServerError, duration, errorsource.DownstreamError(fmt.Errorf("error getting response from url %s. no response received. Error: %w", url, err), false)
+		return nil, http.StatusInternalServerError, duration, nil, nil, errorsource.DownstreamError(fmt.Errorf("error getting response from url %s. no response received. Error: %w", url, err), false)
 	}
 	if res == nil {
 		logger.Error("invalid response from server and also no error", "url", url, "method", req.Method)
-		return nil, http.StatusInternalServerError, duration, errorsource.DownstreamError(fmt.Errorf("invalid response received for the URL %s", url), false)
+		return nil, http.StatusInternalServerError, duration, nil, nil, errorsource.DownstreamError(fmt.Errorf("invalid response received for the URL %s", url), false)
+	}
+	if res.StatusCode == http.StatusNotModified && cached != nil {
+		revalidated := *cached
+		revalidated.expires = time.Now().Add(ttl)
+		client.Cache.set(&revalidated)
+		recordCacheStatus(ctx, CacheStatusRevalidated)
+		obj, err = decodeResponse(query, cached.statusCode, cached.header, cached.body)
+		return obj, cached.statusCode, duration, cached.header.Clone(), cached.body, err
+	}
+	if res.StatusCode == http.StatusNotModified {
+		// the cached response was evicted or the conditional headers come from the query. the response has no body to decode
+		logger.Error("not modified response without a cached response", "url", url, "method", req.Method)
+		err = fmt.Errorf("%w. %s. there is no cached response to use. remove the If-None-Match and If-Modified-Since headers of the query", ErrUnsuccessfulHTTPResponseStatus, res.Status)
+		return nil, res.StatusCode, duration, res.Header, nil, errorsource.DownstreamError(err, false)
 	}
 	if res.StatusCode >= http.StatusBadRequest {
 		err = fmt.Errorf("%w. %s", ErrUnsuccessfulHTTPResponseStatus, res.Status)
 		// Infinity can query anything and users are responsible for ensuring that endpoint/auth is correct
 		// therefore any incoming error is considered downstream
-		return nil, res.StatusCode, duration, errorsource.DownstreamError(err, false)
+		return nil, res.StatusCode, duration, res.Header, nil, errorsource.DownstreamError(err, false)
 	}
 	bodyBytes, err := io.ReadAll(res.Body)
 	if err != nil {
 		logger.Error("error reading response body", "url", url, "error", err.Error())
-		return nil, res.StatusCode, duration, errorsource.DownstreamError(err, false)
+		return nil, res.StatusCode, duration, res.Header, nil, errorsource.DownstreamError(err, false)
 	}
-	bodyBytes = removeBOMContent(bodyBytes)
-	if CanParseAsJSON(query.Type, res.Header) {
-		var out any
-		err := json.Unmarshal(bodyBytes, &out)
-		if err != nil {
-			err = fmt.Errorf("%w. %w", ErrParsingResponseBodyAsJson, err)
-			err = errorsource.DownstreamError(err, false)
-			logger.Error("error un-marshaling JSON response", "url", url, "error", err.Error())
-		}
-		return out, res.StatusCode, duration, err
-	}
-	return string(bodyBytes), res.StatusCode, duration, err
+	obj, err = decodeResponse(query, res.StatusCode, res.Header, bodyBytes)
+	if err != nil {
+		logger.Error("error un-marshaling JSON response", "url", url, "error", err.Error())
+	}
+	if err == nil && cacheKey != "" && isCacheableResponse(res) {
+		client.Cache.set(&cacheEntry{
+			key:          cacheKey,
+			statusCode:   res.StatusCode,
+			header:       res.Header.Clone(),
+			body:         bodyBytes,
+			expires:      time.Now().Add(ttl),
+			etag:         res.Header.Get("ETag"),
+			lastModified: res.Header.Get("Last-Modified"),
+		})
+	}
+	return obj, res.StatusCode, duration, res.Header, bodyBytes, err
 }
 
 // https://stackoverflow.com/questions/31398044/got-error-invalid-character-%C3%AF-looking-for-beginning-of-value-from-json-unmar
@@ -257,52 +422,117 @@ func removeBOMContent(input []byte) []byte {
 	return bytes.TrimPrefix(input, []byte("\xef\xbb\xbf"))
 }
 
-func (client *Client) GetResults(ctx context.Context, query models.Query, requestHeaders map[string]string) (interface{}, int, time.Duration, error) {
+// GetResults fetches the query URL, or the blob of azure-blob queries, and decodes the response according to the query type.
+// The response headers are returned along with the decoded response, including the headers of unsuccessful responses.
+// The raw body is returned as well for the extractions which have to run on the response as received, such as the cursor regex.
+func (client *Client) GetResults(ctx context.Context, query models.Query, requestHeaders map[string]string) (o any, statusCode int, duration time.Duration, responseHeaders http.Header, responseBody []byte, err error) {
+	if query.Source == "azure-blob" {
+		return client.GetAzureBlobResults(ctx, query)
+	}
+	return client.req(ctx, query.URL, GetQueryBody(ctx, query), client.Settings, query, requestHeaders)
+}
+
+// GetAzureBlobResults downloads the blob referenced by the query and decodes it according to the query type
+func (client *Client) GetAzureBlobResults(ctx context.Context, query models.Query) (o any, statusCode int, duration time.Duration, responseHeaders http.Header, responseBody []byte, err error) {
+	ctx, span := tracing.DefaultTracer().Start(ctx, "client.GetAzureBlobResults")
 	logger := backend.Logger.FromContext(ctx)
+	defer span.End()
+	containerName := strings.TrimSpace(query.AzBlobContainerName)
+	blobName := strings.TrimSpace(query.AzBlobName)
+	if containerName == "" || blobName == "" {
+		return nil, http.StatusBadRequest, 0, nil, nil, errorsource.DownstreamError(errors.New("invalid/empty container name/blob name"), false)
+	}
+	if client.AzureBlobClient == nil {
+		return nil, http.StatusInternalServerError, 0, nil, nil, errorsource.PluginError(errors.New("invalid azure blob client"), false)
+	}
 	startTime := time.Now()
-
-	// Create and execute request
-	req, err := GetRequest(ctx, client.Settings, GetQueryBody(ctx, query), query, requestHeaders, true)
+	blobDownloadResponse, err := client.AzureBlobClient.DownloadStream(ctx, containerName, blobName, nil)
+	duration = time.Since(startTime)
 	if err != nil {
-		logger.Error("Failed to create request", "error", err)
-		return nil, http.StatusInternalServerError, 0, fmt.Errorf("failed to create request: %w", err)
+		span.RecordError(err)
+		logger.Error("error downloading azure blob", "container", containerName, "blob", blobName, "error", err.Error())
+		var respErr *azcore.ResponseError
+		if errors.As(err, &respErr) {
+			return nil, respErr.StatusCode, duration, nil, nil, errorsource.DownstreamError(fmt.Errorf("%w. %s", ErrUnsuccessfulHTTPResponseStatus, respErr.ErrorCode), false)
+		}
+		return nil, http.StatusInternalServerError, duration, nil, nil, errorsource.DownstreamError(fmt.Errorf("error downloading blob %s from container %s. %w", blobName, containerName, err), false)
 	}
-
-	// Execute request and log the response status
-	resp, err := client.HttpClient.Do(req)
+	defer blobDownloadResponse.Body.Close()
+	bodyBytes, err := io.ReadAll(blobDownloadResponse.Body)
+	duration = time.Since(startTime)
 	if err != nil {
-		logger.Error("Failed to execute request", "url", req.URL.String(), "error", err)
-		return nil, http.StatusInternalServerError, time.Since(startTime), err
+		logger.Error("error reading blob content", "container", containerName, "blob", blobName, "error", err.Error())
+		return nil, http.StatusInternalServerError, duration, nil, nil, errorsource.DownstreamError(fmt.Errorf("error reading blob content. %w", err), false)
 	}
-	defer resp.Body.Close()
-
-	// Log response status code
-	logger.Info("Received response", "status_code", resp.StatusCode, "url", req.URL.String())
+	responseHeaders = http.Header{}
+	if blobDownloadResponse.ContentType != nil {
+		responseHeaders.Set(headerKeyContentType, *blobDownloadResponse.ContentType)
+	}
+	o, err = decodeResponseBody(query, responseHeaders, bodyBytes)
+	if err != nil {
+		logger.Error("error decoding blob content", "container", containerName, "blob", blobName, "error", err.Error())
+	}
+	return o, http.StatusOK, duration, responseHeaders, bodyBytes, err
+}
 
-	// Check for non-2xx status codes
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		logger.Error("Received non-2xx response", "status_code", resp.StatusCode, "url", req.URL.String())
-		return nil, resp.StatusCode, time.Since(startTime), fmt.Errorf("received non-2xx response: %d", resp.StatusCode)
+// decodeResponse converts the response of a url query into the object expected by the parsers.
+// HEAD responses have no body, so their status code and headers are returned as a single object instead.
+func decodeResponse(query models.Query, statusCode int, responseHeaders http.Header, bodyBytes []byte) (any, error) {
+	if query.URLOptions.GetMethod() != http.MethodHead {
+		return decodeResponseBody(query, responseHeaders, bodyBytes)
+	}
+	out := map[string]any{"status_code": float64(statusCode)}
+	for key, values := range responseHeaders {
+		out[key] = strings.Join(values, ", ")
 	}
+	return out, nil
+}
 
-	// Read and log the response body
-	body, err := io.ReadAll(resp.Body)
+// decodeResponseBody converts the raw response bytes into the object expected by the parsers.
+// The body is converted to UTF-8 using the charset of the response Content-Type.
+// JSON based query types get the unmarshalled object and all other types get the content as string.
+func decodeResponseBody(query models.Query, responseHeaders http.Header, bodyBytes []byte) (any, error) {
+	bodyBytes, err := decodeCharset(responseHeaders, bodyBytes)
 	if err != nil {
-		logger.Error("Failed to read response body", "error", err)
-		return nil, resp.StatusCode, time.Since(startTime), fmt.Errorf("failed to read response body: %w", err)
+		return nil, err
 	}
-	logger.Debug("Response body received", "body", string(body))
-
-	// Parse response and propagate to Grafana
-	var result interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		logger.Error("Failed to parse response body", "error", err)
-		return nil, resp.StatusCode, time.Since(startTime), fmt.Errorf("failed to parse response body: %w", err)
+	bodyBytes = removeBOMContent(bodyBytes)
+	if CanParseAsJSON(query.Type, responseHeaders) {
+		var out any
+		if err := json.Unmarshal(bodyBytes, &out); err != nil {
+			return nil, errorsource.DownstreamError(fmt.Errorf("%w. %w", ErrParsingResponseBodyAsJson, err), false)
+		}
+		return out, nil
 	}
+	return string(bodyBytes), nil
+}
 
-	// Log successful parsing and return the result
-	logger.Info("Successfully parsed response", "url", req.URL.String())
-	return result, resp.StatusCode, time.Since(startTime), nil
+// decodeCharset converts the body to UTF-8 when the response Content-Type specifies another charset
+func decodeCharset(responseHeaders http.Header, bodyBytes []byte) ([]byte, error) {
+	contentType := responseHeaders.Get(headerKeyContentType)
+	if contentType == "" {
+		return bodyBytes, nil
+	}
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return bodyBytes, nil
+	}
+	label := strings.TrimSpace(params["charset"])
+	if label == "" {
+		return bodyBytes, nil
+	}
+	encoding, name := charset.Lookup(label)
+	if encoding == nil {
+		return nil, errorsource.DownstreamError(fmt.Errorf("unsupported response charset %s", label), false)
+	}
+	if name == "utf-8" {
+		return bodyBytes, nil
+	}
+	decoded, err := encoding.NewDecoder().Bytes(bodyBytes)
+	if err != nil {
+		return nil, errorsource.DownstreamError(fmt.Errorf("error decoding response body from charset %s. %w", label, err), false)
+	}
+	return decoded, nil
 }
 
 func CanParseAsJSON(queryType models.QueryType, responseHeaders http.Header) bool {
@@ -318,23 +548,23 @@ func CanParseAsJSON(queryType models.QueryType, responseHeaders http.Header) boo
 	return false
 }
 
-func CanAllowURL(url string, allowedHosts []string) bool {
-	allow := false
+// CanAllowURL returns true when the url matches one of the allowed host rules or when no rules are configured
+func CanAllowURL(rawURL string, allowedHosts []string) bool {
 	if len(allowedHosts) == 0 {
 		return true
 	}
-	for _, host := range allowedHosts {
-		if strings.HasPrefix(url, host) {
-			return true
-		}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
 	}
-	return allow
+	_, ok := models.MatchAllowedHosts(u, allowedHosts)
+	return ok
 }
 
 func GetQueryBody(ctx context.Context, query models.Query) io.Reader {
 	logger := backend.Logger.FromContext(ctx)
 	var body io.Reader
-	if strings.EqualFold(query.URLOptions.Method, http.MethodPost) {
+	if query.URLOptions.HasBody() {
 		switch query.URLOptions.BodyType {
 		case "raw":
 			body = strings.NewReader(query.URLOptions.Body)
@@ -2,15 +2,36 @@ package infinity_test
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
+	"math/big"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/infinity"
 	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/models"
+	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/pluginhost"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestInfinityClient_GetResults(t *testing.T) {
@@ -67,7 +88,7 @@ func TestInfinityClient_GetResults(t *testing.T) {
 				Settings:   tt.settings,
 				HttpClient: &http.Client{},
 			}
-			gotO, statusCode, duration, err := client.GetResults(context.Background(), tt.query, tt.requestHeaders)
+			gotO, statusCode, duration, _, _, err := client.GetResults(context.Background(), tt.query, tt.requestHeaders)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetResults() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -79,6 +100,21 @@ func TestInfinityClient_GetResults(t *testing.T) {
 	}
 }
 
+func TestInfinityClient_GetAzureBlobResults(t *testing.T) {
+	t.Run("should fail when container or blob name is empty", func(t *testing.T) {
+		client := &infinity.Client{Settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodAzureBlob}}
+		_, statusCode, _, _, _, err := client.GetResults(context.Background(), models.Query{Source: "azure-blob", AzBlobContainerName: "container"}, nil)
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+		assert.Equal(t, errors.New("invalid/empty container name/blob name").Error(), err.Error())
+	})
+	t.Run("should fail when azure blob client is not configured", func(t *testing.T) {
+		client := &infinity.Client{Settings: models.InfinitySettings{}}
+		_, statusCode, _, _, _, err := client.GetResults(context.Background(), models.Query{Source: "azure-blob", AzBlobContainerName: "container", AzBlobName: "blob.json"}, nil)
+		assert.Equal(t, http.StatusInternalServerError, statusCode)
+		assert.Equal(t, "invalid azure blob client", err.Error())
+	})
+}
+
 func TestCanAllowURL(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -101,9 +137,15 @@ func TestCanAllowURL(t *testing.T) {
 			want:         false,
 		},
 		{
-			name:         "should match only case sensitive URL",
+			name:         "should match host names case insensitive",
 			url:          "https://FOO.com",
 			allowedHosts: []string{"https://foo.com"},
+			want:         true,
+		},
+		{
+			name:         "should not match other hosts sharing the prefix",
+			url:          "https://foo.com.evil.net",
+			allowedHosts: []string{"https://foo.com"},
 			want:         false,
 		},
 		{
@@ -284,3 +326,1083 @@ JIoTknAYsX8acOy3XzTdA+mN139mLnG+Tpu1bbjcJLihtPieo5NVWBi/jZedo0Ex
 l7aV0Ij7+2S+ynhQUspKZ+fu3Ng+UuMauX9RpkMsfxRyKuj4WrOMVfI=
 -----END RSA PRIVATE KEY-----`
 )
+
+func TestEgressPolicy(t *testing.T) {
+	blockedRequests := &atomic.Int32{}
+	blockedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		blockedRequests.Add(1)
+		_, _ = w.Write([]byte(`{"message":"blocked server reached"}`))
+	}))
+	defer blockedServer.Close()
+	allowedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token":"foo","token_type":"Bearer","expires_in":3600}`))
+		case "/metadata/identity/oauth2/token":
+			_, _ = w.Write([]byte(`{"access_token":"foo","expires_in":"3600","token_type":"Bearer"}`))
+		case "/api/redirect":
+			http.Redirect(w, r, blockedServer.URL, http.StatusFound)
+		default:
+			_, _ = w.Write([]byte(`{"message":"ok"}`))
+		}
+	}))
+	defer allowedServer.Close()
+	t.Setenv("AZURE_POD_IDENTITY_AUTHORITY_HOST", allowedServer.URL)
+	t.Setenv("GF_DEFAULT_APP_MODE", "")
+	allowedHosts := []string{allowedServer.URL + "/api"}
+	tests := []struct {
+		name     string
+		settings models.InfinitySettings
+	}{
+		{name: "no auth", settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodNone}},
+		{name: "basic auth", settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodBasic, UserName: "foo", Password: "bar"}},
+		{name: "api key", settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodApiKey, ApiKeyKey: "foo", ApiKeyValue: "bar", ApiKeyType: "header"}},
+		{name: "bearer token", settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodBearerToken, BearerToken: "foo"}},
+		{name: "digest auth", settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodDigestAuth, UserName: "foo", Password: "bar"}},
+		{name: "oauth2 client credentials", settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodOAuth, OAuth2Settings: models.OAuth2Settings{OAuth2Type: models.AuthOAuthTypeClientCredentials, ClientID: "foo", ClientSecret: "bar", TokenURL: allowedServer.URL + "/token"}}},
+		{name: "oauth2 authorization code", settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodOAuth, OAuth2Settings: models.OAuth2Settings{OAuth2Type: models.AuthOAuthAuthorizationCode, ClientID: "foo", ClientSecret: "bar", AuthURL: allowedServer.URL + "/authorize", TokenURL: allowedServer.URL + "/token", RedirectURL: "https://grafana.example.com/callback", RefreshToken: "foo"}}},
+		{name: "aws", settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodAWS, AWSSettings: models.AWSSettings{AuthType: models.AWSAuthTypeKeys}, AWSAccessKey: "foo", AWSSecretKey: "bar"}},
+		{name: "azure managed identity", settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodManagedIdentity}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blockedRequests.Store(0)
+			tt.settings.AllowedHosts = allowedHosts
+			client, err := infinity.NewClient(context.Background(), tt.settings)
+			require.NoError(t, err)
+			t.Run("query to an allowed host should succeed", func(t *testing.T) {
+				_, statusCode, _, _, _, err := client.GetResults(context.Background(), models.Query{URL: allowedServer.URL + "/api", Type: models.QueryTypeJSON}, map[string]string{})
+				require.NoError(t, err)
+				assert.Equal(t, http.StatusOK, statusCode)
+			})
+			t.Run("query to a host which is not allowed should fail", func(t *testing.T) {
+				_, statusCode, _, _, _, err := client.GetResults(context.Background(), models.Query{URL: blockedServer.URL, Type: models.QueryTypeJSON}, map[string]string{})
+				require.ErrorIs(t, err, infinity.ErrEgressNotAllowed)
+				assert.Equal(t, http.StatusUnauthorized, statusCode)
+			})
+			t.Run("redirect to a host which is not allowed should fail", func(t *testing.T) {
+				_, _, _, _, _, err := client.GetResults(context.Background(), models.Query{URL: allowedServer.URL + "/api/redirect", Type: models.QueryTypeJSON}, map[string]string{})
+				require.ErrorIs(t, err, infinity.ErrEgressNotAllowed)
+			})
+			t.Run("direct use of the http client should fail", func(t *testing.T) {
+				res, err := client.HttpClient.Get(blockedServer.URL)
+				if res != nil {
+					res.Body.Close()
+				}
+				require.ErrorIs(t, err, infinity.ErrEgressNotAllowed)
+			})
+			t.Run("custom health check to a host which is not allowed should fail", func(t *testing.T) {
+				client.Settings.CustomHealthCheckEnabled = true
+				client.Settings.CustomHealthCheckUrl = blockedServer.URL
+				res, err := pluginhost.CheckHealth(context.Background(), client, &backend.CheckHealthRequest{})
+				require.NoError(t, err)
+				assert.Equal(t, backend.HealthStatusError, res.Status)
+				assert.Contains(t, res.Message, infinity.ErrEgressNotAllowed.Error())
+				assert.Contains(t, res.Message, "url doesn't match any of the allowed hosts "+allowedServer.URL+"/api")
+			})
+			t.Run("custom health check should explain the matching rule", func(t *testing.T) {
+				client.Settings.CustomHealthCheckEnabled = true
+				client.Settings.CustomHealthCheckUrl = allowedServer.URL + "/api/health"
+				res, err := pluginhost.CheckHealth(context.Background(), client, &backend.CheckHealthRequest{})
+				require.NoError(t, err)
+				assert.Equal(t, backend.HealthStatusOk, res.Status)
+				assert.Contains(t, res.Message, "allowed by rule "+allowedServer.URL+"/api")
+			})
+			assert.Equal(t, int32(0), blockedRequests.Load())
+		})
+	}
+	t.Run("token exchange endpoint should be allowed", func(t *testing.T) {
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{
+			AuthenticationMethod: models.AuthenticationMethodForwardOauth,
+			ForwardOauthIdentity: true,
+			AllowedHosts:         allowedHosts,
+			TokenExchange:        models.TokenExchangeSettings{Type: models.TokenExchangeTypeRFC8693, TokenURL: allowedServer.URL + "/token", ClientID: "foo"},
+		})
+		require.NoError(t, err)
+		_, statusCode, _, _, _, err := client.GetResults(context.Background(), models.Query{URL: allowedServer.URL + "/api", Type: models.QueryTypeJSON}, map[string]string{"Authorization": "Bearer user"})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, statusCode)
+	})
+	t.Run("login endpoint on another host should be allowed", func(t *testing.T) {
+		loginServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"token":"foo"}`))
+		}))
+		defer loginServer.Close()
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{
+			AuthenticationMethod: models.AuthenticationMethodLoginToken,
+			AllowedHosts:         allowedHosts,
+			LoginToken:           models.LoginTokenSettings{URL: " " + loginServer.URL + "/login ", TokenSelector: "token"},
+		})
+		require.NoError(t, err)
+		_, statusCode, _, _, _, err := client.GetResults(context.Background(), models.Query{URL: allowedServer.URL + "/api", Type: models.QueryTypeJSON}, map[string]string{})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, statusCode)
+		_, _, _, _, _, err = client.GetResults(context.Background(), models.Query{URL: loginServer.URL + "/api", Type: models.QueryTypeJSON}, map[string]string{})
+		require.ErrorIs(t, err, infinity.ErrEgressNotAllowed)
+	})
+	t.Run("token endpoints are checked as well", func(t *testing.T) {
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodOAuth, AllowedHosts: allowedHosts, OAuth2Settings: models.OAuth2Settings{OAuth2Type: models.AuthOAuthTypeClientCredentials, ClientID: "foo", ClientSecret: "bar", TokenURL: allowedServer.URL + "/api/redirect"}})
+		require.NoError(t, err)
+		_, _, _, _, _, err = client.GetResults(context.Background(), models.Query{URL: allowedServer.URL + "/api", Type: models.QueryTypeJSON}, map[string]string{})
+		require.ErrorIs(t, err, infinity.ErrEgressNotAllowed)
+		assert.Equal(t, int32(0), blockedRequests.Load())
+	})
+}
+
+func TestBlockPrivateNetworks(t *testing.T) {
+	requests := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte(`{"message":"ok"}`))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	tests := []struct {
+		name       string
+		settings   models.InfinitySettings
+		url        string
+		wantErr    bool
+		wantStatus int
+	}{
+		{name: "private networks are allowed by default", url: server.URL, wantStatus: http.StatusOK},
+		{name: "loopback address should be blocked", settings: models.InfinitySettings{BlockPrivateNetworks: true}, url: server.URL, wantErr: true, wantStatus: http.StatusForbidden},
+		{name: "host names resolving to loopback should be blocked", settings: models.InfinitySettings{BlockPrivateNetworks: true}, url: "http://localhost:" + port, wantErr: true, wantStatus: http.StatusForbidden},
+		{name: "metadata endpoint should be blocked", settings: models.InfinitySettings{BlockPrivateNetworks: true, TimeoutInSeconds: 1}, url: "http://169.254.169.254/metadata/identity/oauth2/token", wantErr: true, wantStatus: http.StatusForbidden},
+		{name: "exceptions should be allowed", settings: models.InfinitySettings{BlockPrivateNetworks: true, PrivateNetworkExceptions: []string{"127.0.0.1", "::1"}}, url: server.URL, wantStatus: http.StatusOK},
+		{name: "exceptions should be allowed with CIDR", settings: models.InfinitySettings{BlockPrivateNetworks: true, PrivateNetworkExceptions: []string{"127.0.0.0/8"}}, url: server.URL, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			client, err := infinity.NewClient(context.Background(), tt.settings)
+			require.NoError(t, err)
+			_, statusCode, _, _, _, err := client.GetResults(context.Background(), models.Query{URL: tt.url, Type: models.QueryTypeJSON}, map[string]string{})
+			assert.Equal(t, tt.wantStatus, statusCode)
+			if tt.wantErr {
+				require.ErrorIs(t, err, infinity.ErrPrivateNetworkBlocked)
+				assert.Equal(t, int32(0), requests.Load())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, int32(1), requests.Load())
+		})
+	}
+}
+
+func TestInfinityClient_GetResultsDecoding(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		queryType   models.QueryType
+		wantO       any
+		wantErr     string
+	}{
+		{name: "json", contentType: "application/json", body: "\xef\xbb\xbf{\"name\":\"foo\"}", queryType: models.QueryTypeJSON, wantO: map[string]any{"name": "foo"}},
+		{name: "json without content type", body: `[1,2]`, queryType: models.QueryTypeJSON, wantO: []any{1.0, 2.0}},
+		{name: "invalid json", contentType: "application/json", body: `foo`, queryType: models.QueryTypeJSON, wantErr: "unable to parse response body as JSON. invalid character 'o' in literal false (expecting 'a')"},
+		{name: "csv", contentType: "text/csv", body: "name\nfoo", queryType: models.QueryTypeCSV, wantO: "name\nfoo"},
+		{name: "csv with BOM", contentType: "text/csv", body: "\xef\xbb\xbfname\nfoo", queryType: models.QueryTypeCSV, wantO: "name\nfoo"},
+		{name: "tsv", contentType: "text/tab-separated-values", body: "name\tage\nfoo\t1", queryType: models.QueryTypeTSV, wantO: "name\tage\nfoo\t1"},
+		{name: "xml", contentType: "application/xml", body: "<a>foo</a>", queryType: models.QueryTypeXML, wantO: "<a>foo</a>"},
+		{name: "html", contentType: "text/html", body: "<html></html>", queryType: models.QueryTypeHTML, wantO: "<html></html>"},
+		{name: "uql with json content type", contentType: "application/json; charset=utf-8", body: `{"name":"foo"}`, queryType: models.QueryTypeUQL, wantO: map[string]any{"name": "foo"}},
+		{name: "uql with csv content type", contentType: "text/csv", body: "name\nfoo", queryType: models.QueryTypeUQL, wantO: "name\nfoo"},
+		{name: "latin-1 csv", contentType: "text/csv; charset=ISO-8859-1", body: "name\nJos\xe9", queryType: models.QueryTypeCSV, wantO: "name\nJosé"},
+		{name: "windows-1252 json", contentType: "application/json; charset=windows-1252", body: "{\"price\":\"\x80 5\"}", queryType: models.QueryTypeJSON, wantO: map[string]any{"price": "€ 5"}},
+		{name: "utf-16 xml", contentType: "application/xml; charset=utf-16le", body: "<\x00a\x00>\x00\xe9\x00<\x00/\x00a\x00>\x00", queryType: models.QueryTypeXML, wantO: "<a>é</a>"},
+		{name: "unsupported charset", contentType: "text/csv; charset=foo", body: "name\nfoo", queryType: models.QueryTypeCSV, wantErr: "unsupported response charset foo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			client, err := infinity.NewClient(context.Background(), models.InfinitySettings{})
+			require.NoError(t, err)
+			gotO, statusCode, _, _, _, err := client.GetResults(context.Background(), models.Query{URL: server.URL, Type: tt.queryType}, map[string]string{})
+			assert.Equal(t, http.StatusOK, statusCode)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantErr, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantO, gotO)
+		})
+	}
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		settings     models.RetrySettings
+		query        models.Query
+		failures     int
+		status       int
+		retryAfter   string
+		wantAttempts int
+		wantStatus   int
+	}{
+		{name: "retries are disabled by default", failures: 1, status: http.StatusServiceUnavailable, wantAttempts: 1, wantStatus: http.StatusServiceUnavailable},
+		{name: "retryable status should be retried", settings: models.RetrySettings{MaxAttempts: 3, InitialDelayMs: 1}, failures: 2, status: http.StatusServiceUnavailable, wantAttempts: 3, wantStatus: http.StatusOK},
+		{name: "retries should stop after max attempts", settings: models.RetrySettings{MaxAttempts: 3, InitialDelayMs: 1}, failures: 5, status: http.StatusBadGateway, wantAttempts: 3, wantStatus: http.StatusBadGateway},
+		{name: "non retryable status should not be retried", settings: models.RetrySettings{MaxAttempts: 3, InitialDelayMs: 1}, failures: 1, status: http.StatusNotFound, wantAttempts: 1, wantStatus: http.StatusNotFound},
+		{name: "configured status codes should be retried", settings: models.RetrySettings{MaxAttempts: 3, InitialDelayMs: 1, StatusCodes: []int{http.StatusNotFound}}, failures: 1, status: http.StatusNotFound, wantAttempts: 2, wantStatus: http.StatusOK},
+		{name: "retry after should be honored", settings: models.RetrySettings{MaxAttempts: 3, InitialDelayMs: 1}, failures: 1, status: http.StatusTooManyRequests, retryAfter: "0", wantAttempts: 2, wantStatus: http.StatusOK},
+		{name: "retry after beyond the max delay should not be retried", settings: models.RetrySettings{MaxAttempts: 3, InitialDelayMs: 1, MaxDelayMs: 1000}, failures: 1, status: http.StatusTooManyRequests, retryAfter: "120", wantAttempts: 1, wantStatus: http.StatusTooManyRequests},
+		{name: "post should not be retried by default", settings: models.RetrySettings{MaxAttempts: 3, InitialDelayMs: 1}, query: models.Query{URLOptions: models.URLOptions{Method: http.MethodPost, BodyType: "raw", Body: `{"foo":"bar"}`}}, failures: 1, status: http.StatusServiceUnavailable, wantAttempts: 1, wantStatus: http.StatusServiceUnavailable},
+		{name: "post should be retried with the same body when configured", settings: models.RetrySettings{MaxAttempts: 3, InitialDelayMs: 1, Methods: []string{http.MethodPost}}, query: models.Query{URLOptions: models.URLOptions{Method: http.MethodPost, BodyType: "raw", Body: `{"foo":"bar"}`}}, failures: 1, status: http.StatusServiceUnavailable, wantAttempts: 2, wantStatus: http.StatusOK},
+		{name: "query should override the datasource settings", settings: models.RetrySettings{MaxAttempts: 3, InitialDelayMs: 1}, query: models.Query{Retry: &models.RetrySettings{MaxAttempts: 1}}, failures: 1, status: http.StatusServiceUnavailable, wantAttempts: 1, wantStatus: http.StatusServiceUnavailable},
+		{name: "query should enable the retries", query: models.Query{Retry: &models.RetrySettings{MaxAttempts: 2, InitialDelayMs: 1}}, failures: 1, status: http.StatusServiceUnavailable, wantAttempts: 2, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := &atomic.Int32{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if r.Method == http.MethodPost {
+					assert.Equal(t, `{"foo":"bar"}`, string(body))
+				}
+				if int(requests.Add(1)) <= tt.failures {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					w.WriteHeader(tt.status)
+					return
+				}
+				_, _ = w.Write([]byte(`{"message":"ok"}`))
+			}))
+			defer server.Close()
+			client, err := infinity.NewClient(context.Background(), models.InfinitySettings{Retry: tt.settings})
+			require.NoError(t, err)
+			query := tt.query
+			query.URL = server.URL
+			query.Type = models.QueryTypeJSON
+			query.Parser = models.InfinityParserBackend
+			frame, _, err := infinity.GetFrameForURLSourcesWithPostProcessing(context.Background(), query, *client, map[string]string{}, false)
+			require.NotNil(t, frame)
+			customMeta, ok := frame.Meta.Custom.(*infinity.CustomMeta)
+			require.True(t, ok)
+			assert.Equal(t, tt.wantAttempts, int(requests.Load()))
+			assert.Equal(t, tt.wantAttempts, customMeta.Attempts)
+			assert.Equal(t, tt.wantStatus, customMeta.ResponseCodeFromServer)
+			if tt.wantStatus != http.StatusOK {
+				require.ErrorIs(t, err, infinity.ErrUnsuccessfulHTTPResponseStatus)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	requests := &atomic.Int32{}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte(`{"message":"ok"}`))
+	})
+	server1 := httptest.NewServer(handler)
+	defer server1.Close()
+	server2 := httptest.NewServer(handler)
+	defer server2.Close()
+	tests := []struct {
+		name         string
+		limits       []models.RateLimitSettings
+		timeout      time.Duration
+		urls         []string
+		wantRequests int32
+		minDuration  time.Duration
+	}{
+		{name: "requests within the burst should not wait", limits: []models.RateLimitSettings{{Host: "127.0.0.1", RequestsPerSecond: 1, Burst: 3}}, urls: []string{server1.URL, server1.URL, server1.URL}, wantRequests: 3},
+		{name: "requests beyond the burst should wait", limits: []models.RateLimitSettings{{Host: "127.0.0.1", RequestsPerSecond: 10, Burst: 1}}, urls: []string{server1.URL, server1.URL, server1.URL}, wantRequests: 3, minDuration: 150 * time.Millisecond},
+		{name: "requests waiting longer than the max wait should fail", limits: []models.RateLimitSettings{{Host: "127.0.0.1", RequestsPerSecond: 1, MaxWaitMs: 10}}, urls: []string{server1.URL, server1.URL}, wantRequests: 1},
+		{name: "requests waiting beyond the context deadline should fail", limits: []models.RateLimitSettings{{Host: "127.0.0.1", RequestsPerSecond: 0.1}}, timeout: 5 * time.Second, urls: []string{server1.URL, server1.URL}, wantRequests: 1},
+		{name: "other hosts should not be limited", limits: []models.RateLimitSettings{{Host: "example.com", RequestsPerSecond: 1, MaxWaitMs: 10}}, urls: []string{server1.URL, server1.URL}, wantRequests: 2},
+		{name: "hosts matching a rule should share the limit", limits: []models.RateLimitSettings{{Host: "http://127.0.0.1:*", RequestsPerSecond: 1, MaxWaitMs: 10}}, urls: []string{server1.URL, server2.URL}, wantRequests: 1},
+		{name: "wildcard should limit each host separately", limits: []models.RateLimitSettings{{Host: "*", RequestsPerSecond: 1, MaxWaitMs: 10}}, urls: []string{server1.URL, fmt.Sprintf("http://localhost:%d", server2.Listener.Addr().(*net.TCPAddr).Port)}, wantRequests: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			client, err := infinity.NewClient(context.Background(), models.InfinitySettings{RateLimits: tt.limits})
+			require.NoError(t, err)
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			startTime := time.Now()
+			for i, u := range tt.urls {
+				_, statusCode, _, _, _, err := client.GetResults(ctx, models.Query{URL: u, Type: models.QueryTypeJSON}, map[string]string{})
+				if int32(i) >= tt.wantRequests {
+					require.ErrorIs(t, err, infinity.ErrRateLimitedLocally)
+					assert.Equal(t, http.StatusTooManyRequests, statusCode)
+					continue
+				}
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantRequests, requests.Load())
+			assert.GreaterOrEqual(t, time.Since(startTime), tt.minDuration)
+		})
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	requests, notModified := &atomic.Int32{}, &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path == "/no-store" {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		if strings.HasPrefix(r.URL.Path, "/large") {
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"data":%q}`, strings.Repeat("x", 600*1024))))
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte(fmt.Sprintf(`{"authorization":%q}`, r.Header.Get("Authorization"))))
+	}))
+	defer server.Close()
+	ttl := func(seconds int) *int { return &seconds }
+	getResults := func(t *testing.T, client *infinity.Client, query models.Query, requestHeaders map[string]string) (any, infinity.CacheStatus) {
+		t.Helper()
+		ctx, cacheStats := infinity.WithCacheStats(context.Background())
+		o, statusCode, _, _, _, err := client.GetResults(ctx, query, requestHeaders)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, statusCode)
+		return o, cacheStats.Status()
+	}
+	query := models.Query{URL: server.URL, Type: models.QueryTypeJSON}
+	t.Run("identical requests should be served from the cache", func(t *testing.T) {
+		requests.Store(0)
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{Cache: models.CacheSettings{TTLSeconds: 60}})
+		require.NoError(t, err)
+		o1, status1 := getResults(t, client, query, map[string]string{})
+		o2, status2 := getResults(t, client, query, map[string]string{})
+		assert.Equal(t, o1, o2)
+		assert.Equal(t, infinity.CacheStatusMiss, status1)
+		assert.Equal(t, infinity.CacheStatusHit, status2)
+		assert.Equal(t, int32(1), requests.Load())
+	})
+	t.Run("responses should not be cached without a ttl", func(t *testing.T) {
+		requests.Store(0)
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{})
+		require.NoError(t, err)
+		_, status := getResults(t, client, query, map[string]string{})
+		_, _ = getResults(t, client, query, map[string]string{})
+		assert.Equal(t, infinity.CacheStatus(""), status)
+		assert.Equal(t, int32(2), requests.Load())
+	})
+	t.Run("query ttl should override the datasource ttl", func(t *testing.T) {
+		requests.Store(0)
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{Cache: models.CacheSettings{TTLSeconds: 60}})
+		require.NoError(t, err)
+		noCacheQuery := query
+		noCacheQuery.CacheTTLSeconds = ttl(0)
+		_, _ = getResults(t, client, noCacheQuery, map[string]string{})
+		_, _ = getResults(t, client, noCacheQuery, map[string]string{})
+		assert.Equal(t, int32(2), requests.Load())
+	})
+	t.Run("responses with no-store should not be cached", func(t *testing.T) {
+		requests.Store(0)
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{Cache: models.CacheSettings{TTLSeconds: 60}})
+		require.NoError(t, err)
+		noStoreQuery := models.Query{URL: server.URL + "/no-store", Type: models.QueryTypeJSON}
+		_, _ = getResults(t, client, noStoreQuery, map[string]string{})
+		_, _ = getResults(t, client, noStoreQuery, map[string]string{})
+		assert.Equal(t, int32(2), requests.Load())
+	})
+	t.Run("forwarded identities should not share cached responses", func(t *testing.T) {
+		requests.Store(0)
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{ForwardOauthIdentity: true, Cache: models.CacheSettings{TTLSeconds: 60}})
+		require.NoError(t, err)
+		o1, _ := getResults(t, client, query, map[string]string{"Authorization": "Bearer user1"})
+		o2, status := getResults(t, client, query, map[string]string{"Authorization": "Bearer user2"})
+		assert.Equal(t, map[string]any{"authorization": "Bearer user1"}, o1)
+		assert.Equal(t, map[string]any{"authorization": "Bearer user2"}, o2)
+		assert.Equal(t, infinity.CacheStatusMiss, status)
+		assert.Equal(t, int32(2), requests.Load())
+	})
+	t.Run("expired responses should be revalidated", func(t *testing.T) {
+		requests.Store(0)
+		notModified.Store(0)
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{Cache: models.CacheSettings{TTLSeconds: 1}})
+		require.NoError(t, err)
+		o1, _ := getResults(t, client, query, map[string]string{})
+		time.Sleep(1100 * time.Millisecond)
+		o2, status := getResults(t, client, query, map[string]string{})
+		assert.Equal(t, o1, o2)
+		assert.Equal(t, infinity.CacheStatusRevalidated, status)
+		assert.Equal(t, int32(2), requests.Load())
+		assert.Equal(t, int32(1), notModified.Load())
+		_, status = getResults(t, client, query, map[string]string{})
+		assert.Equal(t, infinity.CacheStatusHit, status)
+	})
+	t.Run("not modified responses without a cached response should fail", func(t *testing.T) {
+		requests.Store(0)
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{})
+		require.NoError(t, err)
+		conditionalQuery := query
+		conditionalQuery.URLOptions.Headers = []models.URLOptionKeyValuePair{{Key: "If-None-Match", Value: `"v1"`}}
+		o, statusCode, _, _, _, err := client.GetResults(context.Background(), conditionalQuery, map[string]string{})
+		require.ErrorIs(t, err, infinity.ErrUnsuccessfulHTTPResponseStatus)
+		assert.Contains(t, err.Error(), "there is no cached response")
+		assert.Equal(t, http.StatusNotModified, statusCode)
+		assert.Nil(t, o)
+		assert.Equal(t, int32(1), requests.Load())
+	})
+	t.Run("least recently used responses should be evicted beyond the max size", func(t *testing.T) {
+		requests.Store(0)
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{Cache: models.CacheSettings{TTLSeconds: 60, MaxSizeMB: 1}})
+		require.NoError(t, err)
+		large1 := models.Query{URL: server.URL + "/large1", Type: models.QueryTypeJSON}
+		large2 := models.Query{URL: server.URL + "/large2", Type: models.QueryTypeJSON}
+		_, _ = getResults(t, client, large1, map[string]string{})
+		_, _ = getResults(t, client, large2, map[string]string{})
+		_, status := getResults(t, client, large2, map[string]string{})
+		assert.Equal(t, infinity.CacheStatusHit, status)
+		_, status = getResults(t, client, large1, map[string]string{})
+		assert.Equal(t, infinity.CacheStatusMiss, status)
+		assert.Equal(t, int32(3), requests.Load())
+	})
+	t.Run("dispose should clear the cache", func(t *testing.T) {
+		requests.Store(0)
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{Cache: models.CacheSettings{TTLSeconds: 60}})
+		require.NoError(t, err)
+		_, _ = getResults(t, client, query, map[string]string{})
+		client.Dispose()
+		_, status := getResults(t, client, query, map[string]string{})
+		assert.Equal(t, infinity.CacheStatusMiss, status)
+		assert.Equal(t, int32(2), requests.Load())
+	})
+}
+
+func TestRequestCoalescing(t *testing.T) {
+	requests := &atomic.Int32{}
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		_, _ = w.Write([]byte(fmt.Sprintf(`{"authorization":%q}`, r.Header.Get("Authorization"))))
+	}))
+	defer server.Close()
+	type result struct {
+		o   any
+		err error
+	}
+	getResults := func(ctx context.Context, client *infinity.Client, query models.Query, requestHeaders map[string]string, results chan<- result) {
+		o, _, _, _, _, err := client.GetResults(ctx, query, requestHeaders)
+		results <- result{o: o, err: err}
+	}
+	query := models.Query{URL: server.URL, Type: models.QueryTypeJSON}
+	t.Run("identical concurrent requests should share a single upstream request", func(t *testing.T) {
+		requests.Store(0)
+		release = make(chan struct{})
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{})
+		require.NoError(t, err)
+		results := make(chan result, 5)
+		for range 5 {
+			go getResults(context.Background(), client, query, map[string]string{}, results)
+		}
+		require.Eventually(t, func() bool { return requests.Load() == 1 }, 5*time.Second, time.Millisecond)
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+		for range 5 {
+			r := <-results
+			require.NoError(t, r.err)
+			assert.Equal(t, map[string]any{"authorization": ""}, r.o)
+		}
+		assert.Equal(t, int32(1), requests.Load())
+		_, _, _, _, _, err = client.GetResults(context.Background(), query, map[string]string{})
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), requests.Load(), "completed requests should not be reused")
+	})
+	t.Run("requests with different identities should not be coalesced", func(t *testing.T) {
+		requests.Store(0)
+		release = make(chan struct{})
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{ForwardOauthIdentity: true})
+		require.NoError(t, err)
+		results := make(chan result, 2)
+		go getResults(context.Background(), client, query, map[string]string{"Authorization": "Bearer user1"}, results)
+		go getResults(context.Background(), client, query, map[string]string{"Authorization": "Bearer user2"}, results)
+		require.Eventually(t, func() bool { return requests.Load() == 2 }, 5*time.Second, time.Millisecond)
+		close(release)
+		authorizations := []any{}
+		for range 2 {
+			r := <-results
+			require.NoError(t, r.err)
+			authorizations = append(authorizations, r.o.(map[string]any)["authorization"])
+		}
+		assert.ElementsMatch(t, []any{"Bearer user1", "Bearer user2"}, authorizations)
+	})
+	t.Run("post requests should not be coalesced", func(t *testing.T) {
+		requests.Store(0)
+		release = make(chan struct{})
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{})
+		require.NoError(t, err)
+		postQuery := models.Query{URL: server.URL, Type: models.QueryTypeJSON, URLOptions: models.URLOptions{Method: http.MethodPost, Body: `{}`}}
+		results := make(chan result, 2)
+		for range 2 {
+			go getResults(context.Background(), client, postQuery, map[string]string{}, results)
+		}
+		require.Eventually(t, func() bool { return requests.Load() == 2 }, 5*time.Second, time.Millisecond)
+		close(release)
+		for range 2 {
+			require.NoError(t, (<-results).err)
+		}
+	})
+	t.Run("cancelled requests should stop waiting without affecting the shared request", func(t *testing.T) {
+		requests.Store(0)
+		release = make(chan struct{})
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{})
+		require.NoError(t, err)
+		results, cancelledResults := make(chan result, 1), make(chan result, 1)
+		go getResults(context.Background(), client, query, map[string]string{}, results)
+		require.Eventually(t, func() bool { return requests.Load() == 1 }, 5*time.Second, time.Millisecond)
+		ctx, cancel := context.WithCancel(context.Background())
+		go getResults(ctx, client, query, map[string]string{}, cancelledResults)
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+		assert.ErrorIs(t, (<-cancelledResults).err, context.Canceled)
+		close(release)
+		require.NoError(t, (<-results).err)
+		assert.Equal(t, int32(1), requests.Load())
+	})
+}
+
+func TestRequestMethods(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Method", r.Method)
+		_, _ = w.Write([]byte(fmt.Sprintf(`{"method":%q,"body":%q,"content_type":%q}`, r.Method, string(body), r.Header.Get("Content-Type"))))
+	}))
+	defer server.Close()
+	allMethods := []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"}
+	tests := []struct {
+		name           string
+		allowedMethods []string
+		urlOptions     models.URLOptions
+		want           any
+		wantErr        error
+	}{
+		{
+			name: "should use GET when no method is specified",
+			want: map[string]any{"method": "GET", "body": "", "content_type": ""},
+		},
+		{
+			name:       "should send the body of POST requests",
+			urlOptions: models.URLOptions{Method: "POST", BodyType: "raw", Body: `{"q":1}`, BodyContentType: "application/json"},
+			want:       map[string]any{"method": "POST", "body": `{"q":1}`, "content_type": "application/json"},
+		},
+		{
+			name:           "should send the body of PUT requests",
+			allowedMethods: allMethods,
+			urlOptions:     models.URLOptions{Method: "PUT", BodyType: "raw", Body: `{"q":1}`, BodyContentType: "application/json"},
+			want:           map[string]any{"method": "PUT", "body": `{"q":1}`, "content_type": "application/json"},
+		},
+		{
+			name:           "should send the body of PATCH requests",
+			allowedMethods: allMethods,
+			urlOptions:     models.URLOptions{Method: "patch", BodyType: "x-www-form-urlencoded", BodyForm: []models.URLOptionKeyValuePair{{Key: "q", Value: "1"}}},
+			want:           map[string]any{"method": "PATCH", "body": "q=1", "content_type": "application/x-www-form-urlencoded"},
+		},
+		{
+			name:           "should not send a body with DELETE requests",
+			allowedMethods: allMethods,
+			urlOptions:     models.URLOptions{Method: "DELETE", BodyType: "raw", Body: `{"q":1}`},
+			want:           map[string]any{"method": "DELETE", "body": "", "content_type": ""},
+		},
+		{
+			name:           "should return the status code and headers of HEAD requests",
+			allowedMethods: allMethods,
+			urlOptions:     models.URLOptions{Method: "HEAD"},
+			want:           map[string]any{"status_code": float64(200), "Content-Type": "application/json", "X-Method": "HEAD"},
+		},
+		{
+			name:       "should not allow PUT requests by default",
+			urlOptions: models.URLOptions{Method: "PUT", BodyType: "raw", Body: `{"q":1}`},
+			wantErr:    infinity.ErrMethodNotAllowed,
+		},
+		{
+			name:           "should not allow methods missing from the allowed methods",
+			allowedMethods: []string{"GET", "PUT"},
+			urlOptions:     models.URLOptions{Method: "POST", BodyType: "raw", Body: `{"q":1}`},
+			wantErr:        infinity.ErrMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := infinity.NewClient(context.Background(), models.InfinitySettings{AllowedMethods: tt.allowedMethods})
+			require.NoError(t, err)
+			query := models.Query{URL: server.URL, Type: models.QueryTypeJSON, Source: "url", URLOptions: tt.urlOptions}
+			o, statusCode, _, _, _, err := client.GetResults(context.Background(), query, map[string]string{})
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				assert.Equal(t, http.StatusForbidden, statusCode)
+				return
+			}
+			require.NoError(t, err)
+			if result, ok := o.(map[string]any); ok && result["status_code"] != nil {
+				delete(result, "Content-Length")
+				delete(result, "Date")
+			}
+			assert.Equal(t, tt.want, o)
+		})
+	}
+}
+
+func TestOAuth2AuthorizationCode(t *testing.T) {
+	refreshes := &atomic.Int32{}
+	challenges := map[string]string{}
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Form.Get("grant_type") {
+		case "authorization_code":
+			hash := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+			if challenges[r.Form.Get("code")] != base64.RawURLEncoding.EncodeToString(hash[:]) || r.Form.Get("redirect_uri") != "https://grafana.example.com/callback" {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"access_token":"access-0","refresh_token":"my-refresh-token","token_type":"Bearer","expires_in":3600}`))
+		case "refresh_token":
+			if r.Form.Get("refresh_token") != "my-refresh-token" {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+				return
+			}
+			// tokens expiring within the expiry delta of the oauth2 client are refreshed before every request
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"access_token":"access-%d","token_type":"Bearer","expires_in":1}`, refreshes.Add(1))))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer tokenServer.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(fmt.Sprintf(`{"authorization":%q}`, r.Header.Get("Authorization"))))
+	}))
+	defer server.Close()
+	getSettings := func(refreshToken string) models.InfinitySettings {
+		return models.InfinitySettings{
+			AuthenticationMethod: models.AuthenticationMethodOAuth,
+			TimeoutInSeconds:     10,
+			OAuth2Settings: models.OAuth2Settings{
+				OAuth2Type:   models.AuthOAuthAuthorizationCode,
+				ClientID:     "my-client",
+				ClientSecret: "my-secret",
+				AuthURL:      "https://auth.example.com/authorize",
+				TokenURL:     tokenServer.URL,
+				RedirectURL:  "https://grafana.example.com/callback",
+				Scopes:       []string{"read", "offline_access"},
+				RefreshToken: refreshToken,
+			},
+		}
+	}
+	query := models.Query{URL: server.URL, Type: models.QueryTypeJSON}
+	t.Run("requests should use the access tokens refreshed with the stored refresh token", func(t *testing.T) {
+		refreshes.Store(0)
+		client, err := infinity.NewClient(context.Background(), getSettings("my-refresh-token"))
+		require.NoError(t, err)
+		o, _, _, _, _, err := client.GetResults(context.Background(), query, map[string]string{})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"authorization": "Bearer access-1"}, o)
+		o, _, _, _, _, err = client.GetResults(context.Background(), query, map[string]string{})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"authorization": "Bearer access-2"}, o)
+	})
+	t.Run("requests should fail until the datasource is authorized", func(t *testing.T) {
+		client, err := infinity.NewClient(context.Background(), getSettings(""))
+		require.NoError(t, err)
+		_, _, _, _, _, err = client.GetResults(context.Background(), query, map[string]string{})
+		require.ErrorIs(t, err, infinity.ErrOAuth2NotAuthorized)
+	})
+	t.Run("authorization code should be exchanged for a refresh token", func(t *testing.T) {
+		client, err := infinity.NewClient(context.Background(), getSettings(""))
+		require.NoError(t, err)
+		authURL, state, err := client.GetOAuth2AuthorizationURL(context.Background())
+		require.NoError(t, err)
+		u, err := url.Parse(authURL)
+		require.NoError(t, err)
+		assert.Equal(t, "auth.example.com", u.Host)
+		assert.Equal(t, state, u.Query().Get("state"))
+		assert.Equal(t, "code", u.Query().Get("response_type"))
+		assert.Equal(t, "my-client", u.Query().Get("client_id"))
+		assert.Equal(t, "read offline_access", u.Query().Get("scope"))
+		assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+		challenges["my-code"] = u.Query().Get("code_challenge")
+		_, err = client.ExchangeOAuth2AuthorizationCode(context.Background(), "my-code", "unknown-state")
+		require.ErrorContains(t, err, "invalid or expired oauth2 authorization state")
+		token, err := client.ExchangeOAuth2AuthorizationCode(context.Background(), "my-code", state)
+		require.NoError(t, err)
+		assert.Equal(t, "my-refresh-token", token.RefreshToken)
+		_, err = client.ExchangeOAuth2AuthorizationCode(context.Background(), "my-code", state)
+		require.ErrorContains(t, err, "invalid or expired oauth2 authorization state", "the state should only be used once")
+	})
+	t.Run("invalid authorization codes should be rejected", func(t *testing.T) {
+		client, err := infinity.NewClient(context.Background(), getSettings(""))
+		require.NoError(t, err)
+		_, state, err := client.GetOAuth2AuthorizationURL(context.Background())
+		require.NoError(t, err)
+		_, err = client.ExchangeOAuth2AuthorizationCode(context.Background(), "another-code", state)
+		require.ErrorContains(t, err, "error exchanging the oauth2 authorization code")
+	})
+}
+
+func TestTokenExchange(t *testing.T) {
+	exchanges := &atomic.Int32{}
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		exchanges.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		subjectToken := r.Form.Get("subject_token")
+		switch r.Form.Get("grant_type") {
+		case "urn:ietf:params:oauth:grant-type:token-exchange":
+			assert.Equal(t, "urn:ietf:params:oauth:token-type:access_token", r.Form.Get("subject_token_type"))
+			assert.Equal(t, "urn:ietf:params:oauth:token-type:access_token", r.Form.Get("requested_token_type"))
+			assert.Equal(t, "https://api.example.com", r.Form.Get("audience"))
+			assert.Equal(t, "read write", r.Form.Get("scope"))
+			assert.Equal(t, "my-client", r.Form.Get("client_id"))
+		case "urn:ietf:params:oauth:grant-type:jwt-bearer":
+			assert.Equal(t, "on_behalf_of", r.Form.Get("requested_token_use"))
+			assert.Equal(t, "my-secret", r.Form.Get("client_secret"))
+			assert.Equal(t, "api://my-api/.default", r.Form.Get("scope"))
+			subjectToken = r.Form.Get("assertion")
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if subjectToken == "expired-user" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant","error_description":"the subject token is expired"}`))
+			return
+		}
+		_, _ = w.Write([]byte(fmt.Sprintf(`{"access_token":"exchanged-%s","issued_token_type":"urn:ietf:params:oauth:token-type:access_token","token_type":"Bearer","expires_in":3600}`, subjectToken)))
+	}))
+	defer tokenServer.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(fmt.Sprintf(`{"authorization":%q,"id_token":%q}`, r.Header.Get("Authorization"), r.Header.Get("X-ID-Token"))))
+	}))
+	defer server.Close()
+	getSettings := func(tokenExchange models.TokenExchangeSettings) models.InfinitySettings {
+		tokenExchange.TokenURL = tokenServer.URL
+		return models.InfinitySettings{
+			AuthenticationMethod: models.AuthenticationMethodForwardOauth,
+			ForwardOauthIdentity: true,
+			TimeoutInSeconds:     10,
+			TokenExchange:        tokenExchange,
+		}
+	}
+	query := models.Query{URL: server.URL, Type: models.QueryTypeJSON}
+	userHeaders := func(user string) map[string]string {
+		return map[string]string{"Authorization": "Bearer " + user, "X-ID-Token": "id-" + user}
+	}
+	t.Run("forwarded user tokens should be exchanged and cached per user", func(t *testing.T) {
+		exchanges.Store(0)
+		client, err := infinity.NewClient(context.Background(), getSettings(models.TokenExchangeSettings{Type: models.TokenExchangeTypeRFC8693, ClientID: "my-client", Audience: "https://api.example.com", Scopes: []string{"read", "write"}}))
+		require.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			o, _, _, _, _, err := client.GetResults(context.Background(), query, userHeaders("user-a"))
+			require.NoError(t, err)
+			assert.Equal(t, map[string]any{"authorization": "Bearer exchanged-user-a", "id_token": ""}, o)
+		}
+		o, _, _, _, _, err := client.GetResults(context.Background(), query, userHeaders("user-b"))
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"authorization": "Bearer exchanged-user-b", "id_token": ""}, o)
+		assert.Equal(t, int32(2), exchanges.Load(), "the token of every user should be exchanged once")
+	})
+	t.Run("azure on-behalf-of flow should exchange the forwarded user token", func(t *testing.T) {
+		client, err := infinity.NewClient(context.Background(), getSettings(models.TokenExchangeSettings{Type: models.TokenExchangeTypeAzureOnBehalfOf, ClientID: "my-client", ClientSecret: "my-secret", Scopes: []string{"api://my-api/.default"}}))
+		require.NoError(t, err)
+		o, _, _, _, _, err := client.GetResults(context.Background(), query, userHeaders("user-a"))
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"authorization": "Bearer exchanged-user-a", "id_token": ""}, o)
+	})
+	t.Run("rejected exchanges should fail with a clear error", func(t *testing.T) {
+		client, err := infinity.NewClient(context.Background(), getSettings(models.TokenExchangeSettings{Type: models.TokenExchangeTypeRFC8693, ClientID: "my-client", Audience: "https://api.example.com", Scopes: []string{"read", "write"}}))
+		require.NoError(t, err)
+		_, statusCode, _, _, _, err := client.GetResults(context.Background(), query, userHeaders("expired-user"))
+		require.ErrorIs(t, err, infinity.ErrTokenExchangeRejected)
+		require.ErrorContains(t, err, "invalid_grant the subject token is expired")
+		assert.Equal(t, http.StatusUnauthorized, statusCode)
+	})
+}
+
+func TestOAuth2ClientAuthentication(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(fmt.Sprintf(`{"authorization":%q}`, r.Header.Get("Authorization"))))
+	}))
+	defer server.Close()
+	query := models.Query{URL: server.URL, Type: models.QueryTypeJSON}
+	t.Run("private_key_jwt should authenticate the client with a signed assertion", func(t *testing.T) {
+		assertions := map[string]bool{}
+		tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "client_credentials", r.Form.Get("grant_type"))
+			assert.Equal(t, "my-client", r.Form.Get("client_id"))
+			assert.Equal(t, "", r.Form.Get("client_secret"))
+			assert.Equal(t, "", r.Header.Get("Authorization"))
+			assert.Equal(t, "urn:ietf:params:oauth:client-assertion-type:jwt-bearer", r.Form.Get("client_assertion_type"))
+			claims := jwt.RegisteredClaims{}
+			token, err := jwt.ParseWithClaims(r.Form.Get("client_assertion"), &claims, func(token *jwt.Token) (any, error) {
+				return &key.PublicKey, nil
+			}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithAudience("https://idp.example.com"), jwt.WithIssuer("my-client"), jwt.WithSubject("my-client"))
+			if err != nil || assertions[claims.ID] {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			assert.Equal(t, "my-key", token.Header["kid"])
+			assertions[claims.ID] = true
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"access_token":"access-%d","token_type":"Bearer","expires_in":1}`, len(assertions))))
+		}))
+		defer tokenServer.Close()
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{
+			AuthenticationMethod: models.AuthenticationMethodOAuth,
+			TimeoutInSeconds:     10,
+			OAuth2Settings: models.OAuth2Settings{
+				OAuth2Type:              models.AuthOAuthTypeClientCredentials,
+				ClientAuthMethod:        models.OAuth2ClientAuthPrivateKeyJWT,
+				ClientID:                "my-client",
+				ClientSecret:            "unused-secret",
+				TokenURL:                tokenServer.URL,
+				ClientAssertionKeyID:    "my-key",
+				ClientAssertionAudience: "https://idp.example.com",
+				ClientAssertionKey:      keyPEM,
+			},
+		})
+		require.NoError(t, err)
+		o, _, _, _, _, err := client.GetResults(context.Background(), query, map[string]string{})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"authorization": "Bearer access-1"}, o)
+		// tokens expiring within the expiry delta of the oauth2 client are requested again with a new assertion
+		o, _, _, _, _, err = client.GetResults(context.Background(), query, map[string]string{})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"authorization": "Bearer access-2"}, o)
+	})
+	t.Run("private_key_jwt should fail with an invalid key", func(t *testing.T) {
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{
+			AuthenticationMethod: models.AuthenticationMethodOAuth,
+			TimeoutInSeconds:     10,
+			OAuth2Settings: models.OAuth2Settings{
+				OAuth2Type:         models.AuthOAuthTypeClientCredentials,
+				ClientAuthMethod:   models.OAuth2ClientAuthPrivateKeyJWT,
+				ClientID:           "my-client",
+				TokenURL:           server.URL,
+				ClientAssertionKey: "not a key",
+			},
+		})
+		require.NoError(t, err)
+		_, _, _, _, _, err = client.GetResults(context.Background(), query, map[string]string{})
+		require.ErrorIs(t, err, infinity.ErrInvalidClientAssertionKey)
+	})
+	t.Run("tls_client_auth should authenticate the client with the tls client certificate", func(t *testing.T) {
+		template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "my-client"}, NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour), ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}
+		certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		require.NoError(t, err)
+		tokenServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "my-client", r.Form.Get("client_id"))
+			assert.Equal(t, "", r.Form.Get("client_secret"))
+			assert.Equal(t, "", r.Header.Get("Authorization"))
+			if len(r.TLS.PeerCertificates) == 0 || r.TLS.PeerCertificates[0].Subject.CommonName != "my-client" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token":"mtls-access","token_type":"Bearer","expires_in":3600}`))
+		}))
+		tokenServer.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+		tokenServer.StartTLS()
+		defer tokenServer.Close()
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{
+			AuthenticationMethod: models.AuthenticationMethodOAuth,
+			TimeoutInSeconds:     10,
+			InsecureSkipVerify:   true,
+			TLSClientAuth:        true,
+			TLSClientCert:        string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})),
+			TLSClientKey:         keyPEM,
+			OAuth2Settings: models.OAuth2Settings{
+				OAuth2Type:       models.AuthOAuthTypeClientCredentials,
+				ClientAuthMethod: models.OAuth2ClientAuthTLS,
+				ClientID:         "my-client",
+				TokenURL:         tokenServer.URL,
+			},
+		})
+		require.NoError(t, err)
+		o, _, _, _, _, err := client.GetResults(context.Background(), query, map[string]string{})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"authorization": "Bearer mtls-access"}, o)
+	})
+}
+
+func TestLoginTokenAuth(t *testing.T) {
+	logins := &atomic.Int32{}
+	validToken := &atomic.Value{}
+	validToken.Store("")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			body := map[string]string{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			if body["username"] != "admin" || body["password"] != `my"password` {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			token := fmt.Sprintf("token-%d", logins.Add(1))
+			validToken.Store(token)
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"data":{"session":%q,"expires_in":3600}}`, token)))
+		default:
+			if r.Header.Get("X-Session") != "Token "+validToken.Load().(string) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"session":%q}`, r.Header.Get("X-Session"))))
+		}
+	}))
+	defer server.Close()
+	getSettings := func(password string) models.InfinitySettings {
+		return models.InfinitySettings{
+			AuthenticationMethod: models.AuthenticationMethodLoginToken,
+			TimeoutInSeconds:     10,
+			LoginToken: models.LoginTokenSettings{
+				URL:            server.URL + "/login",
+				Body:           `{"username":"admin","password":"{{password}}"}`,
+				TokenSelector:  "data.session",
+				ExpirySelector: "data.expires_in",
+				HeaderName:     "X-Session",
+				HeaderTemplate: "Token {{token}}",
+				Secrets:        map[string]string{"password": password},
+			},
+		}
+	}
+	query := models.Query{URL: server.URL + "/api", Type: models.QueryTypeJSON}
+	t.Run("session token should be cached and sent with the header template", func(t *testing.T) {
+		logins.Store(0)
+		client, err := infinity.NewClient(context.Background(), getSettings(`my"password`))
+		require.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			o, _, _, _, _, err := client.GetResults(context.Background(), query, map[string]string{})
+			require.NoError(t, err)
+			assert.Equal(t, map[string]any{"session": "Token token-1"}, o)
+		}
+		assert.Equal(t, int32(1), logins.Load())
+	})
+	t.Run("rejected session token should be renewed by logging in again", func(t *testing.T) {
+		logins.Store(0)
+		client, err := infinity.NewClient(context.Background(), getSettings(`my"password`))
+		require.NoError(t, err)
+		o, _, _, _, _, err := client.GetResults(context.Background(), query, map[string]string{})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"session": "Token token-1"}, o)
+		// the session ends on the server side before the token expires
+		validToken.Store("")
+		o, _, _, _, _, err = client.GetResults(context.Background(), query, map[string]string{})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"session": "Token token-2"}, o)
+		assert.Equal(t, int32(2), logins.Load())
+	})
+	t.Run("failed login should fail the request", func(t *testing.T) {
+		client, err := infinity.NewClient(context.Background(), getSettings("wrong-password"))
+		require.NoError(t, err)
+		_, statusCode, _, _, _, err := client.GetResults(context.Background(), query, map[string]string{})
+		require.ErrorIs(t, err, infinity.ErrLoginFailed)
+		assert.Equal(t, http.StatusUnauthorized, statusCode)
+	})
+}
+
+func TestAWSAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(fmt.Sprintf(`{"authorization":%q,"token":%q}`, r.Header.Get("Authorization"), r.Header.Get("X-Amz-Security-Token"))))
+	}))
+	defer server.Close()
+	query := models.Query{URL: server.URL + "/api", Type: models.QueryTypeJSON}
+	getResult := func(t *testing.T, settings models.InfinitySettings) map[string]any {
+		t.Helper()
+		client, err := infinity.NewClient(context.Background(), settings)
+		require.NoError(t, err)
+		o, _, _, _, _, err := client.GetResults(context.Background(), query, map[string]string{})
+		require.NoError(t, err)
+		return o.(map[string]any)
+	}
+	t.Run("keys with session token should sign the request", func(t *testing.T) {
+		o := getResult(t, models.InfinitySettings{
+			AuthenticationMethod: models.AuthenticationMethodAWS,
+			AWSSettings:          models.AWSSettings{AuthType: models.AWSAuthTypeKeys, Region: "eu-west-1", Service: "execute-api"},
+			AWSAccessKey:         "AKIDKEYS",
+			AWSSecretKey:         "secret",
+			AWSSessionToken:      "session-token",
+		})
+		assert.Regexp(t, `^AWS4-HMAC-SHA256 Credential=AKIDKEYS/\d{8}/eu-west-1/execute-api/aws4_request`, o["authorization"])
+		assert.Equal(t, "session-token", o["token"])
+	})
+	t.Run("default credential chain should use the credentials of the environment", func(t *testing.T) {
+		t.Setenv("AWS_ACCESS_KEY_ID", "AKIDENV")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+		t.Setenv("AWS_SESSION_TOKEN", "env-session-token")
+		t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+		o := getResult(t, models.InfinitySettings{
+			AuthenticationMethod: models.AuthenticationMethodAWS,
+			AWSSettings:          models.AWSSettings{AuthType: models.AWSAuthTypeDefault, Region: "us-west-2"},
+		})
+		assert.Regexp(t, `^AWS4-HMAC-SHA256 Credential=AKIDENV/\d{8}/us-west-2/monitoring/aws4_request`, o["authorization"])
+		assert.Equal(t, "env-session-token", o["token"])
+	})
+	t.Run("signed requests should go through the transports of the client", func(t *testing.T) {
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{
+			AuthenticationMethod: models.AuthenticationMethodAWS,
+			AWSSettings:          models.AWSSettings{AuthType: models.AWSAuthTypeKeys},
+			AWSAccessKey:         "AKIDKEYS",
+			AWSSecretKey:         "secret",
+		})
+		require.NoError(t, err)
+		transport, ok := client.HttpClient.Transport.(*infinity.AWSSigV4Transport)
+		require.True(t, ok)
+		assert.IsType(t, &infinity.RetryTransport{}, transport.Base, "the retry and rate limit transports must not be created twice")
+	})
+	t.Run("health check should not show the access key when the identity can't be retrieved", func(t *testing.T) {
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{
+			AuthenticationMethod: models.AuthenticationMethodAWS,
+			AllowedHosts:         []string{server.URL},
+			AWSSettings:          models.AWSSettings{AuthType: models.AWSAuthTypeKeys, Region: "eu-west-1"},
+			AWSAccessKey:         "AKIDKEYS",
+			AWSSecretKey:         "secret",
+		})
+		require.NoError(t, err)
+		res, err := pluginhost.CheckHealth(context.Background(), client, &backend.CheckHealthRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, backend.HealthStatusOk, res.Status)
+		assert.Contains(t, res.Message, "credentials provided by StaticProvider")
+		assert.NotContains(t, res.Message, "AKIDKEYS")
+	})
+	t.Run("web identity without token file should fail the request", func(t *testing.T) {
+		t.Setenv("AWS_ROLE_ARN", "")
+		t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "")
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{
+			AuthenticationMethod: models.AuthenticationMethodAWS,
+			AWSSettings:          models.AWSSettings{AuthType: models.AWSAuthTypeWebIdentity},
+		})
+		require.NoError(t, err)
+		_, statusCode, _, _, _, err := client.GetResults(context.Background(), query, map[string]string{})
+		require.ErrorIs(t, err, infinity.ErrAWSCredentials)
+		assert.Equal(t, http.StatusUnauthorized, statusCode)
+	})
+}
@@ -0,0 +1,102 @@
+package infinity
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+type coalescedCall struct {
+	done    chan struct{}
+	res     *http.Response
+	body    []byte
+	readErr error
+	err     error
+}
+
+// response returns a copy of the shared response, so that every caller can read the body and headers independently
+func (c *coalescedCall) response() *http.Response {
+	if c.res == nil {
+		return nil
+	}
+	res := *c.res
+	res.Header = c.res.Header.Clone()
+	var body io.Reader = bytes.NewReader(c.body)
+	if c.readErr != nil {
+		body = io.MultiReader(body, &errorReader{err: c.readErr})
+	}
+	res.Body = io.NopCloser(body)
+	return &res
+}
+
+type errorReader struct {
+	err error
+}
+
+func (r *errorReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// RequestCoalescer collapses identical requests of a datasource instance which are in flight at the same time into a single upstream request.
+// Unlike the response cache, nothing is kept once the request completes.
+type RequestCoalescer struct {
+	mu       sync.Mutex
+	inflight map[string]*coalescedCall
+}
+
+func NewRequestCoalescer() *RequestCoalescer {
+	return &RequestCoalescer{inflight: map[string]*coalescedCall{}}
+}
+
+// Do sends the request or waits for the identical request already in flight and shares its response.
+// Requests with an empty key are always sent. Waiting for a response respects the context cancellation of the request.
+func (c *RequestCoalescer) Do(httpClient *http.Client, req *http.Request, key string) (res *http.Response, shared bool, err error) {
+	if c == nil || key == "" {
+		res, err = httpClient.Do(req)
+		return res, false, err
+	}
+	ctx := req.Context()
+	for {
+		c.mu.Lock()
+		if call, ok := c.inflight[key]; ok {
+			c.mu.Unlock()
+			select {
+			case <-call.done:
+			case <-ctx.Done():
+				return nil, false, ctx.Err()
+			}
+			// the caller which sent the request went away. try again with the current context
+			if call.res == nil && (errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded)) && ctx.Err() == nil {
+				continue
+			}
+			return call.response(), true, call.err
+		}
+		call := &coalescedCall{done: make(chan struct{})}
+		c.inflight[key] = call
+		c.mu.Unlock()
+
+		call.res, call.err = httpClient.Do(req)
+		if call.res != nil {
+			call.body, call.readErr = io.ReadAll(call.res.Body)
+			call.res.Body.Close()
+		}
+		c.mu.Lock()
+		delete(c.inflight, key)
+		c.mu.Unlock()
+		close(call.done)
+		return call.response(), false, call.err
+	}
+}
+
+// getCoalescingKey returns the key used to coalesce the request or an empty key when the request must always be sent.
+// Only GET and HEAD requests are coalesced as other methods are not guaranteed to be safe to share. The key includes
+// all the headers of the request, so requests sent with the credentials of different users are never coalesced.
+func getCoalescingKey(req *http.Request) string {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return ""
+	}
+	return getCacheKey(req)
+}
@@ -0,0 +1,75 @@
+package infinity
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/models"
+)
+
+// blockedNetworks are the ranges rejected when private networks are blocked, on top of the private, loopback and link-local checks of net.IP
+var blockedNetworks = func() (networks []*net.IPNet) {
+	for _, network := range []string{
+		"0.0.0.0/8",     // current network
+		"100.64.0.0/10", // carrier grade NAT. also hosts the metadata endpoint of some cloud providers
+		"192.0.0.0/24",  // IETF protocol assignments
+		"198.18.0.0/15", // benchmarking
+		"64:ff9b::/96",  // NAT64 prefix embedding IPv4 addresses
+	} {
+		_, cidr, _ := net.ParseCIDR(network)
+		networks = append(networks, cidr)
+	}
+	return networks
+}()
+
+// isBlockedPrivateIP returns true when the ip is in a private, loopback, link-local or metadata range and not in the exceptions
+func isBlockedPrivateIP(ip net.IP, exceptions []*net.IPNet) bool {
+	for _, exception := range exceptions {
+		if exception.Contains(ip) {
+			return false
+		}
+	}
+	if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// getDialContext returns the dial function of the datasource transport.
+// When private networks are blocked, the resolved address is checked right before connecting so DNS rebinding can't bypass the check.
+// When a proxy is used, the address of the proxy is checked as the destination is resolved by the proxy.
+func getDialContext(settings models.InfinitySettings) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if !settings.BlockPrivateNetworks {
+		return dialer.DialContext
+	}
+	exceptions := []*net.IPNet{}
+	for _, exception := range settings.PrivateNetworkExceptions {
+		if cidr, err := models.ParseIPOrCIDR(exception); err == nil {
+			exceptions = append(exceptions, cidr)
+		}
+	}
+	dialer.Control = func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return fmt.Errorf("%w. invalid address %s", ErrPrivateNetworkBlocked, address)
+		}
+		if isBlockedPrivateIP(ip, exceptions) {
+			return fmt.Errorf("%w. blocked address: %s", ErrPrivateNetworkBlocked, ip.String())
+		}
+		return nil
+	}
+	return dialer.DialContext
+}
@@ -0,0 +1,104 @@
+package infinity
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/experimental/errorsource"
+)
+
+// EgressPolicy decides which URLs a datasource is allowed to reach.
+// Every outgoing request of the datasource, including the token requests of the authentication methods, is checked against it.
+type EgressPolicy struct {
+	AllowedHosts []string
+	// TokenURLs are the authentication endpoints configured by the datasource admin. They are always allowed
+	TokenURLs []string
+}
+
+// NewEgressPolicy returns the egress policy of the datasource. Without allowed hosts every URL is allowed
+func NewEgressPolicy(settings models.InfinitySettings) *EgressPolicy {
+	policy := &EgressPolicy{AllowedHosts: settings.AllowedHosts}
+	if IsOAuthCredentialsConfigured(settings) || IsOAuthJWTConfigured(settings) || IsOAuthAuthorizationCodeConfigured(settings) {
+		if tokenURL := strings.TrimSpace(settings.OAuth2Settings.TokenURL); tokenURL != "" {
+			policy.TokenURLs = append(policy.TokenURLs, tokenURL)
+		}
+	}
+	if IsTokenExchangeConfigured(settings) {
+		if tokenURL := strings.TrimSpace(settings.TokenExchange.TokenURL); tokenURL != "" {
+			policy.TokenURLs = append(policy.TokenURLs, tokenURL)
+		}
+	}
+	if IsLoginTokenAuthConfigured(settings) {
+		if loginURL := strings.TrimSpace(settings.LoginToken.URL); loginURL != "" {
+			policy.TokenURLs = append(policy.TokenURLs, loginURL)
+		}
+	}
+	if IsAzureManagedIdentityConfigured(settings) {
+		policy.TokenURLs = append(policy.TokenURLs, getMSIEndpoint())
+	}
+	if IsAzureCredentialConfigured(settings) {
+		policy.TokenURLs = append(policy.TokenURLs, getAzureAuthorityHost())
+	}
+	return policy
+}
+
+// Match returns the allowed host rule or the token endpoint matching the url.
+// The rule is empty when no allowed hosts are configured. A downstream error is returned when the url is not allowed.
+func (p *EgressPolicy) Match(u *url.URL) (rule string, err error) {
+	if p == nil || u == nil {
+		return "", nil
+	}
+	if rule, ok := models.MatchAllowedHosts(u, p.AllowedHosts); ok {
+		return rule, nil
+	}
+	for _, tokenURL := range p.TokenURLs {
+		t, err := url.Parse(tokenURL)
+		if err != nil {
+			continue
+		}
+		// token endpoints only allow their own path. query parameters of the configured url are ignored
+		if _, ok := models.MatchAllowedHosts(u, []string{fmt.Sprintf("%s://%s%s", t.Scheme, t.Host, t.Path)}); ok {
+			return tokenURL, nil
+		}
+	}
+	return "", errorsource.DownstreamError(fmt.Errorf("%w. blocked host: %s", ErrEgressNotAllowed, u.Host), false)
+}
+
+// Check returns a downstream error when the url is not allowed by the policy
+func (p *EgressPolicy) Check(u *url.URL) error {
+	_, err := p.Match(u)
+	return err
+}
+
+// EgressTransport rejects the requests not allowed by the egress policy before they leave the plugin.
+// As the http client runs every redirect through the transport, redirects to other hosts are checked as well.
+type EgressTransport struct {
+	Base   http.RoundTripper
+	Policy *EgressPolicy
+}
+
+func (t *EgressTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.Policy.Check(req.URL); err != nil {
+		backend.Logger.FromContext(req.Context()).Error("url is not in the allowed list. make sure to match the base URL with the settings", "host", req.URL.Host, "url_path", req.URL.Path)
+		return nil, err
+	}
+	return t.Base.RoundTrip(req)
+}
+
+// getTokenHTTPClient returns the http client used to fetch the azure tokens and to exchange the user tokens
+func getTokenHTTPClient(settings models.InfinitySettings) *http.Client {
+	return &http.Client{Transport: &EgressTransport{Base: http.DefaultTransport, Policy: NewEgressPolicy(settings)}}
+}
+
+// getAzureAuthorityHost returns the Microsoft Entra authority used by azidentity. AZURE_AUTHORITY_HOST overrides it the same way azidentity does.
+func getAzureAuthorityHost() string {
+	if host := strings.TrimSpace(os.Getenv("AZURE_AUTHORITY_HOST")); host != "" {
+		return host
+	}
+	return "https://login.microsoftonline.com/"
+}
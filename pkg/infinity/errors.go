@@ -5,4 +5,14 @@ import "errors"
 var (
 	ErrUnsuccessfulHTTPResponseStatus error = errors.New("unsuccessful HTTP response")
 	ErrParsingResponseBodyAsJson      error = errors.New("unable to parse response body as JSON")
+	ErrPrivateNetworkBlocked          error = errors.New("requested address is in a private, loopback, link-local or metadata network range. Connections to these ranges are blocked by the datasource config Security -> Block private networks section")
+	ErrEgressNotAllowed               error = errors.New("requested URL is not allowed. To allow this URL, update the datasource config Security -> Allowed Hosts section")
+	ErrMethodNotAllowed               error = errors.New("requested method is not allowed. To allow this method, update the datasource config Security -> Allowed methods section")
+	ErrOAuth2NotAuthorized            error = errors.New("oauth2 authorization is not completed. To authorize, connect the datasource from the datasource config Authentication section")
+	ErrInvalidClientAssertionKey      error = errors.New("invalid oauth2 client assertion key")
+	ErrAWSCredentials                 error = errors.New("failed to get the AWS credentials. Check the datasource config Authentication -> AWS section")
+	ErrLoginFailed                    error = errors.New("login request failed. Check the datasource config Authentication -> Login section")
+	ErrTokenExchangeRejected          error = errors.New("the token endpoint rejected the exchange of the user token. Check the datasource config Authentication -> Token exchange section and the permissions of the user")
+	ErrTokenExchangeNoUserToken       error = errors.New("no user token to exchange. Make sure the user is signed in to Grafana with OAuth")
+	ErrRateLimitedLocally             error = errors.New("rate limited locally")
 )
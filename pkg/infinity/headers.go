@@ -44,6 +44,9 @@ import (
 // Define the default resource for Azure
 const defaultResource = "https://management.azure.com/"
 
+// azureTokenTimeout is the maximum time spent fetching a single Azure token
+const azureTokenTimeout = 5 * time.Second
+
 const dummyHeader = "xxxxxxxx"
 
 const (
@@ -72,9 +75,9 @@ func ApplyAcceptHeader(query models.Query, settings models.InfinitySettings, req
 	return req
 }
 
-// ApplyContentTypeHeader sets the Content-Type header for POST requests.
+// ApplyContentTypeHeader sets the Content-Type header for POST, PUT and PATCH requests.
 func ApplyContentTypeHeader(query models.Query, settings models.InfinitySettings, req *http.Request, includeSect bool) *http.Request {
-	if strings.ToUpper(query.URLOptions.Method) == http.MethodPost {
+	if query.URLOptions.HasBody() {
 		switch query.URLOptions.BodyType {
 		case "raw":
 			if query.URLOptions.BodyContentType != "" {
@@ -191,94 +194,143 @@ func ApplyForwardedOAuthIdentity(requestHeaders map[string]string, settings mode
 
 // Retrieve Azure token using MSI or fallback to Azure CLI credentials.
 // Added by Syncfish Pty Ltd © 2024. Licensed under the Apache License, Version 2.0.
-func getToken() (string, error) {
-	// Set up a timeout context for MSI token retrieval
-	timeoutCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// azureResourceAndScope returns the IMDS resource and the azidentity scope for the configured resource/scope
+func azureResourceAndScope(configuredResource string) (resource string, scope string) {
+	resource = strings.TrimSpace(configuredResource)
+	if resource == "" {
+		resource = defaultResource
+	}
+	resource = strings.TrimSuffix(resource, "/.default")
+	scope = resource
+	if !strings.HasSuffix(scope, "/") {
+		scope += "/"
+	}
+	return resource, scope + ".default"
+}
+
+func getToken(ctx context.Context, httpClient *http.Client, settings models.AzureManagedIdentitySettings) (AzureToken, error) {
+	// Set up a timeout context for token retrieval. Cancelling the query also cancels the token retrieval
+	timeoutCtx, cancel := context.WithTimeout(ctx, azureTokenTimeout)
 	defer cancel()
+	logger := backend.Logger.FromContext(ctx)
 
 	// Check if the app mode is not 'development' to use MSI
 	if os.Getenv("GF_DEFAULT_APP_MODE") != "development" {
 		// Try fetching token from the MSI endpoint
-		token, err := getMSIToken(timeoutCtx)
+		token, err := getMSIToken(timeoutCtx, httpClient, settings)
 		if err == nil {
-			backend.Logger.FromContext(timeoutCtx).Debug("Successfully fetched MSI token.")
+			logger.Debug("Successfully fetched MSI token.")
 			return token, nil
 		}
-		backend.Logger.FromContext(timeoutCtx).Error("Failed to fetch MSI token, falling back to Azure CLI credentials.", "error", err.Error())
+		if ctx.Err() != nil {
+			return AzureToken{}, ctx.Err()
+		}
+		logger.Error("Failed to fetch MSI token, falling back to Azure CLI credentials.", "error", err.Error())
 	}
 
 	// If MSI token retrieval fails or app is in development mode, fallback to Azure CLI credentials
-	cliCred, err := azidentity.NewAzureCLICredential(nil)
+	cliCred, err := azidentity.NewAzureCLICredential(&azidentity.AzureCLICredentialOptions{TenantID: strings.TrimSpace(settings.TenantID)})
 	if err != nil {
-		return "", fmt.Errorf("failed to create Azure CLI credential: %w", err)
+		return AzureToken{}, fmt.Errorf("failed to create Azure CLI credential: %w", err)
 	}
 
 	// Retrieve the token using Azure CLI credentials
-	tokenResp, err := cliCred.GetToken(timeoutCtx, policy.TokenRequestOptions{Scopes: []string{defaultResource}})
+	_, scope := azureResourceAndScope(settings.Resource)
+	tokenResp, err := cliCred.GetToken(timeoutCtx, policy.TokenRequestOptions{Scopes: []string{scope}})
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch Azure CLI token: %w", err)
+		return AzureToken{}, fmt.Errorf("failed to fetch Azure CLI token: %w", err)
 	}
 
-	return tokenResp.Token, nil
+	return AzureToken{AccessToken: tokenResp.Token, ExpiresOn: tokenResp.ExpiresOn}, nil
+}
+
+// getMSIEndpoint returns the IMDS token endpoint. AZURE_POD_IDENTITY_AUTHORITY_HOST overrides the IMDS host the same way azidentity does.
+func getMSIEndpoint() string {
+	if host := strings.TrimSpace(os.Getenv("AZURE_POD_IDENTITY_AUTHORITY_HOST")); host != "" {
+		return strings.TrimSuffix(host, "/") + "/metadata/identity/oauth2/token"
+	}
+	return "http://169.254.169.254/metadata/identity/oauth2/token"
 }
 
 // Fetch token directly from the MSI endpoint without TenantID.
 // Added by Syncfish Pty Ltd © 2024. Licensed under the Apache License, Version 2.0.
-func getMSIToken(ctx context.Context) (string, error) {
-	msiEndpoint := "http://169.254.169.254/metadata/identity/oauth2/token"
+func getMSIToken(ctx context.Context, httpClient *http.Client, settings models.AzureManagedIdentitySettings) (AzureToken, error) {
+	msiEndpoint := getMSIEndpoint()
 	apiVersion := "2018-02-01"
 
 	// Create HTTP request to fetch MSI token
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, msiEndpoint, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request for MSI token: %w", err)
+		return AzureToken{}, fmt.Errorf("failed to create request for MSI token: %w", err)
 	}
 
 	// Set query parameters and headers for the MSI request
 	q := req.URL.Query()
 	q.Add("api-version", apiVersion)
-	q.Add("resource", defaultResource)
+	resource, _ := azureResourceAndScope(settings.Resource)
+	q.Add("resource", resource)
+	if clientID := strings.TrimSpace(settings.ClientID); clientID != "" {
+		q.Add("client_id", clientID)
+	}
+	if objectID := strings.TrimSpace(settings.ObjectID); objectID != "" {
+		q.Add("object_id", objectID)
+	}
 	req.URL.RawQuery = q.Encode()
 	req.Header.Add("Metadata", "true")
 
 	// Execute the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to get MSI token: %w", err)
+		return AzureToken{}, fmt.Errorf("failed to get MSI token: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code %d from MSI endpoint", resp.StatusCode)
+		return AzureToken{}, fmt.Errorf("unexpected status code %d from MSI endpoint", resp.StatusCode)
 	}
 
-	// Parse the response to extract the access token
+	// Parse the response to extract the access token and its expiry
 	var result struct {
-		AccessToken string `json:"access_token"`
+		AccessToken string      `json:"access_token"`
+		ExpiresOn   json.Number `json:"expires_on"`
+		ExpiresIn   json.Number `json:"expires_in"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("failed to decode MSI token response: %w", err)
+		return AzureToken{}, fmt.Errorf("failed to decode MSI token response: %w", err)
+	}
+	token := AzureToken{AccessToken: result.AccessToken, ExpiresOn: time.Now().Add(azureTokenDefaultLifetime)}
+	if expiresOn, err := result.ExpiresOn.Int64(); err == nil && expiresOn > 0 {
+		token.ExpiresOn = time.Unix(expiresOn, 0)
+	} else if expiresIn, err := result.ExpiresIn.Int64(); err == nil && expiresIn > 0 {
+		token.ExpiresOn = time.Now().Add(time.Duration(expiresIn) * time.Second)
 	}
 
-	return result.AccessToken, nil
+	return token, nil
 }
 
-// ApplyAzureManagedIdentity applies the Azure Managed Identity token to the request headers.
+// ApplyAzureManagedIdentity applies a masked Azure token to the request headers.
+// The actual token is set by the AzureTokenTransport configured in NewClient, so the token is cached per datasource.
 // Added by Syncfish Pty Ltd © 2024. Licensed under the Apache License, Version 2.0.
 func ApplyAzureManagedIdentity(requestHeaders map[string]string, settings models.InfinitySettings, req *http.Request, includeSect bool) *http.Request {
-	// Retrieve token and set Authorization header
-	logger := backend.Logger.FromContext(context.Background())
-	if settings.AuthenticationMethod == models.AuthenticationMethodManagedIdentity {
-		token, err := getToken()
-		if err != nil {
-			logger.Error("failed to get Azure token", "error", err.Error())
-			return req
-		}
-		// Ensure the Authorization header is set
-		req.Header.Add(headerKeyAuthorization, fmt.Sprintf("Bearer %s", token))
-		logger.Info("Authorization header set successfully", "Authorization", token)
+	if IsAzureTokenAuthConfigured(settings) && !includeSect {
+		req.Header.Set(headerKeyAuthorization, fmt.Sprintf("Bearer %s", dummyHeader))
 	}
-
 	return req
 }
+
+// ApplyAzureTokenAuth wraps the http client transport with the cached Azure token.
+func ApplyAzureTokenAuth(ctx context.Context, httpClient *http.Client, settings models.InfinitySettings, tokenProvider *AzureTokenProvider) *http.Client {
+	if IsAzureTokenAuthConfigured(settings) && tokenProvider != nil {
+		httpClient.Transport = &AzureTokenTransport{Base: httpClient.Transport, TokenProvider: tokenProvider}
+	}
+	return httpClient
+}
+
+func IsAzureManagedIdentityConfigured(settings models.InfinitySettings) bool {
+	return settings.AuthenticationMethod == models.AuthenticationMethodManagedIdentity
+}
+
+// IsAzureTokenAuthConfigured returns true for all the authentication methods which send an Azure bearer token
+func IsAzureTokenAuthConfigured(settings models.InfinitySettings) bool {
+	return IsAzureManagedIdentityConfigured(settings) || IsAzureCredentialConfigured(settings)
+}
@@ -0,0 +1,271 @@
+package infinity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"github.com/grafana/grafana-plugin-sdk-go/experimental/errorsource"
+	"github.com/grafana/infinity-libs/lib/go/jsonframer"
+)
+
+// loginTokenRefreshWindow is how long before expiry a login token is renewed
+const loginTokenRefreshWindow = 30 * time.Second
+
+type loginTokenCall struct {
+	done    chan struct{}
+	token   string
+	expires time.Time
+	err     error
+}
+
+// LoginTokenProvider obtains the session token by sending the login request and caches it until it expires or is rejected.
+// Concurrent logins are coalesced into a single login request.
+type LoginTokenProvider struct {
+	settings   models.LoginTokenSettings
+	httpClient *http.Client
+	mu         sync.Mutex
+	token      string
+	expires    time.Time
+	inflight   *loginTokenCall
+}
+
+func NewLoginTokenProvider(settings models.LoginTokenSettings, httpClient *http.Client) *LoginTokenProvider {
+	return &LoginTokenProvider{settings: settings, httpClient: httpClient}
+}
+
+// GetToken returns the cached session token or logs in again when there is no valid token
+func (p *LoginTokenProvider) GetToken(ctx context.Context) (string, error) {
+	for {
+		p.mu.Lock()
+		if p.token != "" && (p.expires.IsZero() || time.Now().Add(loginTokenRefreshWindow).Before(p.expires)) {
+			token := p.token
+			p.mu.Unlock()
+			return token, nil
+		}
+		if call := p.inflight; call != nil {
+			p.mu.Unlock()
+			select {
+			case <-call.done:
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+			if call.err == nil {
+				return call.token, nil
+			}
+			// the request which started the login went away. try again with the current context
+			if (errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded)) && ctx.Err() == nil {
+				continue
+			}
+			return "", call.err
+		}
+		call := &loginTokenCall{done: make(chan struct{})}
+		p.inflight = call
+		p.mu.Unlock()
+
+		call.token, call.expires, call.err = p.login(ctx)
+		p.mu.Lock()
+		p.inflight = nil
+		if call.err == nil {
+			p.token, p.expires = call.token, call.expires
+		}
+		p.mu.Unlock()
+		close(call.done)
+		return call.token, call.err
+	}
+}
+
+// Invalidate drops the cached token when it is the token rejected by the API, so that the next request logs in again
+func (p *LoginTokenProvider) Invalidate(token string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.token == token {
+		p.token, p.expires = "", time.Time{}
+	}
+}
+
+func (p *LoginTokenProvider) login(ctx context.Context) (token string, expires time.Time, err error) {
+	ctx, span := tracing.DefaultTracer().Start(ctx, "LoginTokenProvider.login")
+	defer span.End()
+	var body io.Reader
+	if p.settings.GetMethod() != http.MethodGet {
+		body = strings.NewReader(p.getBody())
+	}
+	req, err := http.NewRequestWithContext(ctx, p.settings.GetMethod(), strings.TrimSpace(p.settings.URL), body)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if body != nil {
+		req.Header.Set(headerKeyContentType, p.settings.GetContentType())
+	}
+	req.Header.Set(headerKeyAccept, contentTypeJSON)
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("%w. %w", ErrLoginFailed, err)
+	}
+	defer res.Body.Close()
+	resBody, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("%w. %w", ErrLoginFailed, err)
+	}
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return "", time.Time{}, fmt.Errorf("%w. %s", ErrLoginFailed, res.Status)
+	}
+	token, err = getLoginResponseValue(string(resBody), p.settings.TokenSelector)
+	if err != nil || token == "" {
+		return "", time.Time{}, fmt.Errorf("%w. token not found in the login response using the selector %s", ErrLoginFailed, p.settings.TokenSelector)
+	}
+	if p.settings.ExpirySelector != "" {
+		value, err := getLoginResponseValue(string(resBody), p.settings.ExpirySelector)
+		if err != nil || value == "" {
+			return "", time.Time{}, fmt.Errorf("%w. expiry not found in the login response using the selector %s", ErrLoginFailed, p.settings.ExpirySelector)
+		}
+		if expires, err = parseLoginTokenExpiry(value, time.Now()); err != nil {
+			return "", time.Time{}, fmt.Errorf("%w. %w", ErrLoginFailed, err)
+		}
+		return token, expires, nil
+	}
+	if p.settings.TTLSeconds > 0 {
+		return token, time.Now().Add(time.Duration(p.settings.TTLSeconds) * time.Second), nil
+	}
+	return token, time.Time{}, nil
+}
+
+// getBody returns the login request body with the placeholders replaced by the secure values. The values are escaped for the content type
+func (p *LoginTokenProvider) getBody() string {
+	body := p.settings.Body
+	contentType := strings.ToLower(p.settings.GetContentType())
+	for name, value := range p.settings.Secrets {
+		if name == "" {
+			continue
+		}
+		switch {
+		case strings.Contains(contentType, "json"):
+			b, _ := json.Marshal(value)
+			value = strings.TrimSuffix(strings.TrimPrefix(string(b), `"`), `"`)
+		case strings.Contains(contentType, contentTypeFormURLEncoded):
+			value = url.QueryEscape(value)
+		}
+		body = strings.ReplaceAll(body, fmt.Sprintf("{{%s}}", name), value)
+	}
+	return body
+}
+
+// getLoginResponseValue returns the value selected from the login response. Selected JSON strings are unquoted
+func getLoginResponseValue(body string, selector string) (string, error) {
+	value, err := jsonframer.GetRootData(body, selector)
+	if err != nil {
+		return "", err
+	}
+	value = strings.TrimSpace(value)
+	if value == "null" {
+		return "", nil
+	}
+	if strings.HasPrefix(value, `"`) {
+		var s string
+		if err := json.Unmarshal([]byte(value), &s); err == nil {
+			return s, nil
+		}
+	}
+	return value, nil
+}
+
+// parseLoginTokenExpiry parses the expiry of the login token. Small numbers are the seconds the token is valid for,
+// larger numbers are unix timestamps in seconds or milliseconds and anything else must be a RFC 3339 date time
+func parseLoginTokenExpiry(value string, now time.Time) (time.Time, error) {
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		switch {
+		case n <= 0:
+			return time.Time{}, fmt.Errorf("invalid login token expiry %s", value)
+		case n < 1e9:
+			return now.Add(time.Duration(n * float64(time.Second))), nil
+		case n < 1e12:
+			return time.Unix(int64(n), 0), nil
+		default:
+			return time.UnixMilli(int64(n)), nil
+		}
+	}
+	expires, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid login token expiry %s. expiry must be a number of seconds, a unix timestamp or a RFC 3339 date time", value)
+	}
+	return expires, nil
+}
+
+// LoginTokenTransport sends the session token with every request and logs in again once when the API responds with 401
+type LoginTokenTransport struct {
+	Base           http.RoundTripper
+	TokenProvider  *LoginTokenProvider
+	HeaderName     string
+	HeaderTemplate string
+}
+
+func (t *LoginTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.TokenProvider.GetToken(req.Context())
+	if err != nil {
+		backend.Logger.FromContext(req.Context()).Error("failed to login", "error", err.Error())
+		return nil, errorsource.DownstreamError(err, false)
+	}
+	res, err := t.Base.RoundTrip(t.withToken(req, token))
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+	// the request can only be sent again when the body can be read again
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return res, nil
+	}
+	backend.Logger.FromContext(req.Context()).Debug("session token rejected. logging in again")
+	t.TokenProvider.Invalidate(token)
+	newToken, err := t.TokenProvider.GetToken(req.Context())
+	if err != nil {
+		backend.Logger.FromContext(req.Context()).Error("failed to login", "error", err.Error())
+		return res, nil
+	}
+	retryReq := t.withToken(req, newToken)
+	if req.GetBody != nil {
+		if retryReq.Body, err = req.GetBody(); err != nil {
+			return res, nil
+		}
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	return t.Base.RoundTrip(retryReq)
+}
+
+func (t *LoginTokenTransport) withToken(req *http.Request, token string) *http.Request {
+	req = req.Clone(req.Context())
+	req.Header.Set(t.HeaderName, strings.ReplaceAll(t.HeaderTemplate, models.LoginTokenPlaceholder, token))
+	return req
+}
+
+// ApplyLoginTokenAuth authenticates the requests with the session token obtained from the login request.
+// The login request is sent through the same egress, TLS and proxy configuration as the other requests.
+func ApplyLoginTokenAuth(ctx context.Context, httpClient *http.Client, settings models.InfinitySettings) *http.Client {
+	_, span := tracing.DefaultTracer().Start(ctx, "ApplyLoginTokenAuth")
+	defer span.End()
+	if IsLoginTokenAuthConfigured(settings) {
+		loginClient := &http.Client{Transport: httpClient.Transport, Timeout: httpClient.Timeout}
+		httpClient.Transport = &LoginTokenTransport{
+			Base:           httpClient.Transport,
+			TokenProvider:  NewLoginTokenProvider(settings.LoginToken, loginClient),
+			HeaderName:     settings.LoginToken.GetHeaderName(),
+			HeaderTemplate: settings.LoginToken.GetHeaderTemplate(),
+		}
+	}
+	return httpClient
+}
+
+func IsLoginTokenAuthConfigured(settings models.InfinitySettings) bool {
+	return settings.AuthenticationMethod == models.AuthenticationMethodLoginToken
+}
@@ -17,6 +17,8 @@ type CustomMeta struct {
 	Data                   any           `json:"data"`
 	ResponseCodeFromServer int           `json:"responseCodeFromServer"`
 	Duration               time.Duration `json:"duration"`
+	Attempts               int           `json:"attempts,omitempty"`
+	Cache                  CacheStatus   `json:"cache,omitempty"`
 	Error                  string        `json:"error"`
 }
 
@@ -6,7 +6,6 @@ import (
 	"net/url"
 	"strings"
 
-	"github.com/grafana/grafana-aws-sdk/pkg/sigv4"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
 	"github.com/icholy/digest"
 	"golang.org/x/oauth2"
@@ -39,7 +38,20 @@ func ApplyOAuthClientCredentials(ctx context.Context, httpClient *http.Client, s
 			}
 		}
 		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, httpClient)
-		httpClient = oauthConfig.Client(ctx)
+		switch settings.OAuth2Settings.GetClientAuthMethod() {
+		case models.OAuth2ClientAuthPrivateKeyJWT:
+			// the client authenticates with a signed assertion instead of the client secret
+			oauthConfig.ClientSecret = ""
+			oauthConfig.AuthStyle = oauth2.AuthStyleInParams
+			httpClient = oauth2.NewClient(ctx, &clientAssertionTokenSource{ctx: ctx, config: oauthConfig, settings: settings.OAuth2Settings})
+		case models.OAuth2ClientAuthTLS:
+			// the client authenticates with the TLS client certificate of the http client, so only the client id is sent
+			oauthConfig.ClientSecret = ""
+			oauthConfig.AuthStyle = oauth2.AuthStyleInParams
+			httpClient = oauthConfig.Client(ctx)
+		default:
+			httpClient = oauthConfig.Client(ctx)
+		}
 	}
 	return httpClient
 }
@@ -74,6 +86,28 @@ func ApplyOAuthJWT(ctx context.Context, httpClient *http.Client, settings models
 func IsOAuthJWTConfigured(settings models.InfinitySettings) bool {
 	return settings.AuthenticationMethod == models.AuthenticationMethodOAuth && settings.OAuth2Settings.OAuth2Type == models.AuthOAuthJWT
 }
+
+// ApplyOAuthAuthorizationCode authenticates the requests with the access tokens issued for the refresh token of the authorization code flow.
+// Access tokens are refreshed automatically before they expire. Refresh tokens rotated by the authorization server are only kept in memory,
+// so providers which revoke the rotated refresh tokens require connecting the datasource again once the plugin restarts.
+func ApplyOAuthAuthorizationCode(ctx context.Context, httpClient *http.Client, settings models.InfinitySettings) *http.Client {
+	_, span := tracing.DefaultTracer().Start(ctx, "ApplyOAuthAuthorizationCode")
+	defer span.End()
+	if IsOAuthAuthorizationCodeConfigured(settings) {
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, httpClient)
+		var tokenSource oauth2.TokenSource = notAuthorizedTokenSource{}
+		if settings.OAuth2Settings.RefreshToken != "" {
+			tokenSource = getOAuthAuthorizationCodeConfig(settings).TokenSource(ctx, &oauth2.Token{RefreshToken: settings.OAuth2Settings.RefreshToken})
+		}
+		httpClient = oauth2.NewClient(ctx, tokenSource)
+	}
+	return httpClient
+}
+
+func IsOAuthAuthorizationCodeConfigured(settings models.InfinitySettings) bool {
+	return settings.AuthenticationMethod == models.AuthenticationMethodOAuth && settings.OAuth2Settings.OAuth2Type == models.AuthOAuthAuthorizationCode
+}
+
 func ApplyDigestAuth(ctx context.Context, httpClient *http.Client, settings models.InfinitySettings) *http.Client {
 	_, span := tracing.DefaultTracer().Start(ctx, "ApplyDigestAuth")
 	defer span.End()
@@ -87,39 +121,3 @@ func ApplyDigestAuth(ctx context.Context, httpClient *http.Client, settings mode
 func IsDigestAuthConfigured(settings models.InfinitySettings) bool {
 	return settings.AuthenticationMethod == models.AuthenticationMethodDigestAuth
 }
-func ApplyAWSAuth(ctx context.Context, httpClient *http.Client, settings models.InfinitySettings) *http.Client {
-	ctx, span := tracing.DefaultTracer().Start(ctx, "ApplyAWSAuth")
-	defer span.End()
-	if IsAwsAuthConfigured(settings) {
-		tempHttpClient := getBaseHTTPClient(ctx, settings)
-		authType := settings.AWSSettings.AuthType
-		if authType == "" {
-			authType = models.AWSAuthTypeKeys
-		}
-		region := settings.AWSSettings.Region
-		if region == "" {
-			region = "us-east-2"
-		}
-		service := settings.AWSSettings.Service
-		if service == "" {
-			service = "monitoring"
-		}
-		conf := &sigv4.Config{
-			AuthType:  string(authType),
-			Region:    region,
-			Service:   service,
-			AccessKey: settings.AWSAccessKey,
-			SecretKey: settings.AWSSecretKey,
-		}
-		rt, _ := sigv4.New(conf, sigv4.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
-			req.Header.Add("Accept", "application/json")
-			return tempHttpClient.Do(req)
-		}))
-		httpClient.Transport = rt
-	}
-	return httpClient
-}
-
-func IsAwsAuthConfigured(settings models.InfinitySettings) bool {
-	return settings.AuthenticationMethod == models.AuthenticationMethodAWS
-}
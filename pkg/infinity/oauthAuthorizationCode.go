@@ -0,0 +1,147 @@
+package infinity
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/models"
+	"golang.org/x/oauth2"
+)
+
+// oauth2AuthorizationTimeout is how long an authorization code flow started from the datasource config can be completed
+const oauth2AuthorizationTimeout = 10 * time.Minute
+
+type notAuthorizedTokenSource struct{}
+
+func (notAuthorizedTokenSource) Token() (*oauth2.Token, error) {
+	return nil, ErrOAuth2NotAuthorized
+}
+
+func getOAuthAuthorizationCodeConfig(settings models.InfinitySettings) *oauth2.Config {
+	config := &oauth2.Config{
+		ClientID:     settings.OAuth2Settings.ClientID,
+		ClientSecret: settings.OAuth2Settings.ClientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   settings.OAuth2Settings.AuthURL,
+			TokenURL:  settings.OAuth2Settings.TokenURL,
+			AuthStyle: settings.OAuth2Settings.AuthStyle,
+		},
+		RedirectURL: settings.OAuth2Settings.RedirectURL,
+		Scopes:      []string{},
+	}
+	for _, scope := range settings.OAuth2Settings.Scopes {
+		if scope != "" {
+			config.Scopes = append(config.Scopes, scope)
+		}
+	}
+	return config
+}
+
+func getOAuthEndpointParams(settings models.InfinitySettings) []oauth2.AuthCodeOption {
+	options := []oauth2.AuthCodeOption{}
+	for k, v := range settings.OAuth2Settings.EndpointParams {
+		if k != "" && v != "" {
+			options = append(options, oauth2.SetAuthURLParam(k, v))
+		}
+	}
+	return options
+}
+
+type oauth2Authorization struct {
+	verifier string
+	expires  time.Time
+}
+
+// OAuth2Authorizations keeps the PKCE verifiers of the authorization code flows started from the datasource config until their code is exchanged
+type OAuth2Authorizations struct {
+	mu      sync.Mutex
+	pending map[string]oauth2Authorization
+}
+
+func NewOAuth2Authorizations() *OAuth2Authorizations {
+	return &OAuth2Authorizations{pending: map[string]oauth2Authorization{}}
+}
+
+func (a *OAuth2Authorizations) start() (state string, verifier string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	state, verifier = base64.RawURLEncoding.EncodeToString(b), oauth2.GenerateVerifier()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	for key, authorization := range a.pending {
+		if now.After(authorization.expires) {
+			delete(a.pending, key)
+		}
+	}
+	a.pending[state] = oauth2Authorization{verifier: verifier, expires: now.Add(oauth2AuthorizationTimeout)}
+	return state, verifier, nil
+}
+
+// complete returns the verifier of the authorization. The state can only be used once
+func (a *OAuth2Authorizations) complete(state string) (verifier string, ok bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	authorization, ok := a.pending[state]
+	delete(a.pending, state)
+	if !ok || time.Now().After(authorization.expires) {
+		return "", false
+	}
+	return authorization.verifier, true
+}
+
+// GetOAuth2AuthorizationURL starts an authorization code flow and returns the url of the authorization server the user has to visit along with the state of the flow
+func (client *Client) GetOAuth2AuthorizationURL(ctx context.Context) (authURL string, state string, err error) {
+	if !IsOAuthAuthorizationCodeConfigured(client.Settings) || client.OAuth2Authorizations == nil {
+		return "", "", errors.New("oauth2 authorization code flow is not configured. save the datasource settings before connecting")
+	}
+	state, verifier, err := client.OAuth2Authorizations.start()
+	if err != nil {
+		return "", "", err
+	}
+	options := append(getOAuthEndpointParams(client.Settings), oauth2.S256ChallengeOption(verifier))
+	return getOAuthAuthorizationCodeConfig(client.Settings).AuthCodeURL(state, options...), state, nil
+}
+
+// ExchangeOAuth2AuthorizationCode completes the authorization code flow of the state and returns the token issued for the code.
+// The refresh token of the token has to be stored in the datasource secure settings to authenticate the requests.
+func (client *Client) ExchangeOAuth2AuthorizationCode(ctx context.Context, code string, state string) (*oauth2.Token, error) {
+	if !IsOAuthAuthorizationCodeConfigured(client.Settings) || client.OAuth2Authorizations == nil {
+		return nil, errors.New("oauth2 authorization code flow is not configured. save the datasource settings before connecting")
+	}
+	verifier, ok := client.OAuth2Authorizations.complete(state)
+	if !ok {
+		return nil, errors.New("invalid or expired oauth2 authorization state. connect the datasource again")
+	}
+	// the token request uses the transport of the datasource without the oauth2 authentication
+	var httpClient *http.Client
+	if client.HttpClient != nil {
+		if transport, ok := client.HttpClient.Transport.(*oauth2.Transport); ok {
+			httpClient = &http.Client{Transport: transport.Base, Timeout: time.Second * time.Duration(client.Settings.TimeoutInSeconds)}
+		}
+	}
+	if httpClient == nil {
+		httpClient = getBaseHTTPClient(ctx, client.Settings)
+	}
+	if httpClient == nil {
+		return nil, errors.New("invalid http client")
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, httpClient)
+	options := append(getOAuthEndpointParams(client.Settings), oauth2.VerifierOption(verifier))
+	token, err := getOAuthAuthorizationCodeConfig(client.Settings).Exchange(ctx, code, options...)
+	if err != nil {
+		return nil, fmt.Errorf("error exchanging the oauth2 authorization code. %w", err)
+	}
+	if token.RefreshToken == "" {
+		return nil, errors.New("the authorization server did not issue a refresh token. make sure the scopes include the offline access scope of the provider")
+	}
+	return token, nil
+}
@@ -0,0 +1,98 @@
+package infinity
+
+import (
+	"context"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/models"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+	// clientAssertionExpiry is how long the signed client assertions are valid. A new assertion is signed for every token request
+	clientAssertionExpiry = 5 * time.Minute
+)
+
+// clientAssertionTokenSource requests the client credentials tokens with a freshly signed private_key_jwt client assertion as per RFC 7523
+type clientAssertionTokenSource struct {
+	ctx      context.Context
+	config   clientcredentials.Config
+	settings models.OAuth2Settings
+}
+
+func (s *clientAssertionTokenSource) Token() (*oauth2.Token, error) {
+	assertion, err := getClientAssertion(s.settings)
+	if err != nil {
+		return nil, err
+	}
+	config := s.config
+	config.EndpointParams = url.Values{}
+	for k, v := range s.config.EndpointParams {
+		config.EndpointParams[k] = v
+	}
+	config.EndpointParams.Set("client_assertion_type", clientAssertionType)
+	config.EndpointParams.Set("client_assertion", assertion)
+	return config.Token(s.ctx)
+}
+
+// getClientAssertion returns the client assertion signed with the client key. The audience defaults to the token url
+func getClientAssertion(settings models.OAuth2Settings) (string, error) {
+	key, method, err := getClientAssertionKey(settings.ClientAssertionKey)
+	if err != nil {
+		return "", err
+	}
+	audience := settings.ClientAssertionAudience
+	if audience == "" {
+		audience = settings.TokenURL
+	}
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	token := jwt.NewWithClaims(method, jwt.RegisteredClaims{
+		Issuer:    settings.ClientID,
+		Subject:   settings.ClientID,
+		Audience:  jwt.ClaimStrings{audience},
+		ID:        hex.EncodeToString(jti),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(clientAssertionExpiry)),
+	})
+	if settings.ClientAssertionKeyID != "" {
+		token.Header["kid"] = settings.ClientAssertionKeyID
+	}
+	return token.SignedString(key)
+}
+
+// getClientAssertionKey parses the PEM encoded RSA, ECDSA or Ed25519 client key and returns the matching signing method
+func getClientAssertionKey(pemKey string) (any, jwt.SigningMethod, error) {
+	pemKey = strings.ReplaceAll(pemKey, "\\n", "\n")
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(pemKey)); err == nil {
+		return key, jwt.SigningMethodRS256, nil
+	}
+	if key, err := jwt.ParseECPrivateKeyFromPEM([]byte(pemKey)); err == nil {
+		switch key.Curve {
+		case elliptic.P256():
+			return key, jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return key, jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return key, jwt.SigningMethodES512, nil
+		}
+		return nil, nil, fmt.Errorf("%w. unsupported elliptic curve %s", ErrInvalidClientAssertionKey, key.Curve.Params().Name)
+	}
+	if key, err := jwt.ParseEdPrivateKeyFromPEM([]byte(pemKey)); err == nil {
+		return key, jwt.SigningMethodEdDSA, nil
+	}
+	return nil, nil, fmt.Errorf("%w. the key must be a PEM encoded RSA, ECDSA or Ed25519 private key", ErrInvalidClientAssertionKey)
+}
@@ -0,0 +1,75 @@
+package infinity
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// getPartialResults returns the frames of the successful requests of a query fanning out to several requests.
+// Frames and errors are aligned by request and describe names the failed request in the errors and notices.
+// Without partial results any failed request fails the query. With partial results the failed requests are
+// reported as warning notices instead, after a notice with the number of failed requests, unless every request failed.
+// Empty frames of the partial results are dropped as they can't be merged.
+func getPartialResults(partialResults bool, frames []*data.Frame, errs []error, describe func(i int) string) (results []*data.Frame, notices []data.Notice, err error) {
+	notices = []data.Notice{}
+	results = []*data.Frame{}
+	for i, err := range errs {
+		if err != nil {
+			errs[i] = fmt.Errorf("%s. %w", describe(i), err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil && !partialResults {
+		return nil, notices, err
+	} else if err == nil {
+		for _, frame := range frames {
+			if frame != nil {
+				results = append(results, frame)
+			}
+		}
+		return results, notices, nil
+	}
+	var first *data.Frame
+	failed := 0
+	for i, frame := range frames {
+		if errs[i] != nil {
+			failed++
+			notices = append(notices, data.Notice{
+				Severity: data.NoticeSeverityWarning,
+				Text:     getFailureText(describe(i), frame, errs[i]),
+			})
+			continue
+		}
+		if frame == nil {
+			continue
+		}
+		if first == nil {
+			first = frame
+		}
+		if frame.Rows() > 0 {
+			results = append(results, frame)
+		}
+	}
+	if first == nil {
+		// every request failed
+		return nil, notices, errors.Join(errs...)
+	}
+	if len(results) == 0 {
+		results = append(results, first)
+	}
+	notices = append([]data.Notice{{
+		Severity: data.NoticeSeverityWarning,
+		Text:     fmt.Sprintf("results are incomplete. %d of %d requests failed", failed, len(frames)),
+	}}, notices...)
+	return results, notices, nil
+}
+
+func getFailureText(description string, frame *data.Frame, err error) string {
+	if frame != nil && frame.Meta != nil {
+		if customMeta, ok := frame.Meta.Custom.(*CustomMeta); ok && customMeta.ResponseCodeFromServer > 0 {
+			return fmt.Sprintf("%s. status code %d. %s", description, customMeta.ResponseCodeFromServer, errors.Unwrap(err).Error())
+		}
+	}
+	return err.Error()
+}
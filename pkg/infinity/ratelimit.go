@@ -0,0 +1,172 @@
+package infinity
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/experimental/errorsource"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// reserve takes a token from the bucket and returns how long the caller has to wait before using it.
+// Nothing is taken when the wait would exceed maxWait.
+func (b *tokenBucket) reserve(now time.Time, maxWait time.Duration) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.last.IsZero() {
+		b.tokens = b.burst
+	} else if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+	}
+	b.last = now
+	wait := time.Duration(0)
+	if b.tokens < 1 {
+		wait = time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	}
+	if wait > maxWait {
+		return wait, false
+	}
+	// tokens go negative while requests are queued for the bucket
+	b.tokens--
+	return wait, true
+}
+
+// cancel returns a token of a request which stopped waiting
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.burst, b.tokens+1)
+}
+
+type rateLimit struct {
+	settings models.RateLimitSettings
+	rule     *models.AllowedHostRule
+	burst    float64
+	maxWait  time.Duration
+}
+
+// RateLimiter holds the token buckets of a datasource instance, so that the limits are shared by all of its queries
+type RateLimiter struct {
+	limits  []rateLimit
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// NewRateLimiter returns a rate limiter for the configured limits. Invalid limits are ignored as they are reported by the settings validation
+func NewRateLimiter(settings []models.RateLimitSettings) *RateLimiter {
+	limiter := &RateLimiter{buckets: map[string]*tokenBucket{}}
+	for _, s := range settings {
+		if s.Validate() != nil {
+			continue
+		}
+		limit := rateLimit{settings: s, burst: float64(s.Burst), maxWait: time.Duration(s.MaxWaitMs) * time.Millisecond}
+		if s.Burst == 0 {
+			limit.burst = math.Ceil(s.RequestsPerSecond)
+		}
+		if models.IsRateLimitRule(s.Host) {
+			rule, _ := models.ParseAllowedHostRule(s.Host)
+			limit.rule = &rule
+		}
+		limiter.limits = append(limiter.limits, limit)
+	}
+	return limiter
+}
+
+// bucket returns the bucket of the first limit matching the url
+func (l *RateLimiter) bucket(u *url.URL) (*tokenBucket, rateLimit, bool) {
+	for i, limit := range l.limits {
+		key := ""
+		switch {
+		case limit.rule != nil:
+			if !limit.rule.Match(u) {
+				continue
+			}
+			key = fmt.Sprintf("%d", i)
+		case strings.TrimSpace(limit.settings.Host) == "*":
+			key = fmt.Sprintf("%d/%s", i, strings.ToLower(u.Hostname()))
+		case strings.EqualFold(strings.TrimSpace(limit.settings.Host), u.Hostname()):
+			key = fmt.Sprintf("%d", i)
+		default:
+			continue
+		}
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		b, ok := l.buckets[key]
+		if !ok {
+			b = &tokenBucket{rate: limit.settings.RequestsPerSecond, burst: limit.burst}
+			l.buckets[key] = b
+		}
+		return b, limit, true
+	}
+	return nil, rateLimit{}, false
+}
+
+// Wait blocks until the request to the url is allowed by the rate limits.
+// It fails without waiting when the wait would exceed the max wait of the limit or the context deadline.
+func (l *RateLimiter) Wait(ctx context.Context, u *url.URL) error {
+	if l == nil || len(l.limits) == 0 || u == nil {
+		return nil
+	}
+	b, limit, ok := l.bucket(u)
+	if !ok {
+		return nil
+	}
+	maxWait := time.Duration(math.MaxInt64)
+	if limit.maxWait > 0 {
+		maxWait = limit.maxWait
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		maxWait = min(maxWait, time.Until(deadline))
+	}
+	wait, ok := b.reserve(time.Now(), maxWait)
+	if !ok {
+		backend.Logger.FromContext(ctx).Warn("request rate limited locally", "host", u.Hostname(), "limit", limit.settings.Host, "wait_ms", wait.Milliseconds())
+		return errorsource.DownstreamError(fmt.Errorf("%w. the rate limit of %v requests per second for %s would delay the request by %s", ErrRateLimitedLocally, limit.settings.RequestsPerSecond, limit.settings.Host, wait.Round(time.Millisecond)), false)
+	}
+	if wait <= 0 {
+		return nil
+	}
+	trace.SpanFromContext(ctx).AddEvent("rate limit wait", trace.WithAttributes(
+		attribute.String("rate_limit.host", limit.settings.Host),
+		attribute.Int64("rate_limit.wait_ms", wait.Milliseconds()),
+	))
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RateLimitTransport delays the outgoing requests according to the rate limits of the datasource
+type RateLimitTransport struct {
+	Base    http.RoundTripper
+	Limiter *RateLimiter
+}
+
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.Limiter.Wait(req.Context(), req.URL); err != nil {
+		return nil, err
+	}
+	return t.Base.RoundTrip(req)
+}
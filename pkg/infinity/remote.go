@@ -5,8 +5,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 
+	xj "github.com/basgys/goxml2json"
 	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/models"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
@@ -19,7 +27,10 @@ import (
 func GetFrameForURLSources(ctx context.Context, query models.Query, infClient Client, requestHeaders map[string]string) (*data.Frame, error) {
 	ctx, span := tracing.DefaultTracer().Start(ctx, "GetFrameForURLSources")
 	defer span.End()
-	if query.Type == models.QueryTypeJSON && query.Parser == models.InfinityParserBackend && query.PageMode != models.PaginationModeNone && query.PageMode != "" {
+	if IsAzureBlobMultiQuery(query) {
+		return GetAzureBlobMergedResults(ctx, query, infClient, requestHeaders)
+	}
+	if (query.Type == models.QueryTypeJSON || query.Type == models.QueryTypeXML) && query.Parser == models.InfinityParserBackend && query.PageMode != models.PaginationModeNone && query.PageMode != "" {
 		return GetPaginatedResults(ctx, query, infClient, requestHeaders)
 	}
 	frame, _, err := GetFrameForURLSourcesWithPostProcessing(ctx, query, infClient, requestHeaders, true)
@@ -30,8 +41,10 @@ func GetPaginatedResults(ctx context.Context, query models.Query, infClient Clie
 	ctx, span := tracing.DefaultTracer().Start(ctx, "GetPaginatedResults")
 	defer span.End()
 	frames := []*data.Frame{}
+	pageErrs := []error{}
 	queries := []models.Query{}
-	var errs error
+	truncated := false
+	query.PageMaxPages = min(query.PageMaxPages, infClient.Settings.GetPaginationMaxPages())
 	switch query.PageMode {
 	case models.PaginationModeOffset:
 		for pageNumber := 1; pageNumber <= query.PageMaxPages; pageNumber++ {
@@ -62,20 +75,25 @@ func GetPaginatedResults(ctx context.Context, query models.Query, infClient Clie
 			currentQuery = ApplyPaginationItemToQuery(currentQuery, query.PageParamListFieldType, query.PageParamListFieldName, strings.TrimSpace(listItem))
 			queries = append(queries, currentQuery)
 		}
-	case models.PaginationModeCursor:
+	case models.PaginationModeCursor, models.PaginationModeLink, models.PaginationModeNextURL:
 		queries = append(queries, query)
 	default:
 		frame, _, err := GetFrameForURLSourcesWithPostProcessing(ctx, query, infClient, requestHeaders, true)
 		return frame, err
 	}
-	if query.PageMode != models.PaginationModeCursor {
-		for _, currentQuery := range queries {
-			frame, _, err := GetFrameForURLSourcesWithPostProcessing(ctx, currentQuery, infClient, requestHeaders, false)
-			frames = append(frames, frame)
-			errs = errors.Join(errs, err)
+	switch query.PageMode {
+	case models.PaginationModeOffset, models.PaginationModePage:
+		frames, pageErrs, truncated = getPagesUntilDone(ctx, query, queries, infClient, requestHeaders)
+	case models.PaginationModeLink, models.PaginationModeNextURL:
+		frames, pageErrs, truncated = getNextURLPages(ctx, query, infClient, requestHeaders)
+	case models.PaginationModeCursor:
+		if query.PageParamCursorExtractionSource == models.PaginationCursorSourceRegex {
+			re, err := compileCursorRegex(query)
+			if err != nil {
+				return nil, err
+			}
+			ctx = context.WithValue(ctx, cursorRegexContextKey{}, re)
 		}
-	}
-	if query.PageMode == models.PaginationModeCursor {
 		i := 0
 		oCursor := ""
 		for {
@@ -83,30 +101,411 @@ func GetPaginatedResults(ctx context.Context, query models.Query, infClient Clie
 			if i > 0 && oCursor != "" {
 				currentQuery = ApplyPaginationItemToQuery(currentQuery, query.PageParamCursorFieldType, query.PageParamCursorFieldName, oCursor)
 			}
-			if i > query.PageMaxPages || (i > 0 && oCursor == "") {
+			if i >= query.PageMaxPages || (i > 0 && oCursor == "") {
+				truncated = oCursor != ""
 				break
 			}
 			i++
 			frame, cursor, err := GetFrameForURLSourcesWithPostProcessing(ctx, currentQuery, infClient, requestHeaders, false)
 			oCursor = cursor
 			frames = append(frames, frame)
-			errs = errors.Join(errs, err)
+			pageErrs = append(pageErrs, err)
 		}
+	default:
+		frames, pageErrs = getPages(ctx, queries, infClient, requestHeaders)
 	}
-	if errs != nil {
-		return nil, errs
+	frames, notices, err := getPartialResults(query.PartialResults, frames, pageErrs, func(i int) string { return fmt.Sprintf("error getting page %d", i+1) })
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
 	}
 	mergedFrame, err := transformations.Merge(frames, transformations.MergeFramesOptions{})
 	if err != nil {
 		return nil, err
 	}
-	return PostProcessFrame(ctx, mergedFrame, query)
+	frame, err := PostProcessFrame(ctx, mergedFrame, query)
+	if frame != nil {
+		frame.AppendNotices(notices...)
+	}
+	if frame != nil && truncated {
+		frame.AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("more pages are available. only the first %d pages are included in the results. increase the max pages of the query or the pagination max pages of the datasource to get more results", query.PageMaxPages),
+		})
+	}
+	return frame, err
+}
+
+// getPagesUntilDone fetches the pages in batches of the pagination concurrency and stops after the batch which has the last page.
+// Pages after the last page are dropped. Failed pages stop the pagination unless the query allows partial results.
+// It also returns whether the max pages cut off more results.
+func getPagesUntilDone(ctx context.Context, query models.Query, queries []models.Query, infClient Client, requestHeaders map[string]string) ([]*data.Frame, []error, bool) {
+	frames, pageErrs := []*data.Frame{}, []error{}
+	batchSize := infClient.Settings.GetPaginationConcurrency()
+	for start := 0; start < len(queries); start += batchSize {
+		batch, batchErrs := getPages(ctx, queries[start:min(start+batchSize, len(queries))], infClient, requestHeaders)
+		frames, pageErrs = append(frames, batch...), append(pageErrs, batchErrs...)
+		if !query.PartialResults && errors.Join(batchErrs...) != nil {
+			return frames, pageErrs, false
+		}
+		for i, frame := range batch {
+			if batchErrs[i] != nil {
+				continue
+			}
+			if done, more := getPageStatus(ctx, query, frame, start+i); done || start+i == len(queries)-1 {
+				// drop the pages after the last page
+				return dropEmptyPages(frames[:start+i+1], pageErrs[:start+i+1]), pageErrs[:start+i+1], more
+			}
+		}
+	}
+	return dropEmptyPages(frames, pageErrs), pageErrs, false
+}
+
+// dropEmptyPages replaces the successful empty pages after the first page with nil.
+// Empty pages have no fields and can't be merged with the other pages.
+func dropEmptyPages(frames []*data.Frame, pageErrs []error) []*data.Frame {
+	kept := false
+	for i, frame := range frames {
+		if frame == nil || pageErrs[i] != nil {
+			continue
+		}
+		if kept && frame.Rows() == 0 {
+			frames[i] = nil
+			continue
+		}
+		kept = true
+	}
+	return frames
+}
+
+// getPageStatus returns whether the page is the last page and whether more results are expected after it.
+// An empty page is always the last page. A page shorter than the page size, a total count reached by the page
+// or a has more value of false end the pagination when configured in the query.
+func getPageStatus(ctx context.Context, query models.Query, frame *data.Frame, pageIndex int) (done bool, more bool) {
+	logger := backend.Logger.FromContext(ctx)
+	rows := 0
+	if frame != nil {
+		rows = frame.Rows()
+	}
+	if rows == 0 {
+		return true, false
+	}
+	if query.PageStopOnShortPage && rows < query.PageParamSizeFieldVal {
+		return true, false
+	}
+	more = rows >= query.PageParamSizeFieldVal
+	totalCountPath, hasMorePath := strings.TrimSpace(query.PageTotalCountExtractionPath), strings.TrimSpace(query.PageHasMoreExtractionPath)
+	if totalCountPath == "" && hasMorePath == "" {
+		return false, more
+	}
+	var urlResponseObject any
+	if frame.Meta != nil {
+		if customMeta, ok := frame.Meta.Custom.(*CustomMeta); ok {
+			urlResponseObject = customMeta.Data
+		}
+	}
+	body, err := json.Marshal(urlResponseObject)
+	if err != nil {
+		return false, more
+	}
+	if totalCountPath != "" {
+		value, err := jsonframer.GetRootData(string(body), totalCountPath)
+		if totalCount, parseErr := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && parseErr == nil {
+			// the position of the first row of the page, relative to the first row of the results
+			first := pageIndex * query.PageParamSizeFieldVal
+			if query.PageMode == models.PaginationModeOffset {
+				first += query.PageParamOffsetFieldVal
+			}
+			if query.PageMode == models.PaginationModePage {
+				first += (query.PageParamPageFieldVal - 1) * query.PageParamSizeFieldVal
+			}
+			if float64(first+rows) >= totalCount {
+				return true, false
+			}
+			more = true
+		} else {
+			logger.Debug("total count not found in the response", "path", totalCountPath)
+		}
+	}
+	if hasMorePath != "" {
+		value, err := jsonframer.GetRootData(string(body), hasMorePath)
+		if hasMore, parseErr := strconv.ParseBool(strings.TrimSpace(value)); err == nil && parseErr == nil {
+			if !hasMore {
+				return true, false
+			}
+			more = true
+		} else {
+			logger.Debug("has more value not found in the response", "path", hasMorePath)
+		}
+	}
+	return false, more
+}
+
+// getPages fetches the pages concurrently using the pagination concurrency of the datasource.
+// Frames and errors are returned in the order of the page queries, regardless of the order the pages complete.
+func getPages(ctx context.Context, queries []models.Query, infClient Client, requestHeaders map[string]string) ([]*data.Frame, []error) {
+	frames := make([]*data.Frame, len(queries))
+	pageErrs := make([]error, len(queries))
+	wg := sync.WaitGroup{}
+	semaphore := make(chan struct{}, infClient.Settings.GetPaginationConcurrency())
+	for i, currentQuery := range queries {
+		wg.Add(1)
+		go func(i int, currentQuery models.Query) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			frames[i], _, pageErrs[i] = GetFrameForURLSourcesWithPostProcessing(ctx, currentQuery, infClient, requestHeaders, false)
+		}(i, currentQuery)
+	}
+	wg.Wait()
+	return frames, pageErrs
+}
+
+type cursorRegexContextKey struct{}
+
+// compileCursorRegex compiles the cursor extraction regex of the query
+func compileCursorRegex(query models.Query) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(query.PageParamCursorFieldExtractionPath)
+	if err != nil {
+		return nil, errorsource.DownstreamError(fmt.Errorf("invalid cursor extraction regex. %w", err), false)
+	}
+	return re, nil
+}
+
+// getCursor extracts the cursor of the next page from the response body or headers.
+// An empty cursor means there are no more pages.
+func getCursor(ctx context.Context, query models.Query, urlResponseObject any, responseHeaders http.Header, responseBody []byte) (string, error) {
+	logger := backend.Logger.FromContext(ctx)
+	path := query.PageParamCursorFieldExtractionPath
+	switch query.PageParamCursorExtractionSource {
+	case models.PaginationCursorSourceHeader:
+		return responseHeaders.Get(strings.TrimSpace(path)), nil
+	case models.PaginationCursorSourceXML:
+		responseString, ok := urlResponseObject.(string)
+		if !ok {
+			return "", errorsource.PluginError(errors.New("error while finding the cursor value"), false)
+		}
+		body, err := xj.Convert(strings.NewReader(responseString))
+		if err != nil {
+			return "", errorsource.DownstreamError(errors.New("error while finding the cursor value. invalid xml response"), false)
+		}
+		// the last page usually doesn't have the cursor at all
+		cursor, err := jsonframer.GetRootData(body.String(), path)
+		if err != nil {
+			logger.Debug("cursor not found in the response", "path", path)
+			return "", nil
+		}
+		return cursor, nil
+	case models.PaginationCursorSourceRegex:
+		// the regex is compiled once per query by the pagination. single page queries compile it here
+		re, ok := ctx.Value(cursorRegexContextKey{}).(*regexp.Regexp)
+		if !ok {
+			var err error
+			if re, err = compileCursorRegex(query); err != nil {
+				return "", err
+			}
+		}
+		// the regex runs on the body as received, not on the decoded response
+		match := re.FindSubmatch(responseBody)
+		if len(match) == 0 {
+			return "", nil
+		}
+		if len(match) > 1 {
+			return string(match[1]), nil
+		}
+		return string(match[0]), nil
+	default:
+		body, err := json.Marshal(urlResponseObject)
+		if err != nil {
+			return "", errorsource.PluginError(errors.New("error while finding the cursor value"), false)
+		}
+		cursor, err := jsonframer.GetRootData(string(body), path)
+		if err != nil {
+			return "", errorsource.PluginError(errors.New("error while extracting the cursor value"), false)
+		}
+		return cursor, nil
+	}
+}
+
+// getNextURLPages follows the next page urls one page at a time until there is no next page or the max pages are reached.
+// Every hop goes through the same egress checks as the first request.
+func getNextURLPages(ctx context.Context, query models.Query, infClient Client, requestHeaders map[string]string) ([]*data.Frame, []error, bool) {
+	frames, pageErrs := []*data.Frame{}, []error{}
+	visited := map[string]bool{}
+	currentQuery := query
+	for i := 0; ; i++ {
+		frame, nextURL, err := GetFrameForURLSourcesWithPostProcessing(ctx, currentQuery, infClient, requestHeaders, false)
+		frames, pageErrs = append(frames, frame), append(pageErrs, err)
+		if err != nil || nextURL == "" {
+			return frames, pageErrs, false
+		}
+		if visited[nextURL] {
+			backend.Logger.FromContext(ctx).Warn("next page url was already fetched. stopping the pagination", "url_path", currentQuery.URL)
+			return frames, pageErrs, false
+		}
+		if i+1 >= query.PageMaxPages {
+			return frames, pageErrs, true
+		}
+		visited[nextURL] = true
+		if currentQuery, err = applyNextURLToQuery(currentQuery, infClient.Settings, nextURL); err != nil {
+			// the next page fails without being requested
+			return append(frames, nil), append(pageErrs, err), false
+		}
+	}
+}
+
+// applyNextURLToQuery points the query to the next page url.
+// The next url already carries its query parameters, so the parameters of the query are not sent again.
+func applyNextURLToQuery(query models.Query, settings models.InfinitySettings, nextURL string) (models.Query, error) {
+	u, err := url.Parse(nextURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return query, errorsource.DownstreamError(fmt.Errorf("invalid next page url %s", nextURL), false)
+	}
+	if settings.URL != "" && !isWithinDatasourceURL(u, settings.URL) {
+		return query, errorsource.DownstreamError(fmt.Errorf("next page url %s is outside of the datasource url", nextURL), false)
+	}
+	// the secure query parameters carried by the next page url are added again when the request is created
+	q := u.Query()
+	removed := false
+	for key := range settings.SecureQueryFields {
+		removed = removed || q.Has(key)
+		q.Del(key)
+	}
+	if settings.AuthenticationMethod == models.AuthenticationMethodApiKey && settings.ApiKeyType == models.ApiKeyTypeQuery {
+		removed = removed || q.Has(settings.ApiKeyKey)
+		q.Del(settings.ApiKeyKey)
+	}
+	if removed {
+		u.RawQuery = q.Encode()
+		nextURL = u.String()
+	}
+	query.URL = nextURL
+	query.URLOptions.Params = []models.URLOptionKeyValuePair{}
+	return query, nil
+}
+
+// isWithinDatasourceURL returns true when the url has the scheme, host and port of the datasource url and its path is under the datasource url path
+func isWithinDatasourceURL(u *url.URL, datasourceURL string) bool {
+	base, err := url.Parse(datasourceURL)
+	if err != nil || u.User != nil {
+		return false
+	}
+	if !strings.EqualFold(u.Scheme, base.Scheme) || !strings.EqualFold(u.Hostname(), base.Hostname()) || getURLPort(u) != getURLPort(base) {
+		return false
+	}
+	prefix := strings.TrimSuffix(base.Path, "/")
+	if prefix == "" {
+		return true
+	}
+	// clean the path so that dot segments can't escape the datasource url path
+	requestPath := path.Clean("/" + u.Path)
+	return requestPath == prefix || strings.HasPrefix(requestPath, prefix+"/")
+}
+
+// getURLPort returns the port of the url or the default port of its scheme
+func getURLPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	if strings.EqualFold(u.Scheme, "https") {
+		return "443"
+	}
+	return "80"
+}
+
+// getNextPageURL returns the absolute url of the next page from the Link header or the response body.
+// An empty url means there are no more pages.
+func getNextPageURL(ctx context.Context, query models.Query, settings models.InfinitySettings, urlResponseObject any, responseHeaders http.Header) (string, error) {
+	logger := backend.Logger.FromContext(ctx)
+	next := ""
+	switch query.PageMode {
+	case models.PaginationModeLink:
+		next = getLinkHeaderURL(responseHeaders, "next")
+	case models.PaginationModeNextURL:
+		body, err := json.Marshal(urlResponseObject)
+		if err != nil {
+			return "", errorsource.PluginError(errors.New("error while finding the next page url"), false)
+		}
+		// the last page usually doesn't have the next url at all
+		if next, err = jsonframer.GetRootData(string(body), query.PageParamNextURLExtractionPath); err != nil {
+			logger.Debug("next page url not found in the response", "path", query.PageParamNextURLExtractionPath)
+			return "", nil
+		}
+	}
+	next = strings.TrimSpace(next)
+	if next == "" || next == "null" {
+		return "", nil
+	}
+	currentURL, err := GetQueryURL(ctx, settings, query, false)
+	if err != nil {
+		return "", errorsource.DownstreamError(fmt.Errorf("invalid url %s", currentURL), false)
+	}
+	base, err := url.Parse(currentURL)
+	if err != nil {
+		return "", errorsource.DownstreamError(fmt.Errorf("invalid url %s", currentURL), false)
+	}
+	ref, err := url.Parse(next)
+	if err != nil {
+		return "", errorsource.DownstreamError(fmt.Errorf("invalid next page url %s", next), false)
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// getLinkHeaderURL returns the target of the first RFC 8288 link with the given relation type.
+// Multiple Link headers and comma separated links within a header are supported.
+func getLinkHeaderURL(headers http.Header, rel string) string {
+	for _, header := range headers.Values("Link") {
+		for _, link := range splitLinks(header) {
+			link = strings.TrimSpace(link)
+			if !strings.HasPrefix(link, "<") || !strings.Contains(link, ">") {
+				continue
+			}
+			target := link[1:strings.Index(link, ">")]
+			for _, param := range strings.Split(link[strings.Index(link, ">")+1:], ";") {
+				key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(key), "rel") {
+					continue
+				}
+				// the rel parameter can hold several space separated relation types
+				for _, r := range strings.Fields(strings.Trim(strings.TrimSpace(value), `"`)) {
+					if strings.EqualFold(r, rel) {
+						return target
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// splitLinks splits a Link header on the commas which are not part of a link target or a quoted value
+func splitLinks(header string) []string {
+	links := []string{}
+	inTarget, inQuotes, start := false, false, 0
+	for i, c := range header {
+		switch {
+		case c == '<' && !inQuotes:
+			inTarget = true
+		case c == '>' && !inQuotes:
+			inTarget = false
+		case c == '"' && !inTarget:
+			inQuotes = !inQuotes
+		case c == ',' && !inTarget && !inQuotes:
+			links = append(links, header[start:i])
+			start = i + 1
+		}
+	}
+	return append(links, header[start:])
 }
 
 func ApplyPaginationItemToQuery(currentQuery models.Query, fieldType models.PaginationParamType, fieldName string, fieldValue string) models.Query {
 	if strings.TrimSpace(fieldValue) == "" {
 		return currentQuery
 	}
+	// the page queries are copies of the same query. clone the slices so that pages don't overwrite each other's items
+	currentQuery.URLOptions.Headers = slices.Clone(currentQuery.URLOptions.Headers)
+	currentQuery.URLOptions.Params = slices.Clone(currentQuery.URLOptions.Params)
+	currentQuery.URLOptions.BodyForm = slices.Clone(currentQuery.URLOptions.BodyForm)
 	field := models.URLOptionKeyValuePair{Key: fieldName, Value: fieldValue}
 	switch fieldType {
 	case models.PaginationParamTypeHeader:
@@ -141,7 +540,9 @@ func GetFrameForURLSourcesWithPostProcessing(ctx context.Context, query models.Q
 	defer span.End()
 	frame := GetDummyFrame(query)
 	cursor := ""
-	urlResponseObject, statusCode, duration, err := infClient.GetResults(ctx, query, requestHeaders)
+	ctx, retryStats := WithRetryStats(ctx)
+	ctx, cacheStats := WithCacheStats(ctx)
+	urlResponseObject, statusCode, duration, responseHeaders, responseBody, err := infClient.GetResults(ctx, query, requestHeaders)
 	frame.Meta.ExecutedQueryString = infClient.GetExecutedURL(ctx, query)
 	if infClient.IsMock {
 		duration = 123
@@ -153,6 +554,8 @@ func GetFrameForURLSourcesWithPostProcessing(ctx context.Context, query models.Q
 			Data:                   urlResponseObject,
 			ResponseCodeFromServer: statusCode,
 			Duration:               duration,
+			Attempts:               retryStats.Attempts(),
+			Cache:                  cacheStats.Status(),
 			Query:                  query,
 			Error:                  err.Error(),
 		}
@@ -164,7 +567,8 @@ func GetFrameForURLSourcesWithPostProcessing(ctx context.Context, query models.Q
 		}
 	}
 	if query.Parser == "backend" {
-		if query.Type == models.QueryTypeJSON || query.Type == models.QueryTypeGraphQL {
+		// the response of HEAD requests is always an object irrespective of the query type
+		if query.Type == models.QueryTypeJSON || query.Type == models.QueryTypeGraphQL || query.URLOptions.GetMethod() == http.MethodHead {
 			if frame, err = GetJSONBackendResponse(ctx, urlResponseObject, query); err != nil {
 				return frame, cursor, err
 			}
@@ -199,6 +603,8 @@ func GetFrameForURLSourcesWithPostProcessing(ctx context.Context, query models.Q
 		Data:                   urlResponseObject,
 		ResponseCodeFromServer: statusCode,
 		Duration:               duration,
+		Attempts:               retryStats.Attempts(),
+		Cache:                  cacheStats.Status(),
 	}
 	if err != nil {
 		logger.Error("error getting response for query", "error", err.Error())
@@ -206,19 +612,23 @@ func GetFrameForURLSourcesWithPostProcessing(ctx context.Context, query models.Q
 			Data:                   urlResponseObject,
 			ResponseCodeFromServer: statusCode,
 			Duration:               duration,
+			Attempts:               retryStats.Attempts(),
+			Cache:                  cacheStats.Status(),
 			Query:                  query,
 			Error:                  err.Error(),
 		}
 		return frame, cursor, err
 	}
 	if query.PageMode == models.PaginationModeCursor && strings.TrimSpace(query.PageParamCursorFieldExtractionPath) != "" {
-		body, err := json.Marshal(urlResponseObject)
+		cursor, err = getCursor(ctx, query, urlResponseObject, responseHeaders, responseBody)
 		if err != nil {
-			return frame, cursor, errorsource.PluginError(errors.New("error while finding the cursor value"), false)
+			return frame, cursor, err
 		}
-		cursor, err = jsonframer.GetRootData(string(body), query.PageParamCursorFieldExtractionPath)
+	}
+	if query.PageMode == models.PaginationModeLink || query.PageMode == models.PaginationModeNextURL {
+		cursor, err = getNextPageURL(ctx, query, infClient.Settings, urlResponseObject, responseHeaders)
 		if err != nil {
-			return frame, cursor, errorsource.PluginError(errors.New("error while extracting the cursor value"), false)
+			return frame, cursor, err
 		}
 	}
 	return frame, cursor, nil
@@ -0,0 +1,535 @@
+package infinity_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/infinity"
+	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getPageValues(t *testing.T, frame *data.Frame) []float64 {
+	t.Helper()
+	require.NotNil(t, frame)
+	field, _ := frame.FieldByName("page")
+	require.NotNil(t, field)
+	values := []float64{}
+	for i := 0; i < field.Len(); i++ {
+		if v, ok := field.At(i).(*float64); ok && v != nil {
+			values = append(values, *v)
+		}
+	}
+	return values
+}
+
+func TestGetPaginatedResults(t *testing.T) {
+	inflight, maxInflight := &atomic.Int32{}, &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := inflight.Add(1)
+		defer inflight.Add(-1)
+		for {
+			previous := maxInflight.Load()
+			if current <= previous || maxInflight.CompareAndSwap(previous, current) {
+				break
+			}
+		}
+		// random delays so that the pages complete out of order
+		time.Sleep(time.Duration(rand.IntN(20)) * time.Millisecond)
+		page := r.URL.Query().Get("page")
+		if offset := r.URL.Query().Get("offset"); offset != "" {
+			page = offset
+		}
+		if h := r.Header.Get("X-Page"); h != "" {
+			page = h
+		}
+		pageNumber, err := strconv.Atoi(page)
+		require.NoError(t, err)
+		_, _ = w.Write([]byte(fmt.Sprintf(`[{"page":%d},{"page":%d}]`, pageNumber, pageNumber)))
+	}))
+	defer server.Close()
+	tests := []struct {
+		name            string
+		settings        models.InfinitySettings
+		query           models.Query
+		want            []float64
+		wantMaxInflight int32
+	}{
+		{
+			name:  "pages should be merged in page order",
+			query: models.Query{PageMode: models.PaginationModePage, PageParamPageFieldName: "page", PageMaxPages: 4},
+			want:  []float64{1, 1, 2, 2, 3, 3, 4, 4},
+		},
+		{
+			name:  "max pages should be capped by the default datasource limit",
+			query: models.Query{PageMode: models.PaginationModePage, PageParamPageFieldName: "page", PageMaxPages: 10},
+			want:  []float64{1, 1, 2, 2, 3, 3, 4, 4, 5, 5},
+		},
+		{
+			name:     "max pages should be capped by the datasource setting",
+			settings: models.InfinitySettings{PaginationMaxPages: 7},
+			query:    models.Query{PageMode: models.PaginationModePage, PageParamPageFieldName: "page", PageMaxPages: 10},
+			want:     []float64{1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6, 7, 7},
+		},
+		{
+			name:            "pages should be fetched concurrently up to the datasource setting",
+			settings:        models.InfinitySettings{PaginationMaxPages: 10, PaginationConcurrency: 2},
+			query:           models.Query{PageMode: models.PaginationModePage, PageParamPageFieldName: "page", PageMaxPages: 6},
+			want:            []float64{1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6},
+			wantMaxInflight: 2,
+		},
+		{
+			name:  "offset pages should be merged in page order",
+			query: models.Query{PageMode: models.PaginationModeOffset, PageParamOffsetFieldName: "offset", PageParamSizeFieldVal: 10, PageMaxPages: 3},
+			want:  []float64{0, 0, 10, 10, 20, 20},
+		},
+		{
+			name:  "list pages should be merged in list order",
+			query: models.Query{PageMode: models.PaginationModeList, PageParamListFieldName: "page", PageParamListFieldValue: "30,10,20", PageMaxPages: 3},
+			want:  []float64{30, 30, 10, 10, 20, 20},
+		},
+		{
+			name: "replaced header placeholders should be replaced in every page",
+			query: models.Query{
+				PageMode:               models.PaginationModePage,
+				PageParamPageFieldName: "PAGE",
+				PageParamPageFieldType: models.PaginationParamTypeReplace,
+				PageMaxPages:           3,
+				URLOptions:             models.URLOptions{Headers: []models.URLOptionKeyValuePair{{Key: "X-Page", Value: "PAGE"}}},
+			},
+			want: []float64{1, 1, 2, 2, 3, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxInflight.Store(0)
+			client, err := infinity.NewClient(context.Background(), tt.settings)
+			require.NoError(t, err)
+			query := tt.query
+			query.URL = server.URL
+			query.Type = models.QueryTypeJSON
+			query.Source = "url"
+			query.Parser = models.InfinityParserBackend
+			query = models.ApplyDefaultsToQuery(context.Background(), query)
+			frame, err := infinity.GetPaginatedResults(context.Background(), query, *client, map[string]string{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, getPageValues(t, frame))
+			if tt.wantMaxInflight > 0 {
+				assert.LessOrEqual(t, maxInflight.Load(), tt.wantMaxInflight)
+			}
+		})
+	}
+}
+
+func TestGetPaginatedResultsWithNextURL(t *testing.T) {
+	requests := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		next := fmt.Sprintf("/items?page=%d", page+1)
+		if r.URL.Query().Get("loop") == "true" {
+			next = "/items?page=1&loop=true"
+		}
+		switch r.URL.Path {
+		case "/link":
+			if page < 3 {
+				w.Header().Add("Link", `<https://example.com/items?page=1>; rel="first"`)
+				w.Header().Add("Link", fmt.Sprintf(`<https://example.com/items?page=9>; rel="last", <%s://%s/link?page=%d>; rel="prev next"`, "http", r.Host, page+1))
+			}
+			_, _ = w.Write([]byte(fmt.Sprintf(`[{"page":%d}]`, page)))
+		case "/items":
+			if page >= 3 {
+				next = ""
+			}
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"value":[{"page":%d}],"@odata.nextLink":%q,"_links":{"next":{"href":%q}}}`, page, next, next)))
+		case "/external":
+			_, _ = w.Write([]byte(`{"value":[{"page":1}],"@odata.nextLink":"https://blocked.example.com/items?page=2"}`))
+		case "/api/hostile":
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"value":[{"page":1}],"@odata.nextLink":%q}`, strings.ReplaceAll(r.URL.Query().Get("next"), "{host}", r.Host))))
+		case "/api/respelled":
+			if keys := r.URL.Query()["api_key"]; len(keys) != 1 || keys[0] != "secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			next = ""
+			if page < 3 {
+				next = fmt.Sprintf("HTTP://%s/api/respelled?page=%d&api_key=secret", strings.ToUpper(r.Host), page+1)
+			}
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"value":[{"page":%d}],"@odata.nextLink":%q}`, page, next)))
+		}
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	tests := []struct {
+		name         string
+		settings     models.InfinitySettings
+		query        models.Query
+		want         []float64
+		wantRequests int32
+		wantErr      error
+		wantErrMsg   string
+	}{
+		{
+			name:         "link header should be followed until there is no next link",
+			query:        models.Query{URL: server.URL + "/link", PageMode: models.PaginationModeLink, PageMaxPages: 5},
+			want:         []float64{1, 2, 3},
+			wantRequests: 3,
+		},
+		{
+			name:         "link header should be bounded by max pages",
+			query:        models.Query{URL: server.URL + "/link", PageMode: models.PaginationModeLink, PageMaxPages: 2},
+			want:         []float64{1, 2},
+			wantRequests: 2,
+		},
+		{
+			name:         "relative odata next links should be followed",
+			query:        models.Query{URL: server.URL + "/items", RootSelector: "value", PageMode: models.PaginationModeNextURL, PageParamNextURLExtractionPath: `@odata\.nextLink`, PageMaxPages: 5},
+			want:         []float64{1, 2, 3},
+			wantRequests: 3,
+		},
+		{
+			name:         "hal next links should be followed",
+			query:        models.Query{URL: server.URL + "/items", RootSelector: "value", PageMode: models.PaginationModeNextURL, PageParamNextURLExtractionPath: "_links.next.href", PageMaxPages: 5},
+			want:         []float64{1, 2, 3},
+			wantRequests: 3,
+		},
+		{
+			name:         "next links already fetched should stop the pagination",
+			query:        models.Query{URL: server.URL + "/items?loop=true", RootSelector: "value", PageMode: models.PaginationModeNextURL, PageParamNextURLExtractionPath: "_links.next.href", PageMaxPages: 5},
+			want:         []float64{1, 1},
+			wantRequests: 2,
+		},
+		{
+			name:         "next links to hosts which are not allowed should be blocked",
+			settings:     models.InfinitySettings{AllowedHosts: []string{server.URL}},
+			query:        models.Query{URL: server.URL + "/external", RootSelector: "value", PageMode: models.PaginationModeNextURL, PageParamNextURLExtractionPath: `@odata\.nextLink`, PageMaxPages: 5},
+			wantRequests: 1,
+			wantErr:      infinity.ErrEgressNotAllowed,
+		},
+		{
+			name:         "next links with the datasource url as user info should be blocked",
+			settings:     models.InfinitySettings{URL: server.URL},
+			query:        models.Query{URL: server.URL + "/api/hostile?next=" + url.QueryEscape("http://{host}@evil.example.com/items"), RootSelector: "value", PageMode: models.PaginationModeNextURL, PageParamNextURLExtractionPath: `@odata\.nextLink`, PageMaxPages: 5},
+			wantRequests: 1,
+			wantErrMsg:   "is outside of the datasource url",
+		},
+		{
+			name:         "next links outside of the datasource url path should be blocked",
+			settings:     models.InfinitySettings{URL: server.URL + "/api"},
+			query:        models.Query{URL: server.URL + "/api/hostile?next=" + url.QueryEscape("http://{host}/api-other/items"), RootSelector: "value", PageMode: models.PaginationModeNextURL, PageParamNextURLExtractionPath: `@odata\.nextLink`, PageMaxPages: 5},
+			wantRequests: 1,
+			wantErrMsg:   "is outside of the datasource url",
+		},
+		{
+			name:         "next links spelling the datasource url differently should be followed",
+			settings:     models.InfinitySettings{URL: "http://localhost:" + port + "/api", SecureQueryFields: map[string]string{"api_key": "secret"}},
+			query:        models.Query{URL: "/respelled", RootSelector: "value", PageMode: models.PaginationModeNextURL, PageParamNextURLExtractionPath: `@odata\.nextLink`, PageMaxPages: 5},
+			want:         []float64{1, 2, 3},
+			wantRequests: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			client, err := infinity.NewClient(context.Background(), tt.settings)
+			require.NoError(t, err)
+			query := tt.query
+			query.Type = models.QueryTypeJSON
+			query.Source = "url"
+			query.Parser = models.InfinityParserBackend
+			query = models.ApplyDefaultsToQuery(context.Background(), query)
+			frame, err := infinity.GetPaginatedResults(context.Background(), query, *client, map[string]string{})
+			assert.Equal(t, tt.wantRequests, requests.Load())
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			if tt.wantErrMsg != "" {
+				require.ErrorContains(t, err, tt.wantErrMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, getPageValues(t, frame))
+		})
+	}
+}
+
+func TestGetPaginatedResultsStopConditions(t *testing.T) {
+	requests := &atomic.Int32{}
+	totalCount := 7
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		items := []string{}
+		for i := (page - 1) * limit; i < min(page*limit, totalCount); i++ {
+			items = append(items, fmt.Sprintf(`{"page":%d}`, page))
+		}
+		_, _ = w.Write([]byte(fmt.Sprintf(`{"items":[%s],"total":%d,"has_more":%t}`, strings.Join(items, ","), totalCount, page*limit < totalCount)))
+	}))
+	defer server.Close()
+	tests := []struct {
+		name         string
+		query        models.Query
+		want         []float64
+		wantRequests int32
+		wantNotice   bool
+	}{
+		{
+			name:         "empty page should end the pagination",
+			query:        models.Query{PageMaxPages: 10},
+			want:         []float64{1, 1, 1, 2, 2, 2, 3},
+			wantRequests: 4,
+		},
+		{
+			name:         "short page should end the pagination",
+			query:        models.Query{PageMaxPages: 10, PageStopOnShortPage: true},
+			want:         []float64{1, 1, 1, 2, 2, 2, 3},
+			wantRequests: 3,
+		},
+		{
+			name:         "total count should end the pagination",
+			query:        models.Query{PageMaxPages: 10, PageTotalCountExtractionPath: "total"},
+			want:         []float64{1, 1, 1, 2, 2, 2, 3},
+			wantRequests: 3,
+		},
+		{
+			name:         "has more should end the pagination",
+			query:        models.Query{PageMaxPages: 10, PageHasMoreExtractionPath: "has_more"},
+			want:         []float64{1, 1, 1, 2, 2, 2, 3},
+			wantRequests: 3,
+		},
+		{
+			name:         "max pages cutting off the results should add a notice",
+			query:        models.Query{PageMaxPages: 2, PageHasMoreExtractionPath: "has_more"},
+			want:         []float64{1, 1, 1, 2, 2, 2},
+			wantRequests: 2,
+			wantNotice:   true,
+		},
+		{
+			name:         "max pages cutting off full pages should add a notice",
+			query:        models.Query{PageMaxPages: 2},
+			want:         []float64{1, 1, 1, 2, 2, 2},
+			wantRequests: 2,
+			wantNotice:   true,
+		},
+		{
+			name:         "max pages reaching the last page should not add a notice",
+			query:        models.Query{PageMaxPages: 3, PageTotalCountExtractionPath: "total"},
+			want:         []float64{1, 1, 1, 2, 2, 2, 3},
+			wantRequests: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			client, err := infinity.NewClient(context.Background(), models.InfinitySettings{PaginationMaxPages: 10, PaginationConcurrency: 1})
+			require.NoError(t, err)
+			query := tt.query
+			query.URL = server.URL
+			query.Type = models.QueryTypeJSON
+			query.Source = "url"
+			query.Parser = models.InfinityParserBackend
+			query.RootSelector = "items"
+			query.PageMode = models.PaginationModePage
+			query.PageParamSizeFieldVal = 3
+			query = models.ApplyDefaultsToQuery(context.Background(), query)
+			frame, err := infinity.GetPaginatedResults(context.Background(), query, *client, map[string]string{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, getPageValues(t, frame))
+			assert.Equal(t, tt.wantRequests, requests.Load())
+			notices := 0
+			if frame.Meta != nil {
+				notices = len(frame.Meta.Notices)
+			}
+			assert.Equal(t, tt.wantNotice, notices > 0)
+		})
+	}
+}
+
+func TestGetPaginatedResultsWithCursor(t *testing.T) {
+	requests := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		page := 1
+		if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+			cursor, _, _ = strings.Cut(cursor, "&")
+			page, _ = strconv.Atoi(strings.TrimPrefix(cursor, "token-"))
+		}
+		next := ""
+		if page < 3 {
+			next = fmt.Sprintf("token-%d", page+1)
+		}
+		switch r.URL.Path {
+		case "/header":
+			if next != "" {
+				w.Header().Set("X-Next-Cursor", next)
+			}
+			_, _ = w.Write([]byte(fmt.Sprintf(`[{"page":%d},{"page":%d}]`, page, page)))
+		case "/xml":
+			if next != "" {
+				next = fmt.Sprintf("<next>%s</next>", next)
+			}
+			_, _ = w.Write([]byte(fmt.Sprintf(`<response><items><item><page>%d</page></item><item><page>%d</page></item></items>%s</response>`, page, page, next)))
+		case "/regex":
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"items":[{"page":%d},{"page":%d}],"links":"self=token-%d;next=%s"}`, page, page, page, next)))
+		case "/raw":
+			if next != "" {
+				next += "&scope=all"
+			}
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"next": "%s", "items": [{"page":%d},{"page":%d}]}`, next, page, page)))
+		}
+	}))
+	defer server.Close()
+	tests := []struct {
+		name  string
+		query models.Query
+	}{
+		{
+			name:  "cursor should be extracted from the response header",
+			query: models.Query{URL: server.URL + "/header", Type: models.QueryTypeJSON, PageParamCursorExtractionSource: models.PaginationCursorSourceHeader, PageParamCursorFieldExtractionPath: "x-next-cursor"},
+		},
+		{
+			name: "cursor should be extracted from the xml response",
+			query: models.Query{
+				URL:                                server.URL + "/xml",
+				Type:                               models.QueryTypeXML,
+				RootSelector:                       "response.items.item",
+				Columns:                            []models.InfinityColumn{{Selector: "page", Text: "page", Type: "number"}},
+				PageParamCursorExtractionSource:    models.PaginationCursorSourceXML,
+				PageParamCursorFieldExtractionPath: "response.next",
+			},
+		},
+		{
+			name:  "cursor should be extracted from the response with the first capture group of the regex",
+			query: models.Query{URL: server.URL + "/regex", Type: models.QueryTypeJSON, RootSelector: "items", PageParamCursorExtractionSource: models.PaginationCursorSourceRegex, PageParamCursorFieldExtractionPath: `next=(token-\d+)`},
+		},
+		{
+			name:  "cursor regex should run on the body as received",
+			query: models.Query{URL: server.URL + "/raw", Type: models.QueryTypeJSON, RootSelector: "items", PageParamCursorExtractionSource: models.PaginationCursorSourceRegex, PageParamCursorFieldExtractionPath: `"next": "(token-\d+&scope=all)"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			client, err := infinity.NewClient(context.Background(), models.InfinitySettings{})
+			require.NoError(t, err)
+			query := tt.query
+			query.Source = "url"
+			query.Parser = models.InfinityParserBackend
+			query.PageMode = models.PaginationModeCursor
+			query.PageMaxPages = 5
+			query = models.ApplyDefaultsToQuery(context.Background(), query)
+			frame, err := infinity.GetPaginatedResults(context.Background(), query, *client, map[string]string{})
+			require.NoError(t, err)
+			assert.Equal(t, []float64{1, 1, 2, 2, 3, 3}, getPageValues(t, frame))
+			assert.Equal(t, int32(3), requests.Load())
+		})
+	}
+	t.Run("invalid cursor regex should fail before the first page", func(t *testing.T) {
+		requests.Store(0)
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{})
+		require.NoError(t, err)
+		query := models.Query{URL: server.URL + "/raw", Type: models.QueryTypeJSON, Source: "url", Parser: models.InfinityParserBackend, PageMode: models.PaginationModeCursor, PageMaxPages: 5, PageParamCursorExtractionSource: models.PaginationCursorSourceRegex, PageParamCursorFieldExtractionPath: `next=(`}
+		query = models.ApplyDefaultsToQuery(context.Background(), query)
+		_, err = infinity.GetPaginatedResults(context.Background(), query, *client, map[string]string{})
+		require.ErrorContains(t, err, "invalid cursor extraction regex")
+		assert.Equal(t, int32(0), requests.Load())
+	})
+	t.Run("cursor pagination should be bounded by max pages", func(t *testing.T) {
+		requests.Store(0)
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{})
+		require.NoError(t, err)
+		query := models.Query{URL: server.URL + "/header", Type: models.QueryTypeJSON, Source: "url", Parser: models.InfinityParserBackend, PageMode: models.PaginationModeCursor, PageMaxPages: 2, PageParamCursorExtractionSource: models.PaginationCursorSourceHeader, PageParamCursorFieldExtractionPath: "x-next-cursor"}
+		query = models.ApplyDefaultsToQuery(context.Background(), query)
+		frame, err := infinity.GetPaginatedResults(context.Background(), query, *client, map[string]string{})
+		require.NoError(t, err)
+		assert.Equal(t, []float64{1, 1, 2, 2}, getPageValues(t, frame))
+		assert.Equal(t, int32(2), requests.Load())
+		require.NotNil(t, frame.Meta)
+		require.Len(t, frame.Meta.Notices, 1)
+		assert.Contains(t, frame.Meta.Notices[0].Text, "only the first 2 pages")
+	})
+}
+
+func TestGetPaginatedResultsPartialResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+			page, _ = strconv.Atoi(cursor)
+		}
+		page = max(page, 1)
+		if page == 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(fmt.Sprintf(`{"items":[{"page":%d},{"page":%d}],"next":"%d"}`, page, page, page+1)))
+	}))
+	defer server.Close()
+	tests := []struct {
+		name        string
+		query       models.Query
+		want        []float64
+		wantErr     string
+		wantNotices []string
+	}{
+		{
+			name:    "failed page should fail the query by default",
+			query:   models.Query{PageMode: models.PaginationModePage, PageMaxPages: 3},
+			wantErr: "error getting page 2. unsuccessful HTTP response",
+		},
+		{
+			name:        "failed page should be reported as a notice with partial results",
+			query:       models.Query{PageMode: models.PaginationModePage, PageMaxPages: 3, PartialResults: true},
+			want:        []float64{1, 1, 3, 3},
+			wantNotices: []string{"results are incomplete. 1 of 3 requests failed", "error getting page 2. status code 500. unsuccessful HTTP response"},
+		},
+		{
+			name:        "failed cursor page should keep the previous pages with partial results",
+			query:       models.Query{PageMode: models.PaginationModeCursor, PageParamCursorFieldExtractionPath: "next", PageMaxPages: 3, PartialResults: true},
+			want:        []float64{1, 1},
+			wantNotices: []string{"results are incomplete. 1 of 2 requests failed", "error getting page 2. status code 500. unsuccessful HTTP response"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := infinity.NewClient(context.Background(), models.InfinitySettings{})
+			require.NoError(t, err)
+			query := tt.query
+			query.URL = server.URL
+			query.Type = models.QueryTypeJSON
+			query.Source = "url"
+			query.Parser = models.InfinityParserBackend
+			query.RootSelector = "items"
+			query = models.ApplyDefaultsToQuery(context.Background(), query)
+			frame, err := infinity.GetPaginatedResults(context.Background(), query, *client, map[string]string{})
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, getPageValues(t, frame))
+			require.NotNil(t, frame.Meta)
+			require.Len(t, frame.Meta.Notices, len(tt.wantNotices))
+			for i, notice := range frame.Meta.Notices {
+				assert.Equal(t, data.NoticeSeverityWarning, notice.Severity)
+				assert.Contains(t, notice.Text, tt.wantNotices[i])
+			}
+		})
+	}
+}
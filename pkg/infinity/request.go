@@ -20,11 +20,10 @@ func GetRequest(ctx context.Context, settings models.InfinitySettings, body io.R
 	if err != nil {
 		return nil, err
 	}
-	switch strings.ToUpper(query.URLOptions.Method) {
-	case http.MethodPost:
-		req, err = http.NewRequestWithContext(ctx, http.MethodPost, url, body)
-	default:
-		req, err = http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if query.URLOptions.HasBody() {
+		req, err = http.NewRequestWithContext(ctx, query.URLOptions.GetMethod(), url, body)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, query.URLOptions.GetMethod(), url, nil)
 	}
 	req = ApplyAcceptHeader(query, settings, req, includeSect)
 	req = ApplyContentTypeHeader(query, settings, req, includeSect)
@@ -42,7 +41,7 @@ func GetQueryURL(ctx context.Context, settings models.InfinitySettings, query mo
 	_, span := tracing.DefaultTracer().Start(ctx, "GetQueryURL")
 	defer span.End()
 	urlString := query.URL
-	if !strings.HasPrefix(query.URL, settings.URL) {
+	if !strings.HasPrefix(query.URL, settings.URL) && !isAbsoluteDatasourceURL(query.URL, settings.URL) {
 		urlString = settings.URL + urlString
 	}
 	urlString = replaceSect(urlString, settings, includeSect)
@@ -78,6 +77,12 @@ func GetQueryURL(ctx context.Context, settings models.InfinitySettings, query mo
 	return NormalizeURL(u.String()), nil
 }
 
+// isAbsoluteDatasourceURL returns true for the absolute urls under the datasource url, such as the next page urls, even when they spell the datasource url differently
+func isAbsoluteDatasourceURL(urlString string, datasourceURL string) bool {
+	u, err := url.Parse(urlString)
+	return err == nil && u.IsAbs() && datasourceURL != "" && isWithinDatasourceURL(u, datasourceURL)
+}
+
 func NormalizeURL(u string) string {
 	urlArray := strings.Split(u, "/")
 	if strings.HasPrefix(u, "https://github.com") && len(urlArray) > 5 && urlArray[5] == "blob" && urlArray[4] != "blob" && urlArray[3] != "blob" {
@@ -2,10 +2,19 @@ package infinity_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/infinity"
 	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/models"
 	"github.com/stretchr/testify/assert"
@@ -272,15 +281,24 @@ func Test_ApplyAzureManagedIdentity(t *testing.T) {
 		name           string
 		settings       models.InfinitySettings
 		requestHeaders map[string]string
-		expectAuth     bool
+		includeSect    bool
+		wantAuth       string
 	}{
 		{
-			name: "should apply managed identity authentication successfully",
+			name: "should apply masked managed identity header when secrets are excluded",
 			settings: models.InfinitySettings{
 				AuthenticationMethod: models.AuthenticationMethodManagedIdentity,
 			},
 			requestHeaders: make(map[string]string),
-			expectAuth:     true,
+			wantAuth:       "Bearer xxxxxxxx",
+		},
+		{
+			name: "should leave the token to the transport when secrets are included",
+			settings: models.InfinitySettings{
+				AuthenticationMethod: models.AuthenticationMethodManagedIdentity,
+			},
+			requestHeaders: make(map[string]string),
+			includeSect:    true,
 		},
 		{
 			name: "should not apply managed identity authentication when method is not managed identity",
@@ -288,76 +306,248 @@ func Test_ApplyAzureManagedIdentity(t *testing.T) {
 				AuthenticationMethod: models.AuthenticationMethodNone,
 			},
 			requestHeaders: make(map[string]string),
-			expectAuth:     false,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Create a basic HTTP request
 			req, err := http.NewRequest("GET", "https://example.com", nil)
 			require.NoError(t, err)
-
-			// Apply Azure Managed Identity logic
-			req = infinity.ApplyAzureManagedIdentity(tt.requestHeaders, tt.settings, req, true)
-
-			// Verify if the Authorization header is set correctly based on the settings
-			authHeader := req.Header.Get("Authorization")
-			if tt.expectAuth {
-				assert.Contains(t, authHeader, "Bearer ")
-				assert.NotEmpty(t, authHeader)
-			} else {
-				assert.Empty(t, authHeader)
-			}
+			req = infinity.ApplyAzureManagedIdentity(tt.requestHeaders, tt.settings, req, tt.includeSect)
+			assert.Equal(t, tt.wantAuth, req.Header.Get("Authorization"))
 		})
 	}
 }
 
-func TestGetRequest(t *testing.T) {
-	tests := []struct {
-		name           string
-		settings       models.InfinitySettings
-		query          models.Query
-		requestHeaders map[string]string
-		wantErr        bool
-	}{
-		{
-			name: "request with Azure Managed Identity",
-			settings: models.InfinitySettings{
-				AuthenticationMethod: models.AuthenticationMethodManagedIdentity,
-			},
-			query: models.Query{
-				URL: "https://example.com",
-			},
-			requestHeaders: make(map[string]string),
-			wantErr:        false,
-		},
-		{
-			name: "request with invalid Managed Identity credentials",
-			settings: models.InfinitySettings{
-				AuthenticationMethod: models.AuthenticationMethodManagedIdentity,
-			},
-			query: models.Query{
-				URL: "https://example.com",
-			},
-			requestHeaders: make(map[string]string),
-			wantErr:        true, // Simulate failing to get a token
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Mock the GetRequest function
-			req, err := infinity.GetRequest(context.Background(), tt.settings, nil, tt.query, tt.requestHeaders, true)
-			if tt.wantErr {
-				assert.Error(t, err)
-			} else {
+// getIMDSServer returns a local stand-in for the Azure instance metadata service and counts the token requests
+func getIMDSServer(t *testing.T, statusCode int, expiresIn time.Duration, tokenRequests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests.Add(1)
+		assert.Equal(t, "/metadata/identity/oauth2/token", r.URL.Path)
+		assert.Equal(t, "true", r.Header.Get("Metadata"))
+		if statusCode != http.StatusOK {
+			w.WriteHeader(statusCode)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"access_token":"token-%d","expires_on":"%d","token_type":"Bearer"}`, tokenRequests.Load(), time.Now().Add(expiresIn).Unix())
+	}))
+	t.Setenv("AZURE_POD_IDENTITY_AUTHORITY_HOST", server.URL)
+	t.Setenv("GF_DEFAULT_APP_MODE", "")
+	return server
+}
+
+func TestGetResultsWithAzureManagedIdentity(t *testing.T) {
+	t.Run("should fetch the token once and reuse it across requests", func(t *testing.T) {
+		tokenRequests := &atomic.Int32{}
+		imds := getIMDSServer(t, http.StatusOK, time.Hour, tokenRequests)
+		defer imds.Close()
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, fmt.Sprintf("Bearer token-%d", tokenRequests.Load()), r.Header.Get("Authorization"))
+			_, _ = w.Write([]byte(`{"message":"ok"}`))
+		}))
+		defer server.Close()
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodManagedIdentity})
+		require.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			_, statusCode, _, _, _, err := client.GetResults(context.Background(), models.Query{URL: server.URL, Type: models.QueryTypeJSON}, map[string]string{})
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, statusCode)
+		}
+		assert.Equal(t, int32(1), tokenRequests.Load())
+		client.Dispose()
+		_, _, _, _, _, err = client.GetResults(context.Background(), models.Query{URL: server.URL, Type: models.QueryTypeJSON}, map[string]string{})
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), tokenRequests.Load())
+	})
+	t.Run("should reuse the token when the endpoint doesn't return the expiry", func(t *testing.T) {
+		tokenRequests := &atomic.Int32{}
+		imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenRequests.Add(1)
+			_, _ = w.Write([]byte(`{"access_token":"foo","token_type":"Bearer"}`))
+		}))
+		defer imds.Close()
+		t.Setenv("AZURE_POD_IDENTITY_AUTHORITY_HOST", imds.URL)
+		t.Setenv("GF_DEFAULT_APP_MODE", "")
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "Bearer foo", r.Header.Get("Authorization"))
+			_, _ = w.Write([]byte(`{"message":"ok"}`))
+		}))
+		defer server.Close()
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodManagedIdentity})
+		require.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			_, _, _, _, _, err := client.GetResults(context.Background(), models.Query{URL: server.URL, Type: models.QueryTypeJSON}, map[string]string{})
+			require.NoError(t, err)
+		}
+		assert.Equal(t, int32(1), tokenRequests.Load())
+	})
+	t.Run("should request the token for the configured resource and identity", func(t *testing.T) {
+		tests := []struct {
+			name         string
+			identity     models.AzureManagedIdentitySettings
+			wantResource string
+			wantClientID string
+			wantObjectID string
+		}{
+			{name: "default resource", wantResource: "https://management.azure.com/"},
+			{name: "resource with client ID", identity: models.AzureManagedIdentitySettings{Resource: "https://graph.microsoft.com", ClientID: "00000000-0000-0000-0000-000000000001"}, wantResource: "https://graph.microsoft.com", wantClientID: "00000000-0000-0000-0000-000000000001"},
+			{name: "scope with object ID", identity: models.AzureManagedIdentitySettings{Resource: "api://my-app/.default", ObjectID: "00000000-0000-0000-0000-000000000002"}, wantResource: "api://my-app", wantObjectID: "00000000-0000-0000-0000-000000000002"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					assert.Equal(t, "2018-02-01", r.URL.Query().Get("api-version"))
+					assert.Equal(t, tt.wantResource, r.URL.Query().Get("resource"))
+					assert.Equal(t, tt.wantClientID, r.URL.Query().Get("client_id"))
+					assert.Equal(t, tt.wantObjectID, r.URL.Query().Get("object_id"))
+					_, _ = fmt.Fprintf(w, `{"access_token":"foo","expires_in":"3600","token_type":"Bearer"}`)
+				}))
+				defer imds.Close()
+				t.Setenv("AZURE_POD_IDENTITY_AUTHORITY_HOST", imds.URL)
+				t.Setenv("GF_DEFAULT_APP_MODE", "")
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					assert.Equal(t, "Bearer foo", r.Header.Get("Authorization"))
+					_, _ = w.Write([]byte(`{"message":"ok"}`))
+				}))
+				defer server.Close()
+				client, err := infinity.NewClient(context.Background(), models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodManagedIdentity, AzureManagedIdentity: tt.identity})
+				require.NoError(t, err)
+				_, _, _, _, _, err = client.GetResults(context.Background(), models.Query{URL: server.URL, Type: models.QueryTypeJSON}, map[string]string{})
+				require.NoError(t, err)
+			})
+		}
+	})
+	t.Run("should fail the request when the token can't be retrieved", func(t *testing.T) {
+		tokenRequests := &atomic.Int32{}
+		imds := getIMDSServer(t, http.StatusBadRequest, time.Hour, tokenRequests)
+		defer imds.Close()
+		t.Setenv("PATH", "")
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodManagedIdentity})
+		require.NoError(t, err)
+		_, _, _, _, _, err = client.GetResults(context.Background(), models.Query{URL: "https://example.com", Type: models.QueryTypeJSON}, map[string]string{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to get Azure token")
+	})
+}
+
+type fakeAzureCredential struct {
+	scopes []string
+}
+
+func (c *fakeAzureCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	c.scopes = options.Scopes
+	return azcore.AccessToken{Token: "foo", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestAzureCredentials(t *testing.T) {
+	t.Run("workload identity without a federated token file should fail", func(t *testing.T) {
+		t.Setenv("AZURE_FEDERATED_TOKEN_FILE", "")
+		_, err := infinity.NewClient(context.Background(), models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodAzureWorkloadIdentity, AzureCredentials: models.AzureCredentialSettings{TenantID: "contoso.onmicrosoft.com", ClientID: "00000000-0000-0000-0000-000000000001"}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "error creating azure workload identity credential")
+	})
+	t.Run("workload identity should read the federated token file from the environment", func(t *testing.T) {
+		tokenFile := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(tokenFile, []byte("federated-token"), 0600))
+		t.Setenv("AZURE_FEDERATED_TOKEN_FILE", tokenFile)
+		t.Setenv("AZURE_TENANT_ID", "contoso.onmicrosoft.com")
+		t.Setenv("AZURE_CLIENT_ID", "00000000-0000-0000-0000-000000000001")
+		cred, err := infinity.GetAzureTokenCredential(models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodAzureWorkloadIdentity})
+		require.NoError(t, err)
+		assert.NotNil(t, cred)
+	})
+	t.Run("service principal with invalid certificate should fail", func(t *testing.T) {
+		_, err := infinity.GetAzureTokenCredential(models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodAzureServicePrincipal, AzureCredentials: models.AzureCredentialSettings{TenantID: "contoso.onmicrosoft.com", ClientID: "00000000-0000-0000-0000-000000000001"}, AzureClientCertificate: "foo"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid azure client certificate")
+	})
+	t.Run("service principal should also create the azure blob client", func(t *testing.T) {
+		client, err := infinity.NewClient(context.Background(), models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodAzureServicePrincipal, AzureCredentials: models.AzureCredentialSettings{TenantID: "contoso.onmicrosoft.com", ClientID: "00000000-0000-0000-0000-000000000001"}, AzureClientSecret: "foo", AzureBlobAccountName: "myaccount"})
+		require.NoError(t, err)
+		require.NotNil(t, client.AzureBlobClient)
+		assert.Equal(t, "https://myaccount.blob.core.windows.net/", client.AzureBlobClient.URL())
+		require.NotNil(t, client.AzureTokenProvider)
+	})
+	t.Run("credential tokens should be requested for the configured scope and sent as bearer token", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "Bearer foo", r.Header.Get("Authorization"))
+			_, _ = w.Write([]byte(`{"message":"ok"}`))
+		}))
+		defer server.Close()
+		cred := &fakeAzureCredential{}
+		settings := models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodAzureServicePrincipal}
+		httpClient := infinity.ApplyAzureTokenAuth(context.Background(), &http.Client{Transport: http.DefaultTransport}, settings, infinity.NewAzureCredentialTokenProvider(cred, "https://graph.microsoft.com"))
+		res, err := httpClient.Get(server.URL)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, []string{"https://graph.microsoft.com/.default"}, cred.scopes)
+	})
+}
+
+func TestAzureTokenProvider(t *testing.T) {
+	t.Run("should coalesce concurrent refreshes", func(t *testing.T) {
+		fetches := &atomic.Int32{}
+		release := make(chan struct{})
+		provider := infinity.NewAzureTokenProvider(func(ctx context.Context) (infinity.AzureToken, error) {
+			fetches.Add(1)
+			<-release
+			return infinity.AzureToken{AccessToken: "foo", ExpiresOn: time.Now().Add(time.Hour)}, nil
+		})
+		wg := sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				token, err := provider.GetToken(context.Background())
 				assert.NoError(t, err)
-				// Validate that the URL is set correctly
-				assert.Equal(t, tt.query.URL, req.URL.String())
-				// Check if Authorization header is present for Managed Identity
-				if tt.settings.AuthenticationMethod == models.AuthenticationMethodManagedIdentity {
-					assert.Contains(t, req.Header.Get("Authorization"), "Bearer ")
-				}
+				assert.Equal(t, "foo", token)
+			}()
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		assert.Equal(t, int32(1), fetches.Load())
+	})
+	t.Run("should refresh the token shortly before expiry", func(t *testing.T) {
+		fetches := &atomic.Int32{}
+		provider := infinity.NewAzureTokenProvider(func(ctx context.Context) (infinity.AzureToken, error) {
+			fetches.Add(1)
+			return infinity.AzureToken{AccessToken: fmt.Sprintf("token-%d", fetches.Load()), ExpiresOn: time.Now().Add(time.Minute)}, nil
+		})
+		token, err := provider.GetToken(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "token-1", token)
+		token, err = provider.GetToken(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "token-2", token)
+	})
+	t.Run("should keep the valid token when refresh fails", func(t *testing.T) {
+		fetches := &atomic.Int32{}
+		provider := infinity.NewAzureTokenProvider(func(ctx context.Context) (infinity.AzureToken, error) {
+			if fetches.Add(1) > 1 {
+				return infinity.AzureToken{}, errors.New("imds unavailable")
 			}
+			return infinity.AzureToken{AccessToken: "foo", ExpiresOn: time.Now().Add(time.Minute)}, nil
 		})
-	}
+		_, err := provider.GetToken(context.Background())
+		require.NoError(t, err)
+		token, err := provider.GetToken(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "foo", token)
+	})
+	t.Run("should stop waiting when the context is cancelled", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		provider := infinity.NewAzureTokenProvider(func(ctx context.Context) (infinity.AzureToken, error) {
+			<-release
+			return infinity.AzureToken{AccessToken: "foo", ExpiresOn: time.Now().Add(time.Hour)}, nil
+		})
+		go func() { _, _ = provider.GetToken(context.Background()) }()
+		time.Sleep(20 * time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := provider.GetToken(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
 }
@@ -0,0 +1,166 @@
+package infinity
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type retryOverrideContextKey struct{}
+
+type retryStatsContextKey struct{}
+
+// RetryStats counts the attempts made by the requests sharing a context, including the retries
+type RetryStats struct {
+	attempts atomic.Int64
+}
+
+func (s *RetryStats) Attempts() int {
+	if s == nil {
+		return 0
+	}
+	return int(s.attempts.Load())
+}
+
+// WithRetryStats returns a context which records the number of attempts made by the requests using it
+func WithRetryStats(ctx context.Context) (context.Context, *RetryStats) {
+	stats := &RetryStats{}
+	return context.WithValue(ctx, retryStatsContextKey{}, stats), stats
+}
+
+// WithRetryOverride returns a context whose requests use the retry settings of the query instead of the datasource settings
+func WithRetryOverride(ctx context.Context, override *models.RetrySettings) context.Context {
+	if override == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, retryOverrideContextKey{}, override)
+}
+
+// RetryTransport retries the requests failing with a retryable status code or a transport error.
+// The backoff grows exponentially with jitter and the Retry-After header of the response is honored.
+type RetryTransport struct {
+	Base     http.RoundTripper
+	Settings models.RetrySettings
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	override, _ := ctx.Value(retryOverrideContextKey{}).(*models.RetrySettings)
+	settings := t.Settings.WithOverride(override).WithDefaults()
+	stats, _ := ctx.Value(retryStatsContextKey{}).(*RetryStats)
+	span := trace.SpanFromContext(ctx)
+	attemptReq := req
+	for attempt := 1; ; attempt++ {
+		if stats != nil {
+			stats.attempts.Add(1)
+		}
+		res, err := t.Base.RoundTrip(attemptReq)
+		delay, retry := retryDelay(ctx, settings, req, res, err, attempt)
+		if !retry {
+			span.SetAttributes(attribute.Int("http.request.attempts", attempt))
+			return res, err
+		}
+		statusCode := 0
+		if res != nil {
+			statusCode = res.StatusCode
+			// drain the body so that the connection can be reused by the next attempt
+			_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+			res.Body.Close()
+		}
+		backend.Logger.FromContext(ctx).Debug("retrying request", "host", req.URL.Hostname(), "url_path", req.URL.Path, "method", req.Method, "attempt", attempt, "status code", statusCode, "delay_ms", delay.Milliseconds())
+		span.AddEvent("retry", trace.WithAttributes(
+			attribute.Int("http.request.attempt", attempt),
+			attribute.Int("http.response.status_code", statusCode),
+			attribute.Int64("http.retry.delay_ms", delay.Milliseconds()),
+		))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			span.SetAttributes(attribute.Int("http.request.attempts", attempt))
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		attemptReq = req.Clone(ctx)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq.Body = body
+		}
+	}
+}
+
+// retryDelay returns the delay before the next attempt and whether the request should be retried at all
+func retryDelay(ctx context.Context, settings models.RetrySettings, req *http.Request, res *http.Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= settings.MaxAttempts || !settings.IsRetryableMethod(req.Method) {
+		return 0, false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// the body can't be sent again
+		return 0, false
+	}
+	if err != nil {
+		if errors.Is(err, ErrEgressNotAllowed) || errors.Is(err, ErrPrivateNetworkBlocked) || errors.Is(err, ErrRateLimitedLocally) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return 0, false
+		}
+	} else if res == nil || !settings.IsRetryableStatus(res.StatusCode) {
+		return 0, false
+	}
+	maxDelay := time.Duration(settings.MaxDelayMs) * time.Millisecond
+	delay := backoffDelay(time.Duration(settings.InitialDelayMs)*time.Millisecond, maxDelay, attempt)
+	if res != nil {
+		if retryAfter, ok := parseRetryAfter(res.Header.Get("Retry-After")); ok {
+			if retryAfter > maxDelay {
+				// the server asked to wait longer than we are allowed to
+				return 0, false
+			}
+			delay = retryAfter
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		return 0, false
+	}
+	return delay, true
+}
+
+// backoffDelay doubles the initial delay for every retry up to the max delay and picks a random delay in the upper half of it
+func backoffDelay(initialDelay time.Duration, maxDelay time.Duration, attempt int) time.Duration {
+	delay := initialDelay
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxDelay)
+	if delay <= 1 {
+		return delay
+	}
+	return delay/2 + rand.N(delay/2)
+}
+
+// parseRetryAfter parses the Retry-After header given either in seconds or as a HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
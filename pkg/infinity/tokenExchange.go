@@ -0,0 +1,227 @@
+package infinity
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/models"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/experimental/errorsource"
+)
+
+const (
+	// tokenExchangeRefreshWindow is how long before expiry a cached exchanged token is exchanged again
+	tokenExchangeRefreshWindow = time.Minute
+	// tokenExchangeDefaultExpiry applies to the exchanged tokens issued without an expiry
+	tokenExchangeDefaultExpiry = 5 * time.Minute
+	// tokenExchangeMaxCachedTokens bounds the number of users whose exchanged tokens are cached
+	tokenExchangeMaxCachedTokens = 1000
+)
+
+const (
+	grantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+	grantTypeJWTBearer     = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	tokenTypeAccessToken   = "urn:ietf:params:oauth:token-type:access_token"
+	tokenTypeIDToken       = "urn:ietf:params:oauth:token-type:id_token"
+)
+
+type exchangedToken struct {
+	accessToken string
+	expires     time.Time
+}
+
+type tokenExchangeCall struct {
+	done  chan struct{}
+	token exchangedToken
+	err   error
+}
+
+// TokenExchanger exchanges the forwarded tokens of the users for tokens issued for the downstream API.
+// Exchanged tokens are cached per user until shortly before they expire and concurrent exchanges of the same user are coalesced.
+type TokenExchanger struct {
+	settings   models.TokenExchangeSettings
+	httpClient *http.Client
+	mu         sync.Mutex
+	tokens     map[string]exchangedToken
+	inflight   map[string]*tokenExchangeCall
+}
+
+func NewTokenExchanger(settings models.TokenExchangeSettings, httpClient *http.Client) *TokenExchanger {
+	return &TokenExchanger{settings: settings, httpClient: httpClient, tokens: map[string]exchangedToken{}, inflight: map[string]*tokenExchangeCall{}}
+}
+
+// GetToken returns the token exchanged for the subject token of the user
+func (e *TokenExchanger) GetToken(ctx context.Context, subjectToken string) (string, error) {
+	hash := sha256.Sum256([]byte(subjectToken))
+	key := hex.EncodeToString(hash[:])
+	for {
+		e.mu.Lock()
+		if token, ok := e.tokens[key]; ok && time.Now().Add(tokenExchangeRefreshWindow).Before(token.expires) {
+			e.mu.Unlock()
+			return token.accessToken, nil
+		}
+		if call, ok := e.inflight[key]; ok {
+			e.mu.Unlock()
+			select {
+			case <-call.done:
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+			if call.err == nil {
+				return call.token.accessToken, nil
+			}
+			// the request which started the exchange went away. try again with the current context
+			if (errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded)) && ctx.Err() == nil {
+				continue
+			}
+			return "", call.err
+		}
+		call := &tokenExchangeCall{done: make(chan struct{})}
+		e.inflight[key] = call
+		e.mu.Unlock()
+
+		call.token, call.err = e.exchange(ctx, subjectToken)
+		e.mu.Lock()
+		delete(e.inflight, key)
+		if call.err == nil {
+			e.store(key, call.token)
+		}
+		e.mu.Unlock()
+		close(call.done)
+		return call.token.accessToken, call.err
+	}
+}
+
+// store caches the token. Expired tokens are dropped first and the cache is emptied when it is still full
+func (e *TokenExchanger) store(key string, token exchangedToken) {
+	if len(e.tokens) >= tokenExchangeMaxCachedTokens {
+		now := time.Now()
+		for k, t := range e.tokens {
+			if now.After(t.expires) {
+				delete(e.tokens, k)
+			}
+		}
+		if len(e.tokens) >= tokenExchangeMaxCachedTokens {
+			e.tokens = map[string]exchangedToken{}
+		}
+	}
+	e.tokens[key] = token
+}
+
+func (e *TokenExchanger) exchange(ctx context.Context, subjectToken string) (exchangedToken, error) {
+	form := url.Values{}
+	scopes := strings.Join(e.settings.GetScopes(), " ")
+	switch e.settings.Type {
+	case models.TokenExchangeTypeAzureOnBehalfOf:
+		form.Set("grant_type", grantTypeJWTBearer)
+		form.Set("requested_token_use", "on_behalf_of")
+		form.Set("assertion", subjectToken)
+		form.Set("scope", scopes)
+	default:
+		form.Set("grant_type", grantTypeTokenExchange)
+		form.Set("subject_token", subjectToken)
+		form.Set("subject_token_type", tokenTypeAccessToken)
+		if e.settings.SubjectToken == models.TokenExchangeSubjectIDToken {
+			form.Set("subject_token_type", tokenTypeIDToken)
+		}
+		form.Set("requested_token_type", tokenTypeAccessToken)
+		if e.settings.Audience != "" {
+			form.Set("audience", e.settings.Audience)
+		}
+		if e.settings.Resource != "" {
+			form.Set("resource", e.settings.Resource)
+		}
+		if scopes != "" {
+			form.Set("scope", scopes)
+		}
+	}
+	form.Set("client_id", e.settings.ClientID)
+	if e.settings.ClientSecret != "" {
+		form.Set("client_secret", e.settings.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.settings.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return exchangedToken{}, err
+	}
+	req.Header.Set(headerKeyContentType, contentTypeFormURLEncoded)
+	req.Header.Set(headerKeyAccept, contentTypeJSON)
+	res, err := e.httpClient.Do(req)
+	if err != nil {
+		return exchangedToken{}, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return exchangedToken{}, err
+	}
+	out := struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	_ = json.Unmarshal(body, &out)
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices || out.AccessToken == "" {
+		if out.Error != "" {
+			return exchangedToken{}, fmt.Errorf("%w. %s %s", ErrTokenExchangeRejected, out.Error, out.ErrorDescription)
+		}
+		return exchangedToken{}, fmt.Errorf("%w. %s", ErrTokenExchangeRejected, res.Status)
+	}
+	expiry := tokenExchangeDefaultExpiry
+	if out.ExpiresIn > 0 {
+		expiry = time.Duration(out.ExpiresIn) * time.Second
+	}
+	return exchangedToken{accessToken: out.AccessToken, expires: time.Now().Add(expiry)}, nil
+}
+
+// TokenExchangeTransport replaces the forwarded identity of the user with the token exchanged for it
+type TokenExchangeTransport struct {
+	Base         http.RoundTripper
+	Exchanger    *TokenExchanger
+	SubjectToken models.TokenExchangeSubjectToken
+}
+
+func (t *TokenExchangeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	subjectToken := strings.TrimSpace(req.Header.Get(headerKeyIdToken))
+	if t.SubjectToken != models.TokenExchangeSubjectIDToken {
+		subjectToken = strings.TrimSpace(req.Header.Get(headerKeyAuthorization))
+		if len(subjectToken) > 7 && strings.EqualFold(subjectToken[:7], "bearer ") {
+			subjectToken = strings.TrimSpace(subjectToken[7:])
+		}
+	}
+	if subjectToken == "" {
+		return nil, errorsource.DownstreamError(ErrTokenExchangeNoUserToken, false)
+	}
+	token, err := t.Exchanger.GetToken(req.Context(), subjectToken)
+	if err != nil {
+		backend.Logger.FromContext(req.Context()).Error("failed to exchange the user token", "error", err.Error())
+		return nil, errorsource.DownstreamError(err, false)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set(headerKeyAuthorization, fmt.Sprintf("Bearer %s", token))
+	req.Header.Del(headerKeyIdToken)
+	return t.Base.RoundTrip(req)
+}
+
+// ApplyTokenExchange exchanges the forwarded OAuth identity of the user before the requests are sent
+func ApplyTokenExchange(ctx context.Context, httpClient *http.Client, settings models.InfinitySettings) *http.Client {
+	if IsTokenExchangeConfigured(settings) {
+		exchanger := NewTokenExchanger(settings.TokenExchange, getTokenHTTPClient(settings))
+		httpClient.Transport = &TokenExchangeTransport{Base: httpClient.Transport, Exchanger: exchanger, SubjectToken: settings.TokenExchange.SubjectToken}
+	}
+	return httpClient
+}
+
+func IsTokenExchangeConfigured(settings models.InfinitySettings) bool {
+	return settings.ForwardOauthIdentity && settings.TokenExchange.Type != ""
+}
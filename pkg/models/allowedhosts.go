@@ -0,0 +1,201 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// AllowedHostRule is a parsed entry of the allowed hosts list.
+// Rules are either URLs such as `https://*.example.com:8000-8100/api` or CIDR blocks such as `10.0.0.0/8`
+type AllowedHostRule struct {
+	Rule   string
+	Scheme string
+	// Host is the lower case host name or IP. Wildcard rules match any subdomain of the host
+	Host     string
+	Wildcard bool
+	// PortMin and PortMax are the allowed port range. Zero means the default port of the scheme
+	PortMin    int
+	PortMax    int
+	PathPrefix string
+	// CIDR rules match the URLs with an IP host in the block, regardless of the scheme, port and path
+	CIDR *net.IPNet
+}
+
+// ParseAllowedHostRule parses an entry of the allowed hosts list
+func ParseAllowedHostRule(rule string) (AllowedHostRule, error) {
+	rule = strings.TrimSpace(rule)
+	r := AllowedHostRule{Rule: rule}
+	if rule == "" {
+		return r, errors.New("empty rule")
+	}
+	if _, cidr, err := net.ParseCIDR(rule); err == nil {
+		r.CIDR = cidr
+		return r, nil
+	}
+	scheme, rest, ok := strings.Cut(rule, "://")
+	if !ok {
+		return r, errors.New("rule must start with http:// or https:// or be a CIDR block such as 10.0.0.0/8")
+	}
+	r.Scheme = strings.ToLower(scheme)
+	if r.Scheme != "http" && r.Scheme != "https" {
+		return r, fmt.Errorf("unsupported scheme %s. only http and https are supported", scheme)
+	}
+	if strings.ContainsAny(rest, "?#@") {
+		return r, errors.New("rule must not contain query, fragment or user info")
+	}
+	hostPort, rulePath, hasPath := strings.Cut(rest, "/")
+	if hasPath {
+		r.PathPrefix = "/" + rulePath
+		for _, segment := range strings.Split(rulePath, "/") {
+			if segment == "." || segment == ".." {
+				return r, errors.New("path must not contain . or .. segments")
+			}
+		}
+	}
+	host, port := hostPort, ""
+	if strings.HasPrefix(hostPort, "[") {
+		end := strings.Index(hostPort, "]")
+		if end < 0 {
+			return r, fmt.Errorf("invalid IPv6 host %s", hostPort)
+		}
+		host = hostPort[1:end]
+		if after := hostPort[end+1:]; after != "" {
+			if !strings.HasPrefix(after, ":") {
+				return r, fmt.Errorf("invalid host %s", hostPort)
+			}
+			port = after[1:]
+		}
+	} else if h, p, found := strings.Cut(hostPort, ":"); found {
+		host, port = h, p
+	}
+	host = strings.ToLower(host)
+	if strings.HasPrefix(host, "*.") {
+		r.Wildcard = true
+		host = strings.TrimPrefix(host, "*.")
+	}
+	if host == "" || strings.Contains(host, "*") {
+		return r, fmt.Errorf("invalid host %s. wildcards are only allowed as the first label such as *.example.com", hostPort)
+	}
+	r.Host = host
+	portMin, portMax, err := parseAllowedPortRange(port)
+	if err != nil {
+		return r, err
+	}
+	r.PortMin, r.PortMax = portMin, portMax
+	return r, nil
+}
+
+func parseAllowedPortRange(port string) (int, int, error) {
+	switch port {
+	case "":
+		return 0, 0, nil
+	case "*":
+		return 1, 65535, nil
+	}
+	from, to, isRange := strings.Cut(port, "-")
+	if !isRange {
+		to = from
+	}
+	portMin, err := strconv.Atoi(from)
+	if err != nil || portMin < 1 || portMin > 65535 {
+		return 0, 0, fmt.Errorf("invalid port %s", port)
+	}
+	portMax, err := strconv.Atoi(to)
+	if err != nil || portMax < portMin || portMax > 65535 {
+		return 0, 0, fmt.Errorf("invalid port range %s", port)
+	}
+	return portMin, portMax, nil
+}
+
+// Match returns true when the url is allowed by the rule
+func (r AllowedHostRule) Match(u *url.URL) bool {
+	if u == nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if r.CIDR != nil {
+		ip := net.ParseIP(host)
+		return ip != nil && r.CIDR.Contains(ip)
+	}
+	if !strings.EqualFold(u.Scheme, r.Scheme) {
+		return false
+	}
+	if r.Wildcard {
+		if !strings.HasSuffix(host, "."+r.Host) {
+			return false
+		}
+	} else if host != r.Host {
+		ruleIP, hostIP := net.ParseIP(r.Host), net.ParseIP(host)
+		if ruleIP == nil || hostIP == nil || !ruleIP.Equal(hostIP) {
+			return false
+		}
+	}
+	port := defaultPort(u.Scheme)
+	if u.Port() != "" {
+		p, err := strconv.Atoi(u.Port())
+		if err != nil {
+			return false
+		}
+		port = p
+	}
+	if r.PortMax == 0 {
+		if port != defaultPort(r.Scheme) {
+			return false
+		}
+	} else if port < r.PortMin || port > r.PortMax {
+		return false
+	}
+	prefix := strings.TrimSuffix(r.PathPrefix, "/")
+	if prefix == "" {
+		return true
+	}
+	// clean the path so that dot segments can't escape the allowed path
+	requestPath := path.Clean("/" + u.Path)
+	return requestPath == prefix || strings.HasPrefix(requestPath, prefix+"/")
+}
+
+func defaultPort(scheme string) int {
+	if strings.EqualFold(scheme, "https") {
+		return 443
+	}
+	return 80
+}
+
+// MatchAllowedHosts returns the first allowed host rule matching the url.
+// Every url is allowed when no rules are configured and the returned rule is empty in that case.
+func MatchAllowedHosts(u *url.URL, allowedHosts []string) (rule string, ok bool) {
+	if len(allowedHosts) == 0 {
+		return "", true
+	}
+	for _, allowedHost := range allowedHosts {
+		r, err := ParseAllowedHostRule(allowedHost)
+		if err != nil {
+			continue
+		}
+		if r.Match(u) {
+			return r.Rule, true
+		}
+	}
+	return "", false
+}
+
+// ParseIPOrCIDR parses a CIDR block. A single IP address is returned as a block containing only that address
+func ParseIPOrCIDR(value string) (*net.IPNet, error) {
+	value = strings.TrimSpace(value)
+	if _, cidr, err := net.ParseCIDR(value); err == nil {
+		return cidr, nil
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address or CIDR block %s", value)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
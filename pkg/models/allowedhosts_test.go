@@ -0,0 +1,101 @@
+package models_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAllowedHostRule(t *testing.T) {
+	tests := []struct {
+		rule    string
+		want    models.AllowedHostRule
+		wantErr string
+	}{
+		{rule: "https://foo.com", want: models.AllowedHostRule{Rule: "https://foo.com", Scheme: "https", Host: "foo.com"}},
+		{rule: "https://*.Example.com:8000-8100/api", want: models.AllowedHostRule{Rule: "https://*.Example.com:8000-8100/api", Scheme: "https", Host: "example.com", Wildcard: true, PortMin: 8000, PortMax: 8100, PathPrefix: "/api"}},
+		{rule: "http://[::1]:*/", want: models.AllowedHostRule{Rule: "http://[::1]:*/", Scheme: "http", Host: "::1", PortMin: 1, PortMax: 65535, PathPrefix: "/"}},
+		{rule: "", wantErr: "empty rule"},
+		{rule: "foo.com", wantErr: "rule must start with http:// or https:// or be a CIDR block such as 10.0.0.0/8"},
+		{rule: "ftp://foo.com", wantErr: "unsupported scheme ftp. only http and https are supported"},
+		{rule: "https://foo.*.com", wantErr: "invalid host foo.*.com. wildcards are only allowed as the first label such as *.example.com"},
+		{rule: "https://user@foo.com", wantErr: "rule must not contain query, fragment or user info"},
+		{rule: "https://foo.com:0", wantErr: "invalid port 0"},
+		{rule: "https://foo.com:90-80", wantErr: "invalid port range 90-80"},
+		{rule: "https://foo.com/api/../admin", wantErr: "path must not contain . or .. segments"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			got, err := models.ParseAllowedHostRule(tt.rule)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantErr, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	t.Run("cidr", func(t *testing.T) {
+		got, err := models.ParseAllowedHostRule("10.0.0.0/8")
+		require.NoError(t, err)
+		require.NotNil(t, got.CIDR)
+		assert.Equal(t, "10.0.0.0/8", got.CIDR.String())
+	})
+}
+
+func TestAllowedHostRule_Match(t *testing.T) {
+	tests := []struct {
+		rule string
+		url  string
+		want bool
+	}{
+		{rule: "https://api.example.com", url: "https://api.example.com/foo", want: true},
+		{rule: "https://api.example.com", url: "https://API.example.com", want: true},
+		{rule: "https://api.example.com", url: "https://api.example.com.evil.net", want: false},
+		{rule: "https://api.example.com", url: "http://api.example.com", want: false},
+		{rule: "https://api.example.com", url: "https://api.example.com:443", want: true},
+		{rule: "https://api.example.com", url: "https://api.example.com:8443", want: false},
+		{rule: "https://*.internal.example.com", url: "https://a.b.internal.example.com", want: true},
+		{rule: "https://*.internal.example.com", url: "https://internal.example.com", want: false},
+		{rule: "https://*.internal.example.com", url: "https://evilinternal.example.com", want: false},
+		{rule: "https://foo.com:8000-8100", url: "https://foo.com:8080", want: true},
+		{rule: "https://foo.com:8000-8100", url: "https://foo.com:8101", want: false},
+		{rule: "https://foo.com:8000-8100", url: "https://foo.com", want: false},
+		{rule: "https://foo.com/api", url: "https://foo.com/api", want: true},
+		{rule: "https://foo.com/api", url: "https://foo.com/api/users", want: true},
+		{rule: "https://foo.com/api/", url: "https://foo.com/api/users", want: true},
+		{rule: "https://foo.com/api", url: "https://foo.com/apix", want: false},
+		{rule: "https://foo.com/api", url: "https://foo.com/api/../admin", want: false},
+		{rule: "https://foo.com/api", url: "https://foo.com/api/%2e%2e/admin", want: false},
+		{rule: "http://127.0.0.1:9000", url: "http://127.0.0.1:9000/foo", want: true},
+		{rule: "http://[::1]:9000", url: "http://[0:0::1]:9000/foo", want: true},
+		{rule: "10.0.0.0/8", url: "http://10.1.2.3:8080/foo", want: true},
+		{rule: "10.0.0.0/8", url: "https://11.1.2.3", want: false},
+		{rule: "10.0.0.0/8", url: "https://10.example.com", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule+" "+tt.url, func(t *testing.T) {
+			rule, err := models.ParseAllowedHostRule(tt.rule)
+			require.NoError(t, err)
+			u, err := url.Parse(tt.url)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, rule.Match(u))
+		})
+	}
+}
+
+func TestMatchAllowedHosts(t *testing.T) {
+	u, _ := url.Parse("https://bar.com/foo")
+	rule, ok := models.MatchAllowedHosts(u, []string{"https://foo.com", "https://bar.com/foo"})
+	assert.True(t, ok)
+	assert.Equal(t, "https://bar.com/foo", rule)
+	rule, ok = models.MatchAllowedHosts(u, nil)
+	assert.True(t, ok)
+	assert.Equal(t, "", rule)
+	_, ok = models.MatchAllowedHosts(u, []string{"https://foo.com"})
+	assert.False(t, ok)
+}
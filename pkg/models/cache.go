@@ -0,0 +1,36 @@
+package models
+
+import "fmt"
+
+const (
+	CacheTTLLimitSeconds  = 86400
+	CacheMaxSizeDefaultMB = 50
+	CacheMaxSizeLimitMB   = 1024
+)
+
+// CacheSettings configures the in-memory cache of the responses of a datasource.
+// Caching is disabled unless a TTL is set in the datasource settings or in the query.
+type CacheSettings struct {
+	// TTLSeconds is how long a response is served from the cache before it is revalidated or fetched again
+	TTLSeconds int `json:"ttlSeconds,omitempty"`
+	// MaxSizeMB bounds the total size of the cached response bodies. The least recently used responses are evicted first
+	MaxSizeMB int `json:"maxSizeMb,omitempty"`
+}
+
+func (s CacheSettings) Validate() error {
+	if s.TTLSeconds < 0 || s.TTLSeconds > CacheTTLLimitSeconds {
+		return fmt.Errorf("invalid cache ttl %d. ttl must be between 0 and %d seconds", s.TTLSeconds, CacheTTLLimitSeconds)
+	}
+	if s.MaxSizeMB < 0 || s.MaxSizeMB > CacheMaxSizeLimitMB {
+		return fmt.Errorf("invalid cache max size %d. max size must be between 0 and %d MB", s.MaxSizeMB, CacheMaxSizeLimitMB)
+	}
+	return nil
+}
+
+// GetMaxSizeBytes returns the max size of the cache in bytes
+func (s CacheSettings) GetMaxSizeBytes() int64 {
+	if s.MaxSizeMB <= 0 {
+		return CacheMaxSizeDefaultMB * 1024 * 1024
+	}
+	return int64(s.MaxSizeMB) * 1024 * 1024
+}
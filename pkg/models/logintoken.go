@@ -0,0 +1,88 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	LoginTokenDefaultHeaderName     = "Authorization"
+	LoginTokenDefaultHeaderTemplate = "Bearer {{token}}"
+	LoginTokenPlaceholder           = "{{token}}"
+)
+
+// LoginTokenSettings configures the session token authentication. The token is obtained by sending the login request
+// and is sent with every request using the header template until it expires or the API rejects it.
+type LoginTokenSettings struct {
+	URL    string `json:"url,omitempty"`
+	Method string `json:"method,omitempty"`
+	// Body is the body template of the login request. {{name}} placeholders are replaced with the secure values of the same name
+	Body        string `json:"body,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	// TokenSelector is the gjson or jsonata selector of the token in the login response
+	TokenSelector string `json:"tokenSelector,omitempty"`
+	// ExpirySelector is the selector of the token expiry in the login response. The expiry can be the number of seconds
+	// the token is valid for, a unix timestamp in seconds or milliseconds or a RFC 3339 date time
+	ExpirySelector string `json:"expirySelector,omitempty"`
+	// TTLSeconds is used when the expiry selector is not set. Tokens without an expiry are kept until the API rejects them
+	TTLSeconds     int    `json:"ttlSeconds,omitempty"`
+	HeaderName     string `json:"headerName,omitempty"`
+	HeaderTemplate string `json:"headerTemplate,omitempty"`
+	// Secrets are the secure values of the body placeholders
+	Secrets map[string]string `json:"-"`
+}
+
+func (s LoginTokenSettings) Validate() error {
+	u, err := url.Parse(strings.TrimSpace(s.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("invalid/empty login url. url must be an absolute http or https url")
+	}
+	switch s.GetMethod() {
+	case http.MethodGet, http.MethodPost, http.MethodPut:
+	default:
+		return fmt.Errorf("invalid login method %s. method must be GET, POST or PUT", s.Method)
+	}
+	if strings.TrimSpace(s.TokenSelector) == "" {
+		return errors.New("invalid/empty login token selector")
+	}
+	if s.TTLSeconds < 0 {
+		return fmt.Errorf("invalid login token ttl %d. ttl must be 0 or greater", s.TTLSeconds)
+	}
+	if !strings.Contains(s.GetHeaderTemplate(), LoginTokenPlaceholder) {
+		return fmt.Errorf("invalid login token header template. template must contain %s", LoginTokenPlaceholder)
+	}
+	return nil
+}
+
+// GetMethod returns the method of the login request. Defaults to POST
+func (s LoginTokenSettings) GetMethod() string {
+	if s.Method == "" {
+		return http.MethodPost
+	}
+	return strings.ToUpper(s.Method)
+}
+
+// GetContentType returns the content type of the login request body. Defaults to JSON
+func (s LoginTokenSettings) GetContentType() string {
+	if s.ContentType == "" {
+		return "application/json"
+	}
+	return s.ContentType
+}
+
+func (s LoginTokenSettings) GetHeaderName() string {
+	if strings.TrimSpace(s.HeaderName) == "" {
+		return LoginTokenDefaultHeaderName
+	}
+	return strings.TrimSpace(s.HeaderName)
+}
+
+func (s LoginTokenSettings) GetHeaderTemplate() string {
+	if s.HeaderTemplate == "" {
+		return LoginTokenDefaultHeaderTemplate
+	}
+	return s.HeaderTemplate
+}
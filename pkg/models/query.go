@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"regexp"
 	"strings"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
@@ -43,6 +45,38 @@ const (
 	PaginationModePage   PaginationMode = "page"
 	PaginationModeCursor PaginationMode = "cursor"
 	PaginationModeList   PaginationMode = "list"
+	// PaginationModeLink follows the RFC 8288 `Link: <url>; rel="next"` response header
+	PaginationModeLink PaginationMode = "link"
+	// PaginationModeNextURL follows the next page url extracted from the response body
+	PaginationModeNextURL PaginationMode = "next_url"
+)
+
+// PaginationCursorSource is where the cursor of the next page is extracted from
+type PaginationCursorSource string
+
+const (
+	// PaginationCursorSourceBody extracts the cursor from the JSON response body with a selector
+	PaginationCursorSourceBody PaginationCursorSource = "body"
+	// PaginationCursorSourceHeader uses the value of a response header as the cursor
+	PaginationCursorSourceHeader PaginationCursorSource = "header"
+	// PaginationCursorSourceXML extracts the cursor from the XML response body with the same selectors as the XML root selector
+	PaginationCursorSourceXML PaginationCursorSource = "xml"
+	// PaginationCursorSourceRegex extracts the cursor from the raw response body with a regular expression.
+	// The first capture group is used when the expression has one, otherwise the whole match is used
+	PaginationCursorSourceRegex PaginationCursorSource = "regex"
+)
+
+type AzureBlobMatchMode string
+
+const (
+	AzureBlobMatchModeExact  AzureBlobMatchMode = "exact"
+	AzureBlobMatchModePrefix AzureBlobMatchMode = "prefix"
+	AzureBlobMatchModeGlob   AzureBlobMatchMode = "glob"
+)
+
+const (
+	AzureBlobMaxBlobsDefault = 100
+	AzureBlobMaxBlobsLimit   = 1000
 )
 
 type PaginationParamType string
@@ -118,6 +152,9 @@ type Query struct {
 	SheetRange                         string                 `json:"range,omitempty"`
 	AzBlobContainerName                string                 `json:"azContainerName,omitempty"`
 	AzBlobName                         string                 `json:"azBlobName,omitempty"`
+	AzBlobMatchMode                    AzureBlobMatchMode     `json:"azBlobMatchMode,omitempty"` // 'exact' | 'prefix' | 'glob'
+	AzBlobMaxBlobs                     int                    `json:"azBlobMaxBlobs,omitempty"`
+	AzBlobNameColumn                   string                 `json:"azBlobNameColumn,omitempty"`
 	PageMode                           PaginationMode         `json:"pagination_mode,omitempty"`
 	PageMaxPages                       int                    `json:"pagination_max_pages,omitempty"`
 	PageParamSizeFieldName             string                 `json:"pagination_param_size_field_name,omitempty"`
@@ -132,10 +169,21 @@ type Query struct {
 	PageParamCursorFieldName           string                 `json:"pagination_param_cursor_field_name,omitempty"`
 	PageParamCursorFieldType           PaginationParamType    `json:"pagination_param_cursor_field_type,omitempty"`
 	PageParamCursorFieldExtractionPath string                 `json:"pagination_param_cursor_extraction_path,omitempty"`
+	PageParamCursorExtractionSource    PaginationCursorSource `json:"pagination_param_cursor_extraction_source,omitempty"`
 	PageParamListFieldName             string                 `json:"pagination_param_list_field_name,omitempty"`
 	PageParamListFieldType             PaginationParamType    `json:"pagination_param_list_field_type,omitempty"`
 	PageParamListFieldValue            string                 `json:"pagination_param_list_value,omitempty"`
+	PageParamNextURLExtractionPath     string                 `json:"pagination_param_next_url_extraction_path,omitempty"`
+	PageStopOnShortPage                bool                   `json:"pagination_stop_on_short_page,omitempty"`
+	PageTotalCountExtractionPath       string                 `json:"pagination_total_count_extraction_path,omitempty"`
+	PageHasMoreExtractionPath          string                 `json:"pagination_has_more_extraction_path,omitempty"`
 	Transformations                    []TransformationItem   `json:"transformations,omitempty"`
+	Retry                              *RetrySettings         `json:"retry,omitempty"`
+	// CacheTTLSeconds overrides the cache ttl of the datasource for the query. 0 disables the cache for the query
+	CacheTTLSeconds *int `json:"cache_ttl_seconds,omitempty"`
+	// PartialResults returns the results of the successful pages and blobs when some of them fail. The failures are reported as warning notices.
+	// Alert queries ignore it and fail when any request fails
+	PartialResults bool `json:"partial_results,omitempty"`
 }
 
 type URLOptionKeyValuePair struct {
@@ -144,7 +192,7 @@ type URLOptionKeyValuePair struct {
 }
 
 type URLOptions struct {
-	Method               string                  `json:"method"` // 'GET' | 'POST'
+	Method               string                  `json:"method"` // 'GET' | 'POST' | 'PUT' | 'PATCH' | 'DELETE' | 'HEAD'
 	Params               []URLOptionKeyValuePair `json:"params"`
 	Headers              []URLOptionKeyValuePair `json:"headers"`
 	Body                 string                  `json:"data"`
@@ -155,6 +203,33 @@ type URLOptions struct {
 	BodyGraphQLVariables string                  `json:"body_graphql_variables"`
 }
 
+// GetMethod returns the HTTP method of the request. Queries without a method use GET
+func (o URLOptions) GetMethod() string {
+	method := strings.ToUpper(strings.TrimSpace(o.Method))
+	if method == "" {
+		return http.MethodGet
+	}
+	return method
+}
+
+// HasBody returns true when the method of the request sends the query body
+func (o URLOptions) HasBody() bool {
+	switch o.GetMethod() {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return true
+	}
+	return false
+}
+
+// IsValidMethod returns true for the HTTP methods supported by the url queries
+func IsValidMethod(method string) bool {
+	switch strings.ToUpper(strings.TrimSpace(method)) {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
 type InfinityCSVOptions struct {
 	Delimiter          string `json:"delimiter"`
 	SkipEmptyLines     bool   `json:"skip_empty_lines"`
@@ -213,7 +288,7 @@ func ApplyDefaultsToQuery(ctx context.Context, query Query) Query {
 	if query.Type == QueryTypeHTML && query.Source == "url" && query.URL == "" {
 		query.URL = "https://raw.githubusercontent.com/grafana/grafana-infinity-datasource/main/testdata/users.html"
 	}
-	if query.Source == "url" && strings.ToUpper(query.URLOptions.Method) == "POST" {
+	if query.Source == "url" && query.URLOptions.HasBody() {
 		if query.URLOptions.BodyType == "" {
 			query.URLOptions.BodyType = "raw"
 			if query.Type == QueryTypeGraphQL {
@@ -254,9 +329,6 @@ func ApplyDefaultsToQuery(ctx context.Context, query Query) Query {
 			if query.PageMaxPages <= 0 {
 				query.PageMaxPages = 1
 			}
-			if query.PageMaxPages >= 5 {
-				query.PageMaxPages = 5
-			}
 			if query.PageParamSizeFieldName == "" {
 				query.PageParamSizeFieldName = "limit"
 			}
@@ -298,6 +370,14 @@ func ApplyDefaultsToQuery(ctx context.Context, query Query) Query {
 			}
 		}
 	}
+	if query.Source == "azure-blob" && (query.AzBlobMatchMode == AzureBlobMatchModePrefix || query.AzBlobMatchMode == AzureBlobMatchModeGlob) {
+		if query.AzBlobMaxBlobs <= 0 {
+			query.AzBlobMaxBlobs = AzureBlobMaxBlobsDefault
+		}
+		if query.AzBlobMaxBlobs > AzureBlobMaxBlobsLimit {
+			query.AzBlobMaxBlobs = AzureBlobMaxBlobsLimit
+		}
+	}
 	for i, t := range query.Transformations {
 		if t.Type == "" {
 			query.Transformations[i].Type = NoOpTransformation
@@ -318,5 +398,39 @@ func LoadQuery(ctx context.Context, backendQuery backend.DataQuery, pluginContex
 		// Downstream error as user input is not correct
 		return query, errorsource.DownstreamError(errors.New("pagination_param_list_field_name cannot be empty"), false)
 	}
+	if query.PageMode == PaginationModeCursor {
+		switch query.PageParamCursorExtractionSource {
+		case "", PaginationCursorSourceBody:
+		case PaginationCursorSourceHeader, PaginationCursorSourceXML, PaginationCursorSourceRegex:
+			if strings.TrimSpace(query.PageParamCursorFieldExtractionPath) == "" {
+				return query, errorsource.DownstreamError(errors.New("pagination_param_cursor_extraction_path cannot be empty"), false)
+			}
+			if query.PageParamCursorExtractionSource == PaginationCursorSourceRegex {
+				if _, err := regexp.Compile(query.PageParamCursorFieldExtractionPath); err != nil {
+					return query, errorsource.DownstreamError(fmt.Errorf("invalid cursor extraction regex. %w", err), false)
+				}
+			}
+		default:
+			return query, errorsource.DownstreamError(fmt.Errorf("invalid cursor extraction source %s", query.PageParamCursorExtractionSource), false)
+		}
+	}
+	if query.PageMode == PaginationModeNextURL && strings.TrimSpace(query.PageParamNextURLExtractionPath) == "" {
+		// Downstream error as user input is not correct
+		return query, errorsource.DownstreamError(errors.New("pagination_param_next_url_extraction_path cannot be empty"), false)
+	}
+	if query.Source == "url" && !IsValidMethod(query.URLOptions.GetMethod()) {
+		// Downstream error as user input is not correct
+		return query, errorsource.DownstreamError(fmt.Errorf("invalid method %s", query.URLOptions.Method), false)
+	}
+	if query.CacheTTLSeconds != nil {
+		if err := (CacheSettings{TTLSeconds: *query.CacheTTLSeconds}).Validate(); err != nil {
+			return query, errorsource.DownstreamError(err, false)
+		}
+	}
+	if query.Retry != nil {
+		if err := query.Retry.Validate(); err != nil {
+			return query, errorsource.DownstreamError(err, false)
+		}
+	}
 	return ApplyMacros(ctx, query, backendQuery.TimeRange, pluginContext)
 }
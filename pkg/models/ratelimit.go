@@ -0,0 +1,48 @@
+package models
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+// RateLimitSettings is a client side token bucket limit for the requests sent to a host
+type RateLimitSettings struct {
+	// Host is a host name such as `api.example.com`, an allowed host rule such as `https://*.example.com` or `*` for every host.
+	// Host names and `*` limit every host separately while the requests to all the hosts matching a rule share a single limit
+	Host string `json:"host"`
+	// RequestsPerSecond is the rate at which the bucket refills
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	// Burst is the size of the bucket. Defaults to the requests per second rounded up
+	Burst int `json:"burst,omitempty"`
+	// MaxWaitMs is the longest a request waits for the limit before failing. Zero waits until the query deadline
+	MaxWaitMs int `json:"maxWaitMs,omitempty"`
+}
+
+func (s RateLimitSettings) Validate() error {
+	if strings.TrimSpace(s.Host) == "" {
+		return errors.New("host must not be empty")
+	}
+	if IsRateLimitRule(s.Host) {
+		if _, err := ParseAllowedHostRule(s.Host); err != nil {
+			return err
+		}
+	}
+	if s.RequestsPerSecond <= 0 {
+		return errors.New("requests per second must be greater than 0")
+	}
+	if s.Burst < 0 || s.MaxWaitMs < 0 {
+		return errors.New("burst and max wait must not be negative")
+	}
+	return nil
+}
+
+// IsRateLimitRule returns true when the host of the rate limit is an allowed host rule rather than a host name
+func IsRateLimitRule(host string) bool {
+	host = strings.TrimSpace(host)
+	if strings.Contains(host, "://") {
+		return true
+	}
+	_, _, err := net.ParseCIDR(host)
+	return err == nil
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	RetryMaxAttemptsLimit    = 10
+	RetryInitialDelayDefault = 500
+	RetryMaxDelayDefault     = 10000
+	RetryMaxDelayLimit       = 300000
+)
+
+var (
+	RetryStatusCodesDefault = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	RetryMethodsDefault     = []string{http.MethodGet, http.MethodHead}
+)
+
+// RetrySettings configures the automatic retries of failed requests.
+// Datasource settings can be overridden per query. Zero values fall back to the datasource settings and then to the defaults.
+type RetrySettings struct {
+	// MaxAttempts is the total number of attempts including the first request. Retries are disabled when it is 0 or 1
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+	// InitialDelayMs is the backoff before the first retry in milliseconds. It doubles for every further retry
+	InitialDelayMs int `json:"initialDelayMs,omitempty"`
+	// MaxDelayMs caps the backoff. Responses asking to Retry-After a longer delay are not retried
+	MaxDelayMs int `json:"maxDelayMs,omitempty"`
+	// StatusCodes are the response status codes which are retried
+	StatusCodes []int `json:"statusCodes,omitempty"`
+	// Methods are the request methods which are retried. Defaults to the idempotent GET and HEAD methods
+	Methods []string `json:"methods,omitempty"`
+}
+
+func (s RetrySettings) Validate() error {
+	if s.MaxAttempts < 0 || s.MaxAttempts > RetryMaxAttemptsLimit {
+		return fmt.Errorf("invalid retry max attempts %d. max attempts must be between 0 and %d", s.MaxAttempts, RetryMaxAttemptsLimit)
+	}
+	if s.InitialDelayMs < 0 || s.MaxDelayMs < 0 {
+		return errors.New("invalid retry delay. delays must not be negative")
+	}
+	if s.MaxDelayMs > RetryMaxDelayLimit {
+		return fmt.Errorf("invalid retry max delay %d. max delay must not exceed %d milliseconds", s.MaxDelayMs, RetryMaxDelayLimit)
+	}
+	if s.InitialDelayMs > 0 && s.MaxDelayMs > 0 && s.InitialDelayMs > s.MaxDelayMs {
+		return errors.New("invalid retry delay. initial delay must not exceed the max delay")
+	}
+	for _, statusCode := range s.StatusCodes {
+		if statusCode < 400 || statusCode > 599 {
+			return fmt.Errorf("invalid retry status code %d. only 4xx and 5xx status codes can be retried", statusCode)
+		}
+	}
+	for _, method := range s.Methods {
+		switch strings.ToUpper(strings.TrimSpace(method)) {
+		case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		default:
+			return fmt.Errorf("invalid retry method %s", method)
+		}
+	}
+	return nil
+}
+
+// WithOverride returns the settings with the non-zero fields of the override applied
+func (s RetrySettings) WithOverride(override *RetrySettings) RetrySettings {
+	if override == nil {
+		return s
+	}
+	if override.MaxAttempts > 0 {
+		s.MaxAttempts = override.MaxAttempts
+	}
+	if override.InitialDelayMs > 0 {
+		s.InitialDelayMs = override.InitialDelayMs
+	}
+	if override.MaxDelayMs > 0 {
+		s.MaxDelayMs = override.MaxDelayMs
+	}
+	if len(override.StatusCodes) > 0 {
+		s.StatusCodes = override.StatusCodes
+	}
+	if len(override.Methods) > 0 {
+		s.Methods = override.Methods
+	}
+	return s
+}
+
+// WithDefaults fills the unset fields with the default values
+func (s RetrySettings) WithDefaults() RetrySettings {
+	if s.InitialDelayMs <= 0 {
+		s.InitialDelayMs = RetryInitialDelayDefault
+	}
+	if s.MaxDelayMs <= 0 {
+		s.MaxDelayMs = max(RetryMaxDelayDefault, s.InitialDelayMs)
+	}
+	if len(s.StatusCodes) == 0 {
+		s.StatusCodes = RetryStatusCodesDefault
+	}
+	if len(s.Methods) == 0 {
+		s.Methods = RetryMethodsDefault
+	}
+	return s
+}
+
+// IsRetryableStatus returns true when responses with the status code are retried
+func (s RetrySettings) IsRetryableStatus(statusCode int) bool {
+	for _, code := range s.StatusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRetryableMethod returns true when requests with the method are retried
+func (s RetrySettings) IsRetryableMethod(method string) bool {
+	for _, m := range s.Methods {
+		if strings.EqualFold(strings.TrimSpace(m), method) {
+			return true
+		}
+	}
+	return false
+}
@@ -5,7 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/textproto"
+	"net/url"
+	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
@@ -14,21 +18,25 @@ import (
 )
 
 const (
-	AuthenticationMethodNone            = "none"
-	AuthenticationMethodBasic           = "basicAuth"
-	AuthenticationMethodApiKey          = "apiKey"
-	AuthenticationMethodBearerToken     = "bearerToken"
-	AuthenticationMethodForwardOauth    = "oauthPassThru"
-	AuthenticationMethodDigestAuth      = "digestAuth"
-	AuthenticationMethodOAuth           = "oauth2"
-	AuthenticationMethodAWS             = "aws"
-	AuthenticationMethodAzureBlob       = "azureBlob"
-	AuthenticationMethodManagedIdentity = "azureManagedIdentity"
+	AuthenticationMethodNone                  = "none"
+	AuthenticationMethodBasic                 = "basicAuth"
+	AuthenticationMethodApiKey                = "apiKey"
+	AuthenticationMethodBearerToken           = "bearerToken"
+	AuthenticationMethodForwardOauth          = "oauthPassThru"
+	AuthenticationMethodDigestAuth            = "digestAuth"
+	AuthenticationMethodOAuth                 = "oauth2"
+	AuthenticationMethodAWS                   = "aws"
+	AuthenticationMethodAzureBlob             = "azureBlob"
+	AuthenticationMethodManagedIdentity       = "azureManagedIdentity"
+	AuthenticationMethodAzureWorkloadIdentity = "azureWorkloadIdentity"
+	AuthenticationMethodAzureServicePrincipal = "azureServicePrincipal"
+	AuthenticationMethodLoginToken            = "loginToken"
 )
 
 const (
 	AuthOAuthTypeClientCredentials = "client_credentials"
 	AuthOAuthJWT                   = "jwt"
+	AuthOAuthAuthorizationCode     = "authorization_code"
 	AuthOAuthOthers                = "others"
 )
 
@@ -37,30 +45,118 @@ const (
 	ApiKeyTypeQuery  = "query"
 )
 
+const (
+	// OAuth2ClientAuthSecret authenticates the client with the client secret
+	OAuth2ClientAuthSecret = "client_secret"
+	// OAuth2ClientAuthPrivateKeyJWT authenticates the client with a JWT assertion signed by the client key as per RFC 7523
+	OAuth2ClientAuthPrivateKeyJWT = "private_key_jwt"
+	// OAuth2ClientAuthTLS authenticates the client with the TLS client certificate as per RFC 8705
+	OAuth2ClientAuthTLS = "tls_client_auth"
+)
+
 type OAuth2Settings struct {
-	OAuth2Type     string           `json:"oauth2_type,omitempty"`
-	ClientID       string           `json:"client_id,omitempty"`
-	TokenURL       string           `json:"token_url,omitempty"`
-	Email          string           `json:"email,omitempty"`
-	PrivateKeyID   string           `json:"private_key_id,omitempty"`
-	Subject        string           `json:"subject,omitempty"`
-	Scopes         []string         `json:"scopes,omitempty"`
-	AuthStyle      oauth2.AuthStyle `json:"authStyle,omitempty"`
-	ClientSecret   string
-	PrivateKey     string
-	EndpointParams map[string]string
+	OAuth2Type              string           `json:"oauth2_type,omitempty"`
+	ClientID                string           `json:"client_id,omitempty"`
+	TokenURL                string           `json:"token_url,omitempty"`
+	AuthURL                 string           `json:"auth_url,omitempty"`
+	RedirectURL             string           `json:"redirect_url,omitempty"`
+	Email                   string           `json:"email,omitempty"`
+	PrivateKeyID            string           `json:"private_key_id,omitempty"`
+	Subject                 string           `json:"subject,omitempty"`
+	Scopes                  []string         `json:"scopes,omitempty"`
+	AuthStyle               oauth2.AuthStyle `json:"authStyle,omitempty"`
+	ClientAuthMethod        string           `json:"client_auth_method,omitempty"`
+	ClientAssertionKeyID    string           `json:"client_assertion_key_id,omitempty"`
+	ClientAssertionAudience string           `json:"client_assertion_audience,omitempty"`
+	ClientSecret            string
+	ClientAssertionKey      string
+	PrivateKey              string
+	RefreshToken            string
+	EndpointParams          map[string]string
+}
+
+// GetClientAuthMethod returns the client authentication method of the client credentials flow. Defaults to the client secret
+func (s OAuth2Settings) GetClientAuthMethod() string {
+	if s.ClientAuthMethod == "" {
+		return OAuth2ClientAuthSecret
+	}
+	return s.ClientAuthMethod
 }
 
 type AWSAuthType string
 
 const (
 	AWSAuthTypeKeys AWSAuthType = "keys"
+	// AWSAuthTypeDefault uses the default credential chain of the AWS SDK. The chain includes the environment variables,
+	// the shared config files, the web identity of the EKS service account (IRSA) and the ECS and EC2 instance roles
+	AWSAuthTypeDefault AWSAuthType = "default"
+	// AWSAuthTypeWebIdentity assumes the role with the web identity token file, such as the token projected by EKS
+	AWSAuthTypeWebIdentity AWSAuthType = "webIdentity"
 )
 
 type AWSSettings struct {
 	AuthType AWSAuthType `json:"authType"`
 	Region   string      `json:"region"`
 	Service  string      `json:"service"`
+	// AssumeRoleARN is the role assumed with the credentials of the auth type, for example to access another account
+	AssumeRoleARN string `json:"assumeRoleArn,omitempty"`
+	ExternalID    string `json:"externalId,omitempty"`
+	// WebIdentityRoleARN and WebIdentityTokenFile default to the AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE environment variables set by EKS
+	WebIdentityRoleARN   string `json:"webIdentityRoleArn,omitempty"`
+	WebIdentityTokenFile string `json:"webIdentityTokenFile,omitempty"`
+}
+
+// GetAuthType returns the auth type. Defaults to the access keys
+func (s AWSSettings) GetAuthType() AWSAuthType {
+	if s.AuthType == "" {
+		return AWSAuthTypeKeys
+	}
+	return s.AuthType
+}
+
+func (s AWSSettings) Validate() error {
+	switch s.GetAuthType() {
+	case AWSAuthTypeKeys, AWSAuthTypeDefault, AWSAuthTypeWebIdentity:
+	default:
+		return fmt.Errorf("invalid AWS auth type %s. auth type must be keys, default or webIdentity", s.AuthType)
+	}
+	if s.AssumeRoleARN != "" && !isValidAWSRoleARN(s.AssumeRoleARN) {
+		return fmt.Errorf("invalid AWS assume role ARN %s. ARN must be in the format arn:aws:iam::<account>:role/<name>", s.AssumeRoleARN)
+	}
+	if s.ExternalID != "" && s.AssumeRoleARN == "" {
+		return errors.New("AWS external ID requires an assume role ARN")
+	}
+	if s.WebIdentityRoleARN != "" && !isValidAWSRoleARN(s.WebIdentityRoleARN) {
+		return fmt.Errorf("invalid AWS web identity role ARN %s. ARN must be in the format arn:aws:iam::<account>:role/<name>", s.WebIdentityRoleARN)
+	}
+	return nil
+}
+
+var awsRoleARNRegex = regexp.MustCompile(`^arn:aws[a-zA-Z-]*:iam::[0-9]{12}:role/.+$`)
+
+func isValidAWSRoleARN(arn string) bool {
+	return awsRoleARNRegex.MatchString(strings.TrimSpace(arn))
+}
+
+type AzureManagedIdentitySettings struct {
+	// Resource is the resource URI or the `.default` scope the token is requested for. Defaults to azure resource manager
+	Resource string `json:"resource,omitempty"`
+	// ClientID selects a user-assigned managed identity by its client ID
+	ClientID string `json:"clientId,omitempty"`
+	// ObjectID selects a user-assigned managed identity by its object ID
+	ObjectID string `json:"objectId,omitempty"`
+	// TenantID is the tenant used by the Azure CLI fallback
+	TenantID string `json:"tenantId,omitempty"`
+}
+
+// AzureCredentialSettings configures the azure workload identity and service principal authentication methods
+type AzureCredentialSettings struct {
+	// TenantID is the tenant of the app registration. Workload identity defaults to AZURE_TENANT_ID
+	TenantID string `json:"tenantId,omitempty"`
+	// ClientID is the client ID of the app registration. Workload identity defaults to AZURE_CLIENT_ID
+	ClientID string `json:"clientId,omitempty"`
+	// Resource is the resource URI or the `.default` scope the token is requested for. Defaults to azure resource manager
+	Resource string `json:"resource,omitempty"`
 }
 
 type ProxyType string
@@ -71,6 +167,17 @@ const (
 	ProxyTypeUrl  ProxyType = "url"
 )
 
+const (
+	MaxConcurrentQueriesDefault  = 5
+	MaxConcurrentQueriesLimit    = 50
+	PaginationMaxPagesDefault    = 5
+	PaginationMaxPagesLimit      = 1000
+	PaginationConcurrencyDefault = 3
+	PaginationConcurrencyLimit   = 20
+)
+
+var DefaultAllowedMethods = []string{http.MethodGet, http.MethodPost}
+
 type UnsecuredQueryHandlingMode string
 
 const (
@@ -92,11 +199,14 @@ type InfinitySettings struct {
 	AWSSettings              AWSSettings
 	AWSAccessKey             string
 	AWSSecretKey             string
+	AWSSessionToken          string
 	URL                      string
 	BasicAuthEnabled         bool
 	UserName                 string
 	Password                 string
 	ForwardOauthIdentity     bool
+	TokenExchange            TokenExchangeSettings
+	LoginToken               LoginTokenSettings
 	CustomHeaders            map[string]string
 	SecureQueryFields        map[string]string
 	InsecureSkipVerify       bool
@@ -110,19 +220,71 @@ type InfinitySettings struct {
 	ProxyType                ProxyType
 	ProxyUrl                 string
 	AllowedHosts             []string
+	AllowedMethods           []string
+	BlockPrivateNetworks     bool
+	PrivateNetworkExceptions []string
 	ReferenceData            []RefData
 	CustomHealthCheckEnabled bool
 	CustomHealthCheckUrl     string
 	AzureBlobAccountUrl      string
 	AzureBlobAccountName     string
 	AzureBlobAccountKey      string
+	AzureManagedIdentity     AzureManagedIdentitySettings
+	AzureCredentials         AzureCredentialSettings
+	AzureClientSecret        string
+	AzureClientCertificate   string
+	AzureClientCertPassword  string
 	UnsecuredQueryHandling   UnsecuredQueryHandlingMode
 	PathEncodedURLsEnabled   bool
+	Retry                    RetrySettings
+	RateLimits               []RateLimitSettings
+	Cache                    CacheSettings
+	MaxConcurrentQueries     int
+	PaginationMaxPages       int
+	PaginationConcurrency    int
 	// ProxyOpts is used for Secure Socks Proxy configuration
 	ProxyOpts httpclient.Options
 }
 
 func (s *InfinitySettings) Validate() error {
+	for _, allowedHost := range s.AllowedHosts {
+		if _, err := ParseAllowedHostRule(allowedHost); err != nil {
+			return fmt.Errorf("invalid allowed host %s. %s", allowedHost, err.Error())
+		}
+	}
+	for _, method := range s.AllowedMethods {
+		if !IsValidMethod(method) {
+			return fmt.Errorf("invalid allowed method %s. allowed methods must be GET, POST, PUT, PATCH, DELETE or HEAD", method)
+		}
+	}
+	for _, exception := range s.PrivateNetworkExceptions {
+		if _, err := ParseIPOrCIDR(exception); err != nil {
+			return fmt.Errorf("invalid private network exception %s. exceptions must be IP addresses or CIDR blocks such as 10.0.0.0/8", exception)
+		}
+	}
+	if err := s.Retry.Validate(); err != nil {
+		return err
+	}
+	if err := s.Cache.Validate(); err != nil {
+		return err
+	}
+	if err := s.TokenExchange.Validate(); err != nil {
+		return err
+	}
+	if s.MaxConcurrentQueries < 0 || s.MaxConcurrentQueries > MaxConcurrentQueriesLimit {
+		return fmt.Errorf("invalid max concurrent queries %d. value must be between 0 and %d. 0 uses the default", s.MaxConcurrentQueries, MaxConcurrentQueriesLimit)
+	}
+	if s.PaginationMaxPages < 0 || s.PaginationMaxPages > PaginationMaxPagesLimit {
+		return fmt.Errorf("invalid pagination max pages %d. value must be between 0 and %d. 0 uses the default", s.PaginationMaxPages, PaginationMaxPagesLimit)
+	}
+	if s.PaginationConcurrency < 0 || s.PaginationConcurrency > PaginationConcurrencyLimit {
+		return fmt.Errorf("invalid pagination concurrency %d. value must be between 0 and %d. 0 uses the default", s.PaginationConcurrency, PaginationConcurrencyLimit)
+	}
+	for _, rateLimit := range s.RateLimits {
+		if err := rateLimit.Validate(); err != nil {
+			return fmt.Errorf("invalid rate limit %s. %s", rateLimit.Host, err.Error())
+		}
+	}
 	if (s.BasicAuthEnabled || s.AuthenticationMethod == AuthenticationMethodBasic || s.AuthenticationMethod == AuthenticationMethodDigestAuth) && s.Password == "" {
 		return errors.New("invalid or empty password detected")
 	}
@@ -132,6 +294,46 @@ func (s *InfinitySettings) Validate() error {
 	if s.AuthenticationMethod == AuthenticationMethodBearerToken && s.BearerToken == "" {
 		return errors.New("invalid or empty bearer token detected")
 	}
+	if s.AuthenticationMethod == AuthenticationMethodOAuth && s.OAuth2Settings.OAuth2Type == AuthOAuthTypeClientCredentials {
+		switch s.OAuth2Settings.GetClientAuthMethod() {
+		case OAuth2ClientAuthSecret:
+		case OAuth2ClientAuthPrivateKeyJWT:
+			if strings.TrimSpace(s.OAuth2Settings.ClientID) == "" {
+				return errors.New("invalid/empty oauth2 client id")
+			}
+			if strings.TrimSpace(s.OAuth2Settings.ClientAssertionKey) == "" {
+				return errors.New("invalid/empty oauth2 client assertion key")
+			}
+		case OAuth2ClientAuthTLS:
+			if strings.TrimSpace(s.OAuth2Settings.ClientID) == "" {
+				return errors.New("invalid/empty oauth2 client id")
+			}
+			if !s.TLSClientAuth {
+				return errors.New("oauth2 tls client authentication requires the tls client certificate. enable the TLS client authentication and provide the client certificate and key")
+			}
+		default:
+			return fmt.Errorf("invalid oauth2 client auth method %s. client auth method must be client_secret, private_key_jwt or tls_client_auth", s.OAuth2Settings.ClientAuthMethod)
+		}
+	}
+	if s.AuthenticationMethod == AuthenticationMethodOAuth && s.OAuth2Settings.OAuth2Type == AuthOAuthAuthorizationCode {
+		if strings.TrimSpace(s.OAuth2Settings.ClientID) == "" {
+			return errors.New("invalid/empty oauth2 client id")
+		}
+		if strings.TrimSpace(s.OAuth2Settings.AuthURL) == "" {
+			return errors.New("invalid/empty oauth2 authorization url")
+		}
+		if strings.TrimSpace(s.OAuth2Settings.TokenURL) == "" {
+			return errors.New("invalid/empty oauth2 token url")
+		}
+		if strings.TrimSpace(s.OAuth2Settings.RedirectURL) == "" {
+			return errors.New("invalid/empty oauth2 redirect url")
+		}
+	}
+	if s.AuthenticationMethod == AuthenticationMethodLoginToken {
+		if err := s.LoginToken.Validate(); err != nil {
+			return err
+		}
+	}
 	if s.AuthenticationMethod == AuthenticationMethodAzureBlob {
 		if strings.TrimSpace(s.AzureBlobAccountName) == "" {
 			return errors.New("invalid/empty azure blob account name")
@@ -141,6 +343,35 @@ func (s *InfinitySettings) Validate() error {
 		}
 		return nil
 	}
+	if s.AuthenticationMethod == AuthenticationMethodManagedIdentity {
+		if err := s.AzureManagedIdentity.Validate(); err != nil {
+			return err
+		}
+	}
+	if s.AuthenticationMethod == AuthenticationMethodAzureWorkloadIdentity {
+		if err := s.AzureCredentials.Validate(); err != nil {
+			return err
+		}
+	}
+	if s.AuthenticationMethod == AuthenticationMethodAzureServicePrincipal {
+		if strings.TrimSpace(s.AzureCredentials.TenantID) == "" {
+			return errors.New("invalid/empty azure tenant ID")
+		}
+		if strings.TrimSpace(s.AzureCredentials.ClientID) == "" {
+			return errors.New("invalid/empty azure client ID")
+		}
+		if err := s.AzureCredentials.Validate(); err != nil {
+			return err
+		}
+		if strings.TrimSpace(s.AzureClientSecret) == "" && strings.TrimSpace(s.AzureClientCertificate) == "" {
+			return errors.New("configure either the azure client secret or the azure client certificate")
+		}
+	}
+	if s.AuthenticationMethod == AuthenticationMethodAWS {
+		if err := s.AWSSettings.Validate(); err != nil {
+			return err
+		}
+	}
 	if s.AuthenticationMethod == AuthenticationMethodAWS && s.AWSSettings.AuthType == AWSAuthTypeKeys {
 		if strings.TrimSpace(s.AWSAccessKey) == "" {
 			return errors.New("invalid/empty AWS access key")
@@ -159,6 +390,92 @@ func (s *InfinitySettings) Validate() error {
 	return nil
 }
 
+var (
+	azureGUIDRegex       = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	azureTenantNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9.-]*[a-zA-Z0-9]$`)
+)
+
+func (s AzureManagedIdentitySettings) Validate() error {
+	if !isValidAzureResource(s.Resource) {
+		return errors.New("invalid azure managed identity resource. resource must be an absolute URI such as https://graph.microsoft.com or api://my-app-id")
+	}
+	if strings.TrimSpace(s.ClientID) != "" && strings.TrimSpace(s.ObjectID) != "" {
+		return errors.New("configure either the managed identity client ID or the object ID, not both")
+	}
+	if clientID := strings.TrimSpace(s.ClientID); clientID != "" && !azureGUIDRegex.MatchString(clientID) {
+		return errors.New("invalid azure managed identity client ID. client ID must be a GUID")
+	}
+	if objectID := strings.TrimSpace(s.ObjectID); objectID != "" && !azureGUIDRegex.MatchString(objectID) {
+		return errors.New("invalid azure managed identity object ID. object ID must be a GUID")
+	}
+	if !isValidAzureTenant(s.TenantID) {
+		return errors.New("invalid azure tenant ID. tenant must be a GUID or a domain name")
+	}
+	return nil
+}
+
+func (s AzureCredentialSettings) Validate() error {
+	if !isValidAzureResource(s.Resource) {
+		return errors.New("invalid azure resource. resource must be an absolute URI such as https://graph.microsoft.com or api://my-app-id")
+	}
+	if clientID := strings.TrimSpace(s.ClientID); clientID != "" && !azureGUIDRegex.MatchString(clientID) {
+		return errors.New("invalid azure client ID. client ID must be a GUID")
+	}
+	if !isValidAzureTenant(s.TenantID) {
+		return errors.New("invalid azure tenant ID. tenant must be a GUID or a domain name")
+	}
+	return nil
+}
+
+// isValidAzureResource returns true for empty resources, which fall back to the default, and absolute URIs
+func isValidAzureResource(resource string) bool {
+	if resource = strings.TrimSpace(resource); resource == "" {
+		return true
+	}
+	u, err := url.Parse(resource)
+	return err == nil && u.Scheme != "" && (u.Host != "" || u.Opaque != "")
+}
+
+func isValidAzureTenant(tenantID string) bool {
+	if tenantID = strings.TrimSpace(tenantID); tenantID == "" {
+		return true
+	}
+	return azureGUIDRegex.MatchString(tenantID) || azureTenantNameRegex.MatchString(tenantID)
+}
+
+// GetAllowedMethods returns the HTTP methods the queries of the datasource can use. Only GET and POST are allowed by default
+func (s *InfinitySettings) GetAllowedMethods() []string {
+	if len(s.AllowedMethods) == 0 {
+		return slices.Clone(DefaultAllowedMethods)
+	}
+	methods := []string{}
+	for _, method := range s.AllowedMethods {
+		methods = append(methods, strings.ToUpper(strings.TrimSpace(method)))
+	}
+	return methods
+}
+
+// IsMethodAllowed returns true when the queries of the datasource can use the HTTP method
+func (s *InfinitySettings) IsMethodAllowed(method string) bool {
+	return slices.Contains(s.GetAllowedMethods(), strings.ToUpper(method))
+}
+
+// GetPaginationMaxPages returns the max number of pages a paginated query can fetch
+func (s *InfinitySettings) GetPaginationMaxPages() int {
+	if s.PaginationMaxPages <= 0 {
+		return PaginationMaxPagesDefault
+	}
+	return s.PaginationMaxPages
+}
+
+// GetPaginationConcurrency returns the number of pages fetched in parallel
+func (s *InfinitySettings) GetPaginationConcurrency() int {
+	if s.PaginationConcurrency <= 0 {
+		return PaginationConcurrencyDefault
+	}
+	return s.PaginationConcurrency
+}
+
 func (s *InfinitySettings) HaveSecureHeaders() bool {
 	if len(s.CustomHeaders) > 0 {
 		for k := range s.CustomHeaders {
@@ -181,29 +498,42 @@ type RefData struct {
 }
 
 type InfinitySettingsJson struct {
-	IsMock                   bool           `json:"is_mock,omitempty"`
-	AuthenticationMethod     string         `json:"auth_method,omitempty"`
-	APIKeyKey                string         `json:"apiKeyKey,omitempty"`
-	APIKeyType               string         `json:"apiKeyType,omitempty"`
-	OAuth2Settings           OAuth2Settings `json:"oauth2,omitempty"`
-	AWSSettings              AWSSettings    `json:"aws,omitempty"`
-	ForwardOauthIdentity     bool           `json:"oauthPassThru,omitempty"`
-	InsecureSkipVerify       bool           `json:"tlsSkipVerify,omitempty"`
-	ServerName               string         `json:"serverName,omitempty"`
-	TLSClientAuth            bool           `json:"tlsAuth,omitempty"`
-	TLSAuthWithCACert        bool           `json:"tlsAuthWithCACert,omitempty"`
-	TimeoutInSeconds         int64          `json:"timeoutInSeconds,omitempty"`
-	ProxyType                ProxyType      `json:"proxy_type,omitempty"`
-	ProxyUrl                 string         `json:"proxy_url,omitempty"`
-	ReferenceData            []RefData      `json:"refData,omitempty"`
-	CustomHealthCheckEnabled bool           `json:"customHealthCheckEnabled,omitempty"`
-	CustomHealthCheckUrl     string         `json:"customHealthCheckUrl,omitempty"`
-	AzureBlobAccountUrl      string         `json:"azureBlobAccountUrl,omitempty"`
-	AzureBlobAccountName     string         `json:"azureBlobAccountName,omitempty"`
-	PathEncodedURLsEnabled   bool           `json:"pathEncodedUrlsEnabled,omitempty"`
+	IsMock                   bool                         `json:"is_mock,omitempty"`
+	AuthenticationMethod     string                       `json:"auth_method,omitempty"`
+	APIKeyKey                string                       `json:"apiKeyKey,omitempty"`
+	APIKeyType               string                       `json:"apiKeyType,omitempty"`
+	OAuth2Settings           OAuth2Settings               `json:"oauth2,omitempty"`
+	AWSSettings              AWSSettings                  `json:"aws,omitempty"`
+	ForwardOauthIdentity     bool                         `json:"oauthPassThru,omitempty"`
+	TokenExchange            TokenExchangeSettings        `json:"tokenExchange,omitempty"`
+	LoginToken               LoginTokenSettings           `json:"loginToken,omitempty"`
+	InsecureSkipVerify       bool                         `json:"tlsSkipVerify,omitempty"`
+	ServerName               string                       `json:"serverName,omitempty"`
+	TLSClientAuth            bool                         `json:"tlsAuth,omitempty"`
+	TLSAuthWithCACert        bool                         `json:"tlsAuthWithCACert,omitempty"`
+	TimeoutInSeconds         int64                        `json:"timeoutInSeconds,omitempty"`
+	ProxyType                ProxyType                    `json:"proxy_type,omitempty"`
+	ProxyUrl                 string                       `json:"proxy_url,omitempty"`
+	ReferenceData            []RefData                    `json:"refData,omitempty"`
+	CustomHealthCheckEnabled bool                         `json:"customHealthCheckEnabled,omitempty"`
+	CustomHealthCheckUrl     string                       `json:"customHealthCheckUrl,omitempty"`
+	AzureBlobAccountUrl      string                       `json:"azureBlobAccountUrl,omitempty"`
+	AzureBlobAccountName     string                       `json:"azureBlobAccountName,omitempty"`
+	AzureManagedIdentity     AzureManagedIdentitySettings `json:"azureManagedIdentity,omitempty"`
+	AzureCredentials         AzureCredentialSettings      `json:"azureCredentials,omitempty"`
+	PathEncodedURLsEnabled   bool                         `json:"pathEncodedUrlsEnabled,omitempty"`
+	Retry                    RetrySettings                `json:"retry,omitempty"`
+	RateLimits               []RateLimitSettings          `json:"rateLimits,omitempty"`
+	Cache                    CacheSettings                `json:"cache,omitempty"`
+	MaxConcurrentQueries     int                          `json:"maxConcurrentQueries,omitempty"`
+	PaginationMaxPages       int                          `json:"paginationMaxPages,omitempty"`
+	PaginationConcurrency    int                          `json:"paginationConcurrency,omitempty"`
 	// Security
-	AllowedHosts           []string                   `json:"allowedHosts,omitempty"`
-	UnsecuredQueryHandling UnsecuredQueryHandlingMode `json:"unsecuredQueryHandling,omitempty"`
+	AllowedHosts             []string                   `json:"allowedHosts,omitempty"`
+	AllowedMethods           []string                   `json:"allowedMethods,omitempty"`
+	UnsecuredQueryHandling   UnsecuredQueryHandlingMode `json:"unsecuredQueryHandling,omitempty"`
+	BlockPrivateNetworks     bool                       `json:"blockPrivateNetworks,omitempty"`
+	PrivateNetworkExceptions []string                   `json:"privateNetworkExceptions,omitempty"`
 }
 
 func LoadSettings(ctx context.Context, config backend.DataSourceInstanceSettings) (settings InfinitySettings, err error) {
@@ -236,6 +566,8 @@ func LoadSettings(ctx context.Context, config backend.DataSourceInstanceSettings
 			settings.ApiKeyValue = val
 		}
 		settings.ForwardOauthIdentity = infJson.ForwardOauthIdentity
+		settings.TokenExchange = infJson.TokenExchange
+		settings.LoginToken = infJson.LoginToken
 		settings.InsecureSkipVerify = infJson.InsecureSkipVerify
 		settings.ServerName = infJson.ServerName
 		settings.TLSClientAuth = infJson.TLSClientAuth
@@ -244,6 +576,12 @@ func LoadSettings(ctx context.Context, config backend.DataSourceInstanceSettings
 		settings.ProxyType = infJson.ProxyType
 		settings.ProxyUrl = infJson.ProxyUrl
 		settings.PathEncodedURLsEnabled = infJson.PathEncodedURLsEnabled
+		settings.Retry = infJson.Retry
+		settings.RateLimits = infJson.RateLimits
+		settings.Cache = infJson.Cache
+		settings.MaxConcurrentQueries = infJson.MaxConcurrentQueries
+		settings.PaginationMaxPages = infJson.PaginationMaxPages
+		settings.PaginationConcurrency = infJson.PaginationConcurrency
 		if settings.ProxyType == "" {
 			settings.ProxyType = ProxyTypeEnv
 		}
@@ -257,21 +595,35 @@ func LoadSettings(ctx context.Context, config backend.DataSourceInstanceSettings
 		if len(infJson.AllowedHosts) > 0 {
 			settings.AllowedHosts = infJson.AllowedHosts
 		}
+		settings.AllowedMethods = infJson.AllowedMethods
+		settings.BlockPrivateNetworks = infJson.BlockPrivateNetworks
+		settings.PrivateNetworkExceptions = infJson.PrivateNetworkExceptions
 	}
 	settings.ReferenceData = infJson.ReferenceData
 	settings.CustomHealthCheckEnabled = infJson.CustomHealthCheckEnabled
 	settings.CustomHealthCheckUrl = infJson.CustomHealthCheckUrl
 	settings.AzureBlobAccountUrl = infJson.AzureBlobAccountUrl
 	settings.AzureBlobAccountName = infJson.AzureBlobAccountName
+	settings.AzureManagedIdentity = infJson.AzureManagedIdentity
+	settings.AzureCredentials = infJson.AzureCredentials
 	if val, ok := config.DecryptedSecureJSONData["basicAuthPassword"]; ok {
 		settings.Password = val
 	}
 	if val, ok := config.DecryptedSecureJSONData["oauth2ClientSecret"]; ok {
 		settings.OAuth2Settings.ClientSecret = val
 	}
+	if val, ok := config.DecryptedSecureJSONData["oauth2ClientAssertionKey"]; ok {
+		settings.OAuth2Settings.ClientAssertionKey = val
+	}
 	if val, ok := config.DecryptedSecureJSONData["oauth2JWTPrivateKey"]; ok {
 		settings.OAuth2Settings.PrivateKey = val
 	}
+	if val, ok := config.DecryptedSecureJSONData["oauth2RefreshToken"]; ok {
+		settings.OAuth2Settings.RefreshToken = val
+	}
+	if val, ok := config.DecryptedSecureJSONData["tokenExchangeClientSecret"]; ok {
+		settings.TokenExchange.ClientSecret = val
+	}
 	if val, ok := config.DecryptedSecureJSONData["tlsCACert"]; ok {
 		settings.TLSCACert = val
 	}
@@ -290,12 +642,25 @@ func LoadSettings(ctx context.Context, config backend.DataSourceInstanceSettings
 	if val, ok := config.DecryptedSecureJSONData["awsSecretKey"]; ok {
 		settings.AWSSecretKey = val
 	}
+	if val, ok := config.DecryptedSecureJSONData["awsSessionToken"]; ok {
+		settings.AWSSessionToken = val
+	}
 	if val, ok := config.DecryptedSecureJSONData["azureBlobAccountKey"]; ok {
 		settings.AzureBlobAccountKey = val
 	}
+	if val, ok := config.DecryptedSecureJSONData["azureClientSecret"]; ok {
+		settings.AzureClientSecret = val
+	}
+	if val, ok := config.DecryptedSecureJSONData["azureClientCertificate"]; ok {
+		settings.AzureClientCertificate = val
+	}
+	if val, ok := config.DecryptedSecureJSONData["azureClientCertificatePassword"]; ok {
+		settings.AzureClientCertPassword = val
+	}
 	settings.CustomHeaders = GetSecrets(config, "httpHeaderName", "httpHeaderValue")
 	settings.SecureQueryFields = GetSecrets(config, "secureQueryName", "secureQueryValue")
 	settings.OAuth2Settings.EndpointParams = GetSecrets(config, "oauth2EndPointParamsName", "oauth2EndPointParamsValue")
+	settings.LoginToken.Secrets = GetSecrets(config, "loginTokenSecretName", "loginTokenSecretValue")
 	if settings.AuthenticationMethod == "" {
 		settings.AuthenticationMethod = AuthenticationMethodNone
 		if settings.BasicAuthEnabled {
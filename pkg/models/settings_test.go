@@ -26,6 +26,9 @@ func TestLoadSettings(t *testing.T) {
 				OAuth2Settings: models.OAuth2Settings{
 					EndpointParams: map[string]string{},
 				},
+				LoginToken: models.LoginTokenSettings{
+					Secrets: map[string]string{},
+				},
 				CustomHeaders:     map[string]string{},
 				SecureQueryFields: map[string]string{},
 			},
@@ -66,6 +69,9 @@ func TestLoadSettings(t *testing.T) {
 				OAuth2Settings: models.OAuth2Settings{
 					EndpointParams: map[string]string{},
 				},
+				LoginToken: models.LoginTokenSettings{
+					Secrets: map[string]string{},
+				},
 				CustomHeaders: map[string]string{
 					"header1": "headervalue1",
 				},
@@ -105,6 +111,9 @@ func TestLoadSettings(t *testing.T) {
 				OAuth2Settings: models.OAuth2Settings{
 					EndpointParams: map[string]string{},
 				},
+				LoginToken: models.LoginTokenSettings{
+					Secrets: map[string]string{},
+				},
 				CustomHeaders: map[string]string{
 					"header1": "headervalue1",
 				},
@@ -150,18 +159,65 @@ func TestAllSettingsAgainstFrontEnd(t *testing.T) {
 			"tlsAuthWithCACert": true,
 			"tlsSkipVerify"    : true,
 			"oauth2EndPointParamsName1":"resource",
+			"loginTokenSecretName1":"password",
 			"oauth2EndPointParamsName2":"name",
 			"oauthPassThru": true,
 			"proxy_type" : "url",
 			"proxy_url" : "https://foo.com",
 			"allowedHosts": ["host1","host2"],
+			"allowedMethods": ["GET","PUT"],
+			"blockPrivateNetworks": true,
+			"privateNetworkExceptions": ["10.0.0.0/8"],
+			"retry" : {
+				"maxAttempts" 	: 3,
+				"initialDelayMs": 100,
+				"statusCodes" 	: [429, 503],
+				"methods" 		: ["GET", "POST"]
+			},
+			"maxConcurrentQueries" : 8,
+			"paginationMaxPages" : 20,
+			"paginationConcurrency" : 4,
+			"rateLimits" : [
+				{ "host" : "api.example.com", "requestsPerSecond" : 5, "burst" : 10 },
+				{ "host" : "https://*.example.com", "requestsPerSecond" : 0.5, "maxWaitMs" : 2000 }
+			],
+			"cache" : {
+				"ttlSeconds" : 120,
+				"maxSizeMb"  : 10
+			},
+			"tokenExchange" : {
+				"type"     : "rfc8693",
+				"tokenUrl" : "https://sts.example.com/token",
+				"clientId" : "myTokenExchangeClientID",
+				"audience" : "https://api.example.com",
+				"scopes"   : ["read"]
+			},
+			"loginToken" : {
+				"url"            : "https://foo.com/login",
+				"body"           : "{\"password\":\"{{password}}\"}",
+				"tokenSelector"  : "data.token",
+				"ttlSeconds"     : 600,
+				"headerName"     : "X-Session",
+				"headerTemplate" : "Token {{token}}"
+			},
 			"customHealthCheckEnabled" : true,
 			"customHealthCheckUrl" : "https://foo-check/",
 			"unsecuredQueryHandling" : "deny",
+			"azureCredentials" : {
+				"tenantId" 	: "00000000-0000-0000-0000-000000000002",
+				"clientId" 	: "00000000-0000-0000-0000-000000000003"
+			},
+			"azureManagedIdentity" : {
+				"resource" 	: "https://graph.microsoft.com",
+				"clientId" 	: "00000000-0000-0000-0000-000000000001",
+				"tenantId" 	: "contoso.onmicrosoft.com"
+			},
 			"aws" : {
 				"authType" 	: "keys",
 				"region" 	: "region1",
-				"service" 	: "service1"
+				"service" 	: "service1",
+				"assumeRoleArn" : "arn:aws:iam::123456789012:role/infinity",
+				"externalId" : "externalId1"
 			},
 			"oauth2" : {
 				"client_id":"myClientID",
@@ -169,24 +225,37 @@ func TestAllSettingsAgainstFrontEnd(t *testing.T) {
 				"private_key_id":"saturn",
 				"subject":"mySubject",
 				"token_url":"TOKEN_URL",
+				"auth_url":"AUTH_URL",
+				"redirect_url":"REDIRECT_URL",
+				"client_auth_method":"private_key_jwt",
+				"client_assertion_key_id":"myClientAssertionKeyID",
+				"client_assertion_audience":"CLIENT_ASSERTION_AUDIENCE",
 				"scopes":["scope1","scope2"]
 			}
 		}`),
 		DecryptedSecureJSONData: map[string]string{
-			"tlsCACert":                  "myTlsCACert",
-			"tlsClientCert":              "myTlsClientCert",
-			"tlsClientKey":               "myTlsClientKey",
-			"basicAuthPassword":          "password",
-			"secureQueryValue1":          "bar",
-			"httpHeaderValue1":           "headervalue1",
-			"apiKeyValue":                "earth",
-			"bearerToken":                "myBearerToken",
-			"awsAccessKey":               "awsAccessKey1",
-			"awsSecretKey":               "awsSecretKey1",
-			"oauth2ClientSecret":         "myOauth2ClientSecret",
-			"oauth2JWTPrivateKey":        "myOauth2JWTPrivateKey",
-			"oauth2EndPointParamsValue1": "Resource1",
-			"oauth2EndPointParamsValue2": "Resource2",
+			"tlsCACert":                      "myTlsCACert",
+			"tlsClientCert":                  "myTlsClientCert",
+			"tlsClientKey":                   "myTlsClientKey",
+			"basicAuthPassword":              "password",
+			"secureQueryValue1":              "bar",
+			"httpHeaderValue1":               "headervalue1",
+			"apiKeyValue":                    "earth",
+			"bearerToken":                    "myBearerToken",
+			"awsAccessKey":                   "awsAccessKey1",
+			"awsSecretKey":                   "awsSecretKey1",
+			"awsSessionToken":                "awsSessionToken1",
+			"oauth2ClientSecret":             "myOauth2ClientSecret",
+			"oauth2JWTPrivateKey":            "myOauth2JWTPrivateKey",
+			"oauth2RefreshToken":             "myOauth2RefreshToken",
+			"oauth2ClientAssertionKey":       "myOauth2ClientAssertionKey",
+			"oauth2EndPointParamsValue1":     "Resource1",
+			"oauth2EndPointParamsValue2":     "Resource2",
+			"azureClientSecret":              "myAzureClientSecret",
+			"azureClientCertificate":         "myAzureClientCertificate",
+			"azureClientCertificatePassword": "myAzureClientCertificatePassword",
+			"tokenExchangeClientSecret":      "myTokenExchangeClientSecret",
+			"loginTokenSecretValue1":         "myLoginPassword",
 		},
 	}
 	gotSettings, err := models.LoadSettings(context.Background(), config)
@@ -210,26 +279,48 @@ func TestAllSettingsAgainstFrontEnd(t *testing.T) {
 		TLSClientKey:         "myTlsClientKey",
 		AWSAccessKey:         "awsAccessKey1",
 		AWSSecretKey:         "awsSecretKey1",
+		AWSSessionToken:      "awsSessionToken1",
 		AWSSettings: models.AWSSettings{
-			AuthType: models.AWSAuthTypeKeys,
-			Service:  "service1",
-			Region:   "region1",
+			AuthType:      models.AWSAuthTypeKeys,
+			Service:       "service1",
+			Region:        "region1",
+			AssumeRoleARN: "arn:aws:iam::123456789012:role/infinity",
+			ExternalID:    "externalId1",
 		},
 		OAuth2Settings: models.OAuth2Settings{
-			ClientID:     "myClientID",
-			OAuth2Type:   "client_credentials",
-			ClientSecret: "myOauth2ClientSecret",
-			PrivateKey:   "myOauth2JWTPrivateKey",
-			Email:        "myEmail",
-			PrivateKeyID: "saturn",
-			Subject:      "mySubject",
-			TokenURL:     "TOKEN_URL",
-			Scopes:       []string{"scope1", "scope2"},
+			ClientID:                "myClientID",
+			OAuth2Type:              "client_credentials",
+			ClientSecret:            "myOauth2ClientSecret",
+			PrivateKey:              "myOauth2JWTPrivateKey",
+			Email:                   "myEmail",
+			PrivateKeyID:            "saturn",
+			Subject:                 "mySubject",
+			TokenURL:                "TOKEN_URL",
+			AuthURL:                 "AUTH_URL",
+			RedirectURL:             "REDIRECT_URL",
+			RefreshToken:            "myOauth2RefreshToken",
+			Scopes:                  []string{"scope1", "scope2"},
+			ClientAuthMethod:        models.OAuth2ClientAuthPrivateKeyJWT,
+			ClientAssertionKeyID:    "myClientAssertionKeyID",
+			ClientAssertionAudience: "CLIENT_ASSERTION_AUDIENCE",
+			ClientAssertionKey:      "myOauth2ClientAssertionKey",
 			EndpointParams: map[string]string{
 				"resource": "Resource1",
 				"name":     "Resource2",
 			},
 		},
+		AzureManagedIdentity: models.AzureManagedIdentitySettings{
+			Resource: "https://graph.microsoft.com",
+			ClientID: "00000000-0000-0000-0000-000000000001",
+			TenantID: "contoso.onmicrosoft.com",
+		},
+		AzureCredentials: models.AzureCredentialSettings{
+			TenantID: "00000000-0000-0000-0000-000000000002",
+			ClientID: "00000000-0000-0000-0000-000000000003",
+		},
+		AzureClientSecret:        "myAzureClientSecret",
+		AzureClientCertificate:   "myAzureClientCertificate",
+		AzureClientCertPassword:  "myAzureClientCertificatePassword",
 		BearerToken:              "myBearerToken",
 		ApiKeyKey:                "hello",
 		ApiKeyType:               "query",
@@ -238,6 +329,35 @@ func TestAllSettingsAgainstFrontEnd(t *testing.T) {
 		ProxyType:                models.ProxyTypeUrl,
 		ProxyUrl:                 "https://foo.com",
 		AllowedHosts:             []string{"host1", "host2"},
+		AllowedMethods:           []string{"GET", "PUT"},
+		BlockPrivateNetworks:     true,
+		PrivateNetworkExceptions: []string{"10.0.0.0/8"},
+		Retry:                    models.RetrySettings{MaxAttempts: 3, InitialDelayMs: 100, StatusCodes: []int{429, 503}, Methods: []string{"GET", "POST"}},
+		MaxConcurrentQueries:     8,
+		PaginationMaxPages:       20,
+		PaginationConcurrency:    4,
+		RateLimits: []models.RateLimitSettings{
+			{Host: "api.example.com", RequestsPerSecond: 5, Burst: 10},
+			{Host: "https://*.example.com", RequestsPerSecond: 0.5, MaxWaitMs: 2000},
+		},
+		Cache: models.CacheSettings{TTLSeconds: 120, MaxSizeMB: 10},
+		LoginToken: models.LoginTokenSettings{
+			URL:            "https://foo.com/login",
+			Body:           `{"password":"{{password}}"}`,
+			TokenSelector:  "data.token",
+			TTLSeconds:     600,
+			HeaderName:     "X-Session",
+			HeaderTemplate: "Token {{token}}",
+			Secrets:        map[string]string{"password": "myLoginPassword"},
+		},
+		TokenExchange: models.TokenExchangeSettings{
+			Type:         models.TokenExchangeTypeRFC8693,
+			TokenURL:     "https://sts.example.com/token",
+			ClientID:     "myTokenExchangeClientID",
+			Audience:     "https://api.example.com",
+			Scopes:       []string{"read"},
+			ClientSecret: "myTokenExchangeClientSecret",
+		},
 		UserName:                 "user",
 		Password:                 "password",
 		TimeoutInSeconds:         30,
@@ -357,6 +477,116 @@ func TestInfinitySettings_Validate(t *testing.T) {
 			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodNone, CustomHeaders: map[string]string{"A": "B", "C": "D"}},
 			wantErr:  errors.New("configure allowed hosts in the authentication section"),
 		},
+		{
+			name:     "invalid private network exception",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodNone, BlockPrivateNetworks: true, PrivateNetworkExceptions: []string{"10.0.0.1", "10.0.0.0/8", "foo"}},
+			wantErr:  errors.New("invalid private network exception foo. exceptions must be IP addresses or CIDR blocks such as 10.0.0.0/8"),
+		},
+		{
+			name:     "invalid retry max attempts",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodNone, Retry: models.RetrySettings{MaxAttempts: 20}},
+			wantErr:  errors.New("invalid retry max attempts 20. max attempts must be between 0 and 10"),
+		},
+		{
+			name:     "invalid retry delay",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodNone, Retry: models.RetrySettings{MaxAttempts: 3, InitialDelayMs: 2000, MaxDelayMs: 1000}},
+			wantErr:  errors.New("invalid retry delay. initial delay must not exceed the max delay"),
+		},
+		{
+			name:     "invalid retry status code",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodNone, Retry: models.RetrySettings{MaxAttempts: 3, StatusCodes: []int{503, 200}}},
+			wantErr:  errors.New("invalid retry status code 200. only 4xx and 5xx status codes can be retried"),
+		},
+		{
+			name:     "invalid retry method",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodNone, Retry: models.RetrySettings{MaxAttempts: 3, Methods: []string{"GET", "FOO"}}},
+			wantErr:  errors.New("invalid retry method FOO"),
+		},
+		{
+			name:     "invalid max concurrent queries",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodNone, MaxConcurrentQueries: 100},
+			wantErr:  errors.New("invalid max concurrent queries 100. value must be between 0 and 50. 0 uses the default"),
+		},
+		{
+			name:     "invalid pagination max pages",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodNone, PaginationMaxPages: 5000},
+			wantErr:  errors.New("invalid pagination max pages 5000. value must be between 0 and 1000. 0 uses the default"),
+		},
+		{
+			name:     "invalid pagination concurrency",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodNone, PaginationConcurrency: -1},
+			wantErr:  errors.New("invalid pagination concurrency -1. value must be between 0 and 20. 0 uses the default"),
+		},
+		{
+			name:     "invalid cache ttl",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodNone, Cache: models.CacheSettings{TTLSeconds: -1}},
+			wantErr:  errors.New("invalid cache ttl -1. ttl must be between 0 and 86400 seconds"),
+		},
+		{
+			name:     "invalid cache max size",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodNone, Cache: models.CacheSettings{TTLSeconds: 60, MaxSizeMB: 2048}},
+			wantErr:  errors.New("invalid cache max size 2048. max size must be between 0 and 1024 MB"),
+		},
+		{
+			name:     "oauth2 authorization code without redirect url",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodOAuth, AllowedHosts: []string{"https://foo.com"}, OAuth2Settings: models.OAuth2Settings{OAuth2Type: models.AuthOAuthAuthorizationCode, ClientID: "client", AuthURL: "https://foo.com/authorize", TokenURL: "https://foo.com/token"}},
+			wantErr:  errors.New("invalid/empty oauth2 redirect url"),
+		},
+		{
+			name:     "token exchange on-behalf-of without client secret",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodForwardOauth, ForwardOauthIdentity: true, TokenExchange: models.TokenExchangeSettings{Type: models.TokenExchangeTypeAzureOnBehalfOf, TokenURL: "https://login.microsoftonline.com/tenant/oauth2/v2.0/token", ClientID: "client", Scopes: []string{"api://my-api/.default"}}},
+			wantErr:  errors.New("invalid/empty token exchange client secret. the on-behalf-of flow requires a client secret"),
+		},
+		{
+			name:     "oauth2 tls client authentication without the tls client certificate",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodOAuth, AllowedHosts: []string{"https://foo.com"}, OAuth2Settings: models.OAuth2Settings{OAuth2Type: models.AuthOAuthTypeClientCredentials, ClientAuthMethod: models.OAuth2ClientAuthTLS, ClientID: "client", TokenURL: "https://foo.com/token"}},
+			wantErr:  errors.New("oauth2 tls client authentication requires the tls client certificate. enable the TLS client authentication and provide the client certificate and key"),
+		},
+		{
+			name:     "oauth2 private key jwt without key",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodOAuth, AllowedHosts: []string{"https://foo.com"}, OAuth2Settings: models.OAuth2Settings{OAuth2Type: models.AuthOAuthTypeClientCredentials, ClientAuthMethod: models.OAuth2ClientAuthPrivateKeyJWT, ClientID: "client", TokenURL: "https://foo.com/token"}},
+			wantErr:  errors.New("invalid/empty oauth2 client assertion key"),
+		},
+		{
+			name:     "login token without token selector",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodLoginToken, AllowedHosts: []string{"https://foo.com"}, LoginToken: models.LoginTokenSettings{URL: "https://foo.com/login"}},
+			wantErr:  errors.New("invalid/empty login token selector"),
+		},
+		{
+			name:     "login token header template without token placeholder",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodLoginToken, AllowedHosts: []string{"https://foo.com"}, LoginToken: models.LoginTokenSettings{URL: "https://foo.com/login", TokenSelector: "token", HeaderTemplate: "Token"}},
+			wantErr:  errors.New("invalid login token header template. template must contain {{token}}"),
+		},
+		{
+			name:     "aws invalid assume role arn",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodAWS, AllowedHosts: []string{"https://foo.com"}, AWSSettings: models.AWSSettings{AuthType: models.AWSAuthTypeDefault, AssumeRoleARN: "infinity"}},
+			wantErr:  errors.New("invalid AWS assume role ARN infinity. ARN must be in the format arn:aws:iam::<account>:role/<name>"),
+		},
+		{
+			name:     "aws external id without assume role",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodAWS, AllowedHosts: []string{"https://foo.com"}, AWSSettings: models.AWSSettings{AuthType: models.AWSAuthTypeDefault, ExternalID: "externalId1"}},
+			wantErr:  errors.New("AWS external ID requires an assume role ARN"),
+		},
+		{
+			name:     "invalid allowed method",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodNone, AllowedMethods: []string{"GET", "TRACE"}},
+			wantErr:  errors.New("invalid allowed method TRACE. allowed methods must be GET, POST, PUT, PATCH, DELETE or HEAD"),
+		},
+		{
+			name:     "invalid rate limit rate",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodNone, RateLimits: []models.RateLimitSettings{{Host: "api.example.com"}}},
+			wantErr:  errors.New("invalid rate limit api.example.com. requests per second must be greater than 0"),
+		},
+		{
+			name:     "invalid rate limit rule",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodNone, RateLimits: []models.RateLimitSettings{{Host: "ftp://example.com", RequestsPerSecond: 1}}},
+			wantErr:  errors.New("invalid rate limit ftp://example.com. unsupported scheme ftp. only http and https are supported"),
+		},
+		{
+			name:     "invalid allowed host rule",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodNone, AllowedHosts: []string{"https://foo.com", "foo.com"}},
+			wantErr:  errors.New("invalid allowed host foo.com. rule must start with http:// or https:// or be a CIDR block such as 10.0.0.0/8"),
+		},
 		{
 			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodBasic},
 			wantErr:  errors.New("invalid or empty password detected"),
@@ -389,6 +619,63 @@ func TestInfinitySettings_Validate(t *testing.T) {
 			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodBearerToken, BearerToken: "foo"},
 			wantErr:  errors.New("configure allowed hosts in the authentication section"),
 		},
+		{
+			name:     "managed identity with resource, client ID and tenant",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodManagedIdentity, AllowedHosts: []string{"https://graph.microsoft.com"}, AzureManagedIdentity: models.AzureManagedIdentitySettings{Resource: "api://my-app/.default", ClientID: "00000000-0000-0000-0000-000000000001", TenantID: "00000000-0000-0000-0000-000000000002"}},
+		},
+		{
+			name:     "managed identity with invalid resource",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodManagedIdentity, AzureManagedIdentity: models.AzureManagedIdentitySettings{Resource: "graph.microsoft.com"}},
+			wantErr:  errors.New("invalid azure managed identity resource. resource must be an absolute URI such as https://graph.microsoft.com or api://my-app-id"),
+		},
+		{
+			name:     "managed identity with both client ID and object ID",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodManagedIdentity, AzureManagedIdentity: models.AzureManagedIdentitySettings{ClientID: "00000000-0000-0000-0000-000000000001", ObjectID: "00000000-0000-0000-0000-000000000002"}},
+			wantErr:  errors.New("configure either the managed identity client ID or the object ID, not both"),
+		},
+		{
+			name:     "managed identity with invalid client ID",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodManagedIdentity, AzureManagedIdentity: models.AzureManagedIdentitySettings{ClientID: "foo"}},
+			wantErr:  errors.New("invalid azure managed identity client ID. client ID must be a GUID"),
+		},
+		{
+			name:     "managed identity with invalid object ID",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodManagedIdentity, AzureManagedIdentity: models.AzureManagedIdentitySettings{ObjectID: "foo"}},
+			wantErr:  errors.New("invalid azure managed identity object ID. object ID must be a GUID"),
+		},
+		{
+			name:     "managed identity with invalid tenant",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodManagedIdentity, AzureManagedIdentity: models.AzureManagedIdentitySettings{TenantID: "foo bar"}},
+			wantErr:  errors.New("invalid azure tenant ID. tenant must be a GUID or a domain name"),
+		},
+		{
+			name:     "workload identity with settings from the environment",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodAzureWorkloadIdentity, AllowedHosts: []string{"https://management.azure.com"}},
+		},
+		{
+			name:     "workload identity with invalid client ID",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodAzureWorkloadIdentity, AzureCredentials: models.AzureCredentialSettings{ClientID: "foo"}},
+			wantErr:  errors.New("invalid azure client ID. client ID must be a GUID"),
+		},
+		{
+			name:     "service principal without tenant",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodAzureServicePrincipal, AzureClientSecret: "foo"},
+			wantErr:  errors.New("invalid/empty azure tenant ID"),
+		},
+		{
+			name:     "service principal without client ID",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodAzureServicePrincipal, AzureCredentials: models.AzureCredentialSettings{TenantID: "contoso.onmicrosoft.com"}, AzureClientSecret: "foo"},
+			wantErr:  errors.New("invalid/empty azure client ID"),
+		},
+		{
+			name:     "service principal without secret or certificate",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodAzureServicePrincipal, AzureCredentials: models.AzureCredentialSettings{TenantID: "contoso.onmicrosoft.com", ClientID: "00000000-0000-0000-0000-000000000001"}},
+			wantErr:  errors.New("configure either the azure client secret or the azure client certificate"),
+		},
+		{
+			name:     "service principal with secret",
+			settings: models.InfinitySettings{AuthenticationMethod: models.AuthenticationMethodAzureServicePrincipal, AllowedHosts: []string{"https://graph.microsoft.com"}, AzureCredentials: models.AzureCredentialSettings{TenantID: "contoso.onmicrosoft.com", ClientID: "00000000-0000-0000-0000-000000000001", Resource: "https://graph.microsoft.com"}, AzureClientSecret: "foo"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
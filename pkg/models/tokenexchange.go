@@ -0,0 +1,81 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type TokenExchangeType string
+
+const (
+	// TokenExchangeTypeRFC8693 exchanges the user token with the OAuth 2.0 token exchange grant of RFC 8693
+	TokenExchangeTypeRFC8693 TokenExchangeType = "rfc8693"
+	// TokenExchangeTypeAzureOnBehalfOf exchanges the user token with the Microsoft identity platform on-behalf-of flow
+	TokenExchangeTypeAzureOnBehalfOf TokenExchangeType = "azureOnBehalfOf"
+)
+
+type TokenExchangeSubjectToken string
+
+const (
+	TokenExchangeSubjectAccessToken TokenExchangeSubjectToken = "access_token"
+	TokenExchangeSubjectIDToken     TokenExchangeSubjectToken = "id_token"
+)
+
+// TokenExchangeSettings configures the exchange of the forwarded OAuth identity of the user for a token issued for the downstream API.
+// The exchange is disabled unless a type is set.
+type TokenExchangeSettings struct {
+	Type TokenExchangeType `json:"type,omitempty"`
+	// TokenURL is the token endpoint the user token is exchanged at
+	TokenURL string `json:"tokenUrl,omitempty"`
+	ClientID string `json:"clientId,omitempty"`
+	// SubjectToken selects the forwarded token to exchange. Defaults to the access token of the Authorization header
+	SubjectToken TokenExchangeSubjectToken `json:"subjectToken,omitempty"`
+	// Audience and Resource identify the downstream API in RFC 8693 exchanges
+	Audience string   `json:"audience,omitempty"`
+	Resource string   `json:"resource,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+	// ClientSecret is stored in the secure json data
+	ClientSecret string `json:"-"`
+}
+
+func (s TokenExchangeSettings) Validate() error {
+	switch s.Type {
+	case "":
+		return nil
+	case TokenExchangeTypeRFC8693, TokenExchangeTypeAzureOnBehalfOf:
+	default:
+		return fmt.Errorf("invalid token exchange type %s", s.Type)
+	}
+	if strings.TrimSpace(s.TokenURL) == "" {
+		return errors.New("invalid/empty token exchange token url")
+	}
+	if strings.TrimSpace(s.ClientID) == "" {
+		return errors.New("invalid/empty token exchange client id")
+	}
+	switch s.SubjectToken {
+	case "", TokenExchangeSubjectAccessToken, TokenExchangeSubjectIDToken:
+	default:
+		return fmt.Errorf("invalid token exchange subject token %s", s.SubjectToken)
+	}
+	if s.Type == TokenExchangeTypeAzureOnBehalfOf {
+		if s.ClientSecret == "" {
+			return errors.New("invalid/empty token exchange client secret. the on-behalf-of flow requires a client secret")
+		}
+		if len(s.GetScopes()) == 0 {
+			return errors.New("invalid/empty token exchange scopes. the on-behalf-of flow requires the scopes of the downstream API")
+		}
+	}
+	return nil
+}
+
+// GetScopes returns the scopes without the empty entries
+func (s TokenExchangeSettings) GetScopes() []string {
+	scopes := []string{}
+	for _, scope := range s.Scopes {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
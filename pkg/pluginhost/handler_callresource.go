@@ -19,6 +19,9 @@ func (host *DataSource) getRouter() *http.ServeMux {
 	router := http.NewServeMux()
 	router.HandleFunc("GET /reference-data", host.withDatasourceHandlerFunc(getReferenceDataHandler))
 	router.HandleFunc("GET /ping", host.withDatasourceHandlerFunc(getPingHandler))
+	// the oauth2 handlers check the method themselves so that they don't depend on the method patterns of the router
+	router.HandleFunc("/oauth2/authorize", host.withDatasourceHandlerFunc(getOAuth2AuthorizeHandler))
+	router.HandleFunc("/oauth2/token", host.withDatasourceHandlerFunc(getOAuth2TokenHandler))
 	router.HandleFunc("/", host.withDatasourceHandlerFunc(defaultHandler))
 	return router
 }
@@ -46,6 +49,57 @@ func getPingHandler(client *infinity.Client) http.HandlerFunc {
 	}
 }
 
+// getOAuth2AuthorizeHandler starts the oauth2 authorization code flow of the datasource config and returns the authorization url to visit
+func getOAuth2AuthorizeHandler(client *infinity.Client) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		if !isAdminPost(rw, r) {
+			return
+		}
+		authURL, state, err := client.GetOAuth2AuthorizationURL(r.Context())
+		if err != nil {
+			writeResponse(map[string]any{"error": err.Error()}, nil, rw, http.StatusBadRequest)
+			return
+		}
+		writeResponse(map[string]any{"url": authURL, "state": state}, nil, rw, http.StatusOK)
+	}
+}
+
+// getOAuth2TokenHandler exchanges the code the authorization server redirected to and returns the refresh token to store in the datasource secure settings
+func getOAuth2TokenHandler(client *infinity.Client) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		if !isAdminPost(rw, r) {
+			return
+		}
+		body := struct {
+			Code  string `json:"code"`
+			State string `json:"state"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Code == "" || body.State == "" {
+			writeResponse(map[string]any{"error": "invalid oauth2 authorization code or state"}, nil, rw, http.StatusBadRequest)
+			return
+		}
+		token, err := client.ExchangeOAuth2AuthorizationCode(r.Context(), body.Code, body.State)
+		if err != nil {
+			writeResponse(map[string]any{"error": err.Error()}, nil, rw, http.StatusBadRequest)
+			return
+		}
+		writeResponse(map[string]any{"refresh_token": token.RefreshToken}, nil, rw, http.StatusOK)
+	}
+}
+
+// isAdminPost writes the error response and returns false unless the request is a POST request of an admin
+func isAdminPost(rw http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		writeResponse(map[string]any{"error": "method not allowed"}, nil, rw, http.StatusMethodNotAllowed)
+		return false
+	}
+	if user := backend.UserFromContext(r.Context()); user == nil || user.Role != "Admin" {
+		writeResponse(map[string]any{"error": "only admins can connect the datasource"}, nil, rw, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
 func defaultHandler(client *infinity.Client) http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
 		writeResponse(map[string]any{"error": "not a known resource call"}, nil, rw, http.StatusNotFound)
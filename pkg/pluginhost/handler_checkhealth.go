@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/infinity"
 	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/models"
@@ -50,38 +52,71 @@ func CheckHealth(ctx context.Context, client *infinity.Client, req *backend.Chec
 	if client.Settings.AuthenticationMethod == models.AuthenticationMethodAzureBlob {
 		return checkHealthAzureBlobStorage(ctx, client)
 	}
+	if infinity.IsAzureCredentialConfigured(client.Settings) && client.AzureTokenProvider != nil {
+		if _, err := client.AzureTokenProvider.GetToken(ctx); err != nil {
+			return healthCheckError(fmt.Sprintf("failed to get azure token. %s", err.Error()))
+		}
+	}
 	if client.Settings.CustomHealthCheckEnabled && client.Settings.CustomHealthCheckUrl != "" {
-		_, statusCode, _, err := client.GetResults(ctx, models.Query{
+		query := models.Query{
 			Type:   models.QueryTypeUQL,
 			Source: "url",
 			URL:    client.Settings.CustomHealthCheckUrl,
 			URLOptions: models.URLOptions{
 				Method: http.MethodGet,
 			},
-		}, req.Headers)
+		}
+		allowedHost := allowedHostMessage(ctx, client.Settings, query)
+		_, statusCode, _, _, _, err := client.GetResults(ctx, query, req.Headers)
 		if err != nil {
 			return &backend.CheckHealthResult{
 				Status:  backend.HealthStatusError,
-				Message: fmt.Sprintf("health check failed with url %s. error received: %s", client.Settings.CustomHealthCheckUrl, err.Error()),
+				Message: fmt.Sprintf("health check failed with url %s. error received: %s. %s", client.Settings.CustomHealthCheckUrl, err.Error(), allowedHost),
 			}, nil
 		}
 		if statusCode != http.StatusOK {
 			return &backend.CheckHealthResult{
 				Status:  backend.HealthStatusError,
-				Message: fmt.Sprintf("health check failed with url %s. http status code received: %d", client.Settings.CustomHealthCheckUrl, statusCode),
+				Message: fmt.Sprintf("health check failed with url %s. http status code received: %d. %s", client.Settings.CustomHealthCheckUrl, statusCode, allowedHost),
 			}, nil
 		}
 		if statusCode == http.StatusOK {
-			return &backend.CheckHealthResult{
-				Status:  backend.HealthStatusOk,
-				Message: fmt.Sprintf("health check successful with url %s. http status code received: %d", client.Settings.CustomHealthCheckUrl, statusCode),
-			}, nil
+			return checkHealthAWSIdentity(ctx, client, fmt.Sprintf("health check successful with url %s. http status code received: %d. %s", client.Settings.CustomHealthCheckUrl, statusCode, allowedHost))
 		}
 	}
-	return &backend.CheckHealthResult{
-		Status:  backend.HealthStatusOk,
-		Message: "OK",
-	}, nil
+	return checkHealthAWSIdentity(ctx, client, "OK")
+}
+
+// checkHealthAWSIdentity reports the AWS identity the requests are signed with
+func checkHealthAWSIdentity(ctx context.Context, client *infinity.Client, msg string) (*backend.CheckHealthResult, error) {
+	if !infinity.IsAwsAuthConfigured(client.Settings) || client.AWSCredentials == nil {
+		return &backend.CheckHealthResult{Status: backend.HealthStatusOk, Message: msg}, nil
+	}
+	identity, err := client.AWSCredentials.GetCallerIdentity(ctx)
+	if err != nil {
+		return healthCheckError(fmt.Sprintf("failed to get the aws identity. %s", err.Error()))
+	}
+	return &backend.CheckHealthResult{Status: backend.HealthStatusOk, Message: fmt.Sprintf("%s. aws identity %s", msg, identity)}, nil
+}
+
+// allowedHostMessage explains which allowed host rule permits the url of the query
+func allowedHostMessage(ctx context.Context, settings models.InfinitySettings, query models.Query) string {
+	if len(settings.AllowedHosts) == 0 {
+		return "no allowed hosts configured"
+	}
+	queryURL, err := infinity.GetQueryURL(ctx, settings, query, false)
+	if err != nil {
+		return "invalid url"
+	}
+	u, err := url.Parse(queryURL)
+	if err != nil {
+		return "invalid url"
+	}
+	rule, err := infinity.NewEgressPolicy(settings).Match(u)
+	if err != nil {
+		return fmt.Sprintf("url doesn't match any of the allowed hosts %s", strings.Join(settings.AllowedHosts, ", "))
+	}
+	return fmt.Sprintf("allowed by rule %s", rule)
 }
 
 func healthCheckError(msg string) (*backend.CheckHealthResult, error) {
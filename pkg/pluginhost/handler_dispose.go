@@ -1,4 +1,7 @@
 package pluginhost
 
 func (host *DataSource) Dispose() {
+	if host.client != nil {
+		host.client.Dispose()
+	}
 }
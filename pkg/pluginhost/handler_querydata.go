@@ -15,7 +15,12 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
+// headerFromAlert is set by grafana on the queries of alert rule evaluations
+const headerFromAlert = "FromAlert"
+
 // QueryData handles multiple queries and returns multiple responses.
+// Queries are executed concurrently up to the max concurrent queries of the datasource.
+// Transformations queries wait for all the queries before them, so the responses are assembled in the order of the queries.
 func (ds *DataSource) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
 	logger := backend.Logger.FromContext(ctx)
 	ctx, span := tracing.DefaultTracer().Start(ctx, "PluginHost.QueryData")
@@ -24,18 +29,44 @@ func (ds *DataSource) QueryData(ctx context.Context, req *backend.QueryDataReque
 	if ds.client == nil {
 		return response, errorsource.PluginError(errors.New("invalid infinity client"), false)
 	}
-	for _, q := range req.Queries {
+	// cancelling stops the queued and in-flight queries when the request is cancelled or a transformation fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	concurrency := ds.client.Settings.MaxConcurrentQueries
+	if concurrency <= 0 {
+		concurrency = models.MaxConcurrentQueriesDefault
+	}
+	semaphore := make(chan struct{}, concurrency)
+	queries := make([]models.Query, len(req.Queries))
+	results := make([]chan backend.DataResponse, len(req.Queries))
+	for i, q := range req.Queries {
+		results[i] = make(chan backend.DataResponse, 1)
 		query, err := models.LoadQuery(ctx, q, req.PluginContext)
 		if err != nil {
 			span.RecordError(err)
 			logger.Error("error un-marshaling the query", "error", err.Error())
 			// Here we are using error source from the original error and if it does not have any source we are using the plugin error as the default source
-			errorRes := errorsource.Response(errorsource.SourceError(backend.ErrorSourcePlugin, fmt.Errorf("%s: %w", "error un-marshaling the query", err), false))
-			response.Responses[q.RefID] = errorRes
+			results[i] <- errorsource.Response(errorsource.SourceError(backend.ErrorSourcePlugin, fmt.Errorf("%s: %w", "error un-marshaling the query", err), false))
 			continue
 		}
+		queries[i] = query
 		if query.Type == models.QueryTypeTransformations {
-			response1, err := infinity.ApplyTransformations(query, response)
+			continue
+		}
+		go func(query models.Query, result chan<- backend.DataResponse) {
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+				result <- errorsource.Response(errorsource.DownstreamError(ctx.Err(), false))
+				return
+			}
+			defer func() { <-semaphore }()
+			result <- QueryDataQuery(ctx, query, *ds.client, req.Headers, req.PluginContext)
+		}(query, results[i])
+	}
+	for i, q := range req.Queries {
+		if queries[i].Type == models.QueryTypeTransformations {
+			response1, err := infinity.ApplyTransformations(queries[i], response)
 			if err != nil {
 				logger.Error("error applying infinity query transformation", "error", err.Error())
 				span.RecordError(err)
@@ -46,7 +77,7 @@ func (ds *DataSource) QueryData(ctx context.Context, req *backend.QueryDataReque
 			response = response1
 			continue
 		}
-		response.Responses[q.RefID] = QueryDataQuery(ctx, query, *ds.client, req.Headers, req.PluginContext)
+		response.Responses[q.RefID] = <-results[i]
 	}
 	return response, nil
 }
@@ -89,6 +120,10 @@ func QueryDataQuery(ctx context.Context, query models.Query, infClient infinity.
 	args = append(args, "settings.AuthenticationMethod", infClient.Settings.AuthenticationMethod)
 	args = append(args, "settings.OAuth2Settings.OAuth2Type", infClient.Settings.OAuth2Settings.OAuth2Type)
 	logger.Info("performing QueryData in infinity datasource", args...)
+	if query.PartialResults && requestHeaders[headerFromAlert] == "true" {
+		// alert rules must not evaluate incomplete data
+		query.PartialResults = false
+	}
 	//region Frame Builder
 	switch query.Type {
 	case models.QueryTypeGSheets:
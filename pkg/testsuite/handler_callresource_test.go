@@ -0,0 +1,87 @@
+package testsuite_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOAuth2AuthorizationCodeResources(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		w.Header().Set("Content-Type", "application/json")
+		if r.Form.Get("grant_type") != "authorization_code" || r.Form.Get("code") != "my-code" || r.Form.Get("code_verifier") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"my-access-token","refresh_token":"my-refresh-token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+	ds := getds(t, backend.DataSourceInstanceSettings{
+		JSONData: []byte(fmt.Sprintf(`{
+			"auth_method": "oauth2",
+			"allowedHosts": ["%s"],
+			"oauth2": {
+				"oauth2_type": "authorization_code",
+				"client_id": "my-client",
+				"auth_url": "https://auth.example.com/authorize",
+				"token_url": "%s",
+				"redirect_url": "https://grafana.example.com/callback"
+			}
+		}`, tokenServer.URL, tokenServer.URL)),
+		DecryptedSecureJSONData: map[string]string{"oauth2ClientSecret": "my-secret"},
+	})
+	callResource := func(t *testing.T, path string, body string, role string) (int, map[string]any) {
+		t.Helper()
+		var res *backend.CallResourceResponse
+		err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
+			PluginContext: backend.PluginContext{User: &backend.User{Login: "user", Role: role}},
+			Method:        http.MethodPost,
+			Path:          path,
+			URL:           path,
+			Body:          []byte(body),
+		}, backend.CallResourceResponseSenderFunc(func(r *backend.CallResourceResponse) error {
+			res = r
+			return nil
+		}))
+		require.NoError(t, err)
+		require.NotNil(t, res)
+		out := map[string]any{}
+		require.NoError(t, json.Unmarshal(res.Body, &out))
+		return res.Status, out
+	}
+	t.Run("admins should be able to connect the datasource", func(t *testing.T) {
+		status, out := callResource(t, "oauth2/authorize", "", "Admin")
+		require.Equal(t, http.StatusOK, status)
+		u, err := url.Parse(out["url"].(string))
+		require.NoError(t, err)
+		assert.Equal(t, "https://auth.example.com/authorize", u.Scheme+"://"+u.Host+u.Path)
+		assert.Equal(t, out["state"], u.Query().Get("state"))
+		status, out = callResource(t, "oauth2/token", fmt.Sprintf(`{"code":"my-code","state":%q}`, out["state"]), "Admin")
+		require.Equal(t, http.StatusOK, status)
+		assert.Equal(t, map[string]any{"refresh_token": "my-refresh-token"}, out)
+	})
+	t.Run("invalid codes should not be exchanged", func(t *testing.T) {
+		_, out := callResource(t, "oauth2/authorize", "", "Admin")
+		status, out := callResource(t, "oauth2/token", fmt.Sprintf(`{"code":"another-code","state":%q}`, out["state"]), "Admin")
+		require.Equal(t, http.StatusBadRequest, status)
+		assert.Contains(t, out["error"], "error exchanging the oauth2 authorization code")
+		status, _ = callResource(t, "oauth2/token", `{"code":"my-code"}`, "Admin")
+		require.Equal(t, http.StatusBadRequest, status)
+	})
+	t.Run("only admins should be able to connect the datasource", func(t *testing.T) {
+		status, _ := callResource(t, "oauth2/authorize", "", "Viewer")
+		assert.Equal(t, http.StatusForbidden, status)
+		status, _ = callResource(t, "oauth2/token", `{"code":"my-code","state":"my-state"}`, "Editor")
+		assert.Equal(t, http.StatusForbidden, status)
+	})
+}
@@ -8,7 +8,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/infinity"
 	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/models"
@@ -1254,7 +1256,6 @@ func TestQuery(t *testing.T) {
 		}
 	})
 	t.Run("azure blob", func(t *testing.T) {
-		t.Skip()
 		t.Run("backend", func(t *testing.T) {
 			server := getServerWithStaticResponse(t, "./../../testdata/users.json", true)
 			server.Start()
@@ -1360,6 +1361,69 @@ func TestQuery(t *testing.T) {
 			resItem := res.Responses["A"]
 			experimental.CheckGoldenJSONResponse(t, "golden", strings.ReplaceAll(t.Name(), "TestQuery/", ""), &resItem, UPDATE_GOLDEN_DATA)
 		})
+		t.Run("backend csv prefix", func(t *testing.T) {
+			blobs := map[string]string{
+				"exports/2024-01-01-00.csv": "name,age\nfoo,1\nbar,2",
+				"exports/2024-01-01-01.csv": "name,age\nbaz,3",
+				"exports/2024-01-01-02.csv": "name,age\nqux,4",
+				"other/2024-01-01-00.csv":   "name,age\nignored,0",
+			}
+			server := getAzureBlobServer(t, blobs)
+			defer server.Close()
+			ds := getds(t, backend.DataSourceInstanceSettings{
+				JSONData: []byte(fmt.Sprintf(`{
+					"is_mock"				: true,
+					"auth_method"			: "azureBlob",
+					"azureBlobAccountUrl"	: "%s",
+					"azureBlobAccountName"  : "dummyaccount"
+				}`, server.URL)),
+				DecryptedSecureJSONData: map[string]string{
+					"azureBlobAccountKey": "ZmFrZQ==",
+				},
+			})
+			t.Run("should merge all the blobs matching the prefix", func(t *testing.T) {
+				res, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+					Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(`{
+						"type"				:	"csv",
+						"source"			:	"azure-blob",
+						"parser" 			: 	"backend",
+						"azContainerName"	: 	"my-azContainerName",
+						"azBlobName"		: 	"exports/",
+						"azBlobMatchMode"	: 	"prefix",
+						"azBlobNameColumn"	: 	"blob"
+					}`)}},
+				})
+				require.Nil(t, err)
+				require.Nil(t, res.Responses["A"].Error)
+				frame := res.Responses["A"].Frames[0]
+				require.Equal(t, 4, frame.Rows())
+				require.Equal(t, []string{"age", "name", "blob"}, []string{frame.Fields[0].Name, frame.Fields[1].Name, frame.Fields[2].Name})
+				require.Equal(t, toSP("foo"), frame.Fields[1].At(0))
+				require.Equal(t, toSP("qux"), frame.Fields[1].At(3))
+				require.Equal(t, toSP("exports/2024-01-01-00.csv"), frame.Fields[2].At(1))
+				require.Equal(t, toSP("exports/2024-01-01-02.csv"), frame.Fields[2].At(3))
+			})
+			t.Run("should respect the glob pattern and the blob limit", func(t *testing.T) {
+				res, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+					Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(`{
+						"type"				:	"csv",
+						"source"			:	"azure-blob",
+						"parser" 			: 	"backend",
+						"azContainerName"	: 	"my-azContainerName",
+						"azBlobName"		: 	"*/2024-01-01-0?.csv",
+						"azBlobMatchMode"	: 	"glob",
+						"azBlobMaxBlobs"	: 	3
+					}`)}},
+				})
+				require.Nil(t, err)
+				require.Nil(t, res.Responses["A"].Error)
+				frame := res.Responses["A"].Frames[0]
+				require.Equal(t, 4, frame.Rows())
+				require.Equal(t, 2, len(frame.Fields))
+				require.Equal(t, 1, len(frame.Meta.Notices))
+				require.Equal(t, "more than 3 blobs matched */2024-01-01-0?.csv. only the first 3 blobs are included in the results", frame.Meta.Notices[0].Text)
+			})
+		})
 		t.Run("default tsv", func(t *testing.T) {
 			server := getServerWithStaticResponse(t, "./../../testdata/users.tsv", true)
 			server.Start()
@@ -1396,3 +1460,107 @@ func TestQuery(t *testing.T) {
 		})
 	})
 }
+
+func TestQueryConcurrency(t *testing.T) {
+	inflight, maxInflight := &atomic.Int32{}, &atomic.Int32{}
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := inflight.Add(1)
+		defer inflight.Add(-1)
+		for {
+			previous := maxInflight.Load()
+			if current <= previous || maxInflight.CompareAndSwap(previous, current) {
+				break
+			}
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		_, _ = w.Write([]byte(`[{"value":1},{"value":2},{"value":3}]`))
+	}))
+	defer server.Close()
+	getQueries := func(refIDs ...string) []backend.DataQuery {
+		queries := []backend.DataQuery{}
+		for _, refID := range refIDs {
+			queries = append(queries, backend.DataQuery{RefID: refID, JSON: []byte(fmt.Sprintf(`{ "refId": "%s", "type": "json", "source": "url", "parser": "backend", "url": "%s?refId=%s" }`, refID, server.URL, refID))})
+		}
+		return queries
+	}
+	t.Run("queries should run concurrently up to the limit", func(t *testing.T) {
+		maxInflight.Store(0)
+		ds := getds(t, backend.DataSourceInstanceSettings{JSONData: []byte(`{ "maxConcurrentQueries": 2 }`)})
+		go func() {
+			assert.Eventually(t, func() bool { return inflight.Load() == 2 }, 5*time.Second, time.Millisecond)
+			close(release)
+		}()
+		res, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{Queries: getQueries("A", "B", "C", "D")})
+		require.NoError(t, err)
+		require.Len(t, res.Responses, 4)
+		for _, refID := range []string{"A", "B", "C", "D"} {
+			require.NoError(t, res.Responses[refID].Error)
+			require.Len(t, res.Responses[refID].Frames, 1)
+			assert.Equal(t, refID, res.Responses[refID].Frames[0].Name)
+		}
+		assert.Equal(t, int32(2), maxInflight.Load())
+	})
+	t.Run("transformations should wait for the previous queries", func(t *testing.T) {
+		ds := getds(t, backend.DataSourceInstanceSettings{JSONData: []byte(`{}`)})
+		queries := append(getQueries("A", "B"), backend.DataQuery{RefID: "C", JSON: []byte(`{ "type": "transformations", "transformations": [{ "type": "limit", "limit": { "limitField": 2 } }] }`)})
+		queries = append(queries, getQueries("D")...)
+		res, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{Queries: queries})
+		require.NoError(t, err)
+		require.Len(t, res.Responses, 3)
+		assert.Equal(t, 2, res.Responses["A"].Frames[0].Rows())
+		assert.Equal(t, 2, res.Responses["B"].Frames[0].Rows())
+		assert.Equal(t, 3, res.Responses["D"].Frames[0].Rows())
+	})
+	t.Run("cancelling the request should stop all the queries", func(t *testing.T) {
+		release = make(chan struct{})
+		defer close(release)
+		ds := getds(t, backend.DataSourceInstanceSettings{JSONData: []byte(`{ "maxConcurrentQueries": 1 }`)})
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		startTime := time.Now()
+		res, err := ds.QueryData(ctx, &backend.QueryDataRequest{Queries: getQueries("A", "B", "C")})
+		require.NoError(t, err)
+		assert.Less(t, time.Since(startTime), 5*time.Second)
+		for _, refID := range []string{"A", "B", "C"} {
+			require.ErrorIs(t, res.Responses[refID].Error, context.DeadlineExceeded)
+		}
+	})
+}
+
+func TestPartialResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(fmt.Sprintf(`[{"page":"%s"}]`, r.URL.Query().Get("page"))))
+	}))
+	defer server.Close()
+	query := backend.DataQuery{RefID: "A", JSON: []byte(fmt.Sprintf(`{ "type": "json", "source": "url", "parser": "backend", "url": "%s", "pagination_mode": "page", "pagination_max_pages": 3, "partial_results": true }`, server.URL))}
+	t.Run("partial results should return the successful pages with a warning", func(t *testing.T) {
+		ds := getds(t, backend.DataSourceInstanceSettings{JSONData: []byte(`{}`)})
+		res, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{Queries: []backend.DataQuery{query}})
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		require.Len(t, res.Responses["A"].Frames, 1)
+		frame := res.Responses["A"].Frames[0]
+		assert.Equal(t, 2, frame.Rows())
+		require.NotNil(t, frame.Meta)
+		require.Len(t, frame.Meta.Notices, 2)
+		assert.Equal(t, data.NoticeSeverityWarning, frame.Meta.Notices[0].Severity)
+		assert.Equal(t, "results are incomplete. 1 of 3 requests failed", frame.Meta.Notices[0].Text)
+		assert.Equal(t, data.NoticeSeverityWarning, frame.Meta.Notices[1].Severity)
+		assert.Contains(t, frame.Meta.Notices[1].Text, "error getting page 2. status code 502")
+	})
+	t.Run("alert queries should fail when a page fails", func(t *testing.T) {
+		ds := getds(t, backend.DataSourceInstanceSettings{JSONData: []byte(`{}`)})
+		res, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{Queries: []backend.DataQuery{query}, Headers: map[string]string{"FromAlert": "true"}})
+		require.NoError(t, err)
+		require.ErrorContains(t, res.Responses["A"].Error, "error getting page 2")
+	})
+}
@@ -12,6 +12,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/cloudrhinoltd/infinity-plus-datasource/pkg/infinity"
@@ -79,6 +81,38 @@ func getServerWithStaticResponse(t *testing.T, content string, isFile bool) *htt
 	return server
 }
 
+// getAzureBlobServer returns a minimal stand-in for the azure blob storage API serving the given blobs
+func getAzureBlobServer(t *testing.T, blobs map[string]string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+		if r.URL.Query().Get("comp") == "list" {
+			names := []string{}
+			for name := range blobs {
+				if strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			out := `<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs>`
+			for _, name := range names {
+				out += fmt.Sprintf("<Blob><Name>%s</Name><Properties></Properties></Blob>", name)
+			}
+			out += `</Blobs><NextMarker /></EnumerationResults>`
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = w.Write([]byte(out))
+			return
+		}
+		if len(parts) == 2 {
+			if content, ok := blobs[parts[1]]; ok {
+				_, _ = w.Write([]byte(content))
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+}
+
 func getServerCertificate(serverName string) *tls.Config {
 	caPool := x509.NewCertPool()
 	if ok := caPool.AppendCertsFromPEM([]byte(mockPEMClientCACet)); !ok {
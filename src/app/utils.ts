@@ -9,6 +9,7 @@ import type {
   InfinityQueryWithURLSource,
   InfinityXMLQuery,
   InfinityQueryType,
+  InfinityURLMethod,
 } from './../types';
 
 export const isTableData = (res: any): res is TableData => res && res.columns;
@@ -18,6 +19,7 @@ export const isJSONQuery = (query: InfinityQuery): query is InfinityJSONQuery =>
 export const isXMLQuery = (query: InfinityQuery): query is InfinityXMLQuery => query.type === 'xml';
 export const isGraphQLQuery = (query: InfinityQuery): query is InfinityGraphQLQuery => query.type === 'graphql';
 export const isHTMLQuery = (query: InfinityQuery): query is InfinityHTMLQuery => query.type === 'html';
+export const hasBody = (method?: InfinityURLMethod): boolean => method === 'POST' || method === 'PUT' || method === 'PATCH';
 
 export const isBackendQuerySupported = (
   query: InfinityQuery
@@ -11,9 +11,12 @@ export const AllowedHostsEditor = ({ options, onOptionsChange }: AllowedHostsEdi
   }
   return (
     <>
-      <p>For the enhanced security, enter list of allowed hosts in this section. The host URLs can include path and the URLs are case sensitive</p>
+      <p>
+        For the enhanced security, enter list of allowed hosts in this section. Each entry is either a URL with an optional wildcard subdomain, port or port range and path prefix
+        such as https://*.example.com:8000-8100/api, or a CIDR block such as 10.0.0.0/8. Paths are case sensitive
+      </p>
       <div className="gf-form">
-        <InlineFormLabel width={10} tooltip="List of allowed host names. Enter the base URL names. ex: https://foo.com">
+        <InlineFormLabel width={10} tooltip="List of allowed host rules. ex: https://foo.com, https://*.foo.com, https://foo.com:8443/api, 10.0.0.0/8">
           Allowed hosts
         </InlineFormLabel>
         <TagsInput
@@ -26,10 +26,12 @@ import { onUpdateDatasourceSecureJsonDataOption, DataSourcePluginOptionsEditorPr
 import { Icon, InlineFormLabel, LegacyForms, RadioButtonGroup, Select, useTheme2 } from '@grafana/ui';
 import React, { useState } from 'react';
 import { AllowedHostsEditor } from './AllowedHosts';
+import { LoginTokenEditor } from './LoginToken';
 import { OAuthInputsEditor } from './OAuthInput';
 import { OthersAuthentication } from './OtherAuthProviders';
+import { TokenExchangeEditor } from './TokenExchange';
 import { AWSRegions } from './../../constants';
-import type { APIKeyType, AuthType, InfinityOptions, InfinitySecureOptions } from './../../types';
+import type { APIKeyType, AuthType, AWSAuthProps, AWSAuthType, InfinityOptions, InfinitySecureOptions } from './../../types';
 
 // Adding Azure Managed Identity to the available authentication methods
 const authTypes: Array<SelectableValue<AuthType | 'others'> & { logo?: string }> = [
@@ -43,6 +45,7 @@ const authTypes: Array<SelectableValue<AuthType | 'others'> & { logo?: string }>
   { value: 'aws', label: 'AWS', logo: '/public/plugins/infinity-plus-datasource/img/aws.jpg' },
   { value: 'azureBlob', label: 'Azure Blob' },
   { value: 'azureManagedIdentity', label: 'Azure Managed Identity' }, // Added Azure Managed Identity option
+  { value: 'loginToken', label: 'Login Token' },
   { value: 'others', label: 'Other Auth Providers' },
 ];
 
@@ -53,6 +56,7 @@ export const AuthEditor = (props: DataSourcePluginOptionsEditorProps<InfinityOpt
   const [othersOpen, setOthersOpen] = useState(false);
   const theme = useTheme2();
   const secureJsonData = (options.secureJsonData || {}) as InfinitySecureOptions;
+  const awsAuthType: AWSAuthType = options.jsonData?.aws?.authType || 'keys';
   const authType = props.options.jsonData.auth_method
     ? props.options.jsonData.auth_method
     : props.options.basicAuth
@@ -107,6 +111,7 @@ export const AuthEditor = (props: DataSourcePluginOptionsEditorProps<InfinityOpt
       case 'aws':
       case 'azureBlob':
       case 'oauth2':
+      case 'loginToken':
       case 'none':
       default:
         onOptionsChange({ ...options, basicAuth: false, jsonData: { ...options.jsonData, oauthPassThru: false, auth_method: authMethod } });
@@ -129,6 +134,10 @@ export const AuthEditor = (props: DataSourcePluginOptionsEditorProps<InfinityOpt
     onOptionsChange({ ...options, jsonData: { ...options.jsonData, aws: { ...options.jsonData?.aws, service } } });
   };
 
+  const onAwsChange = <T extends keyof AWSAuthProps>(key: T, value: AWSAuthProps[T]) => {
+    onOptionsChange({ ...options, jsonData: { ...options.jsonData, aws: { ...options.jsonData?.aws, [key]: value } } });
+  };
+
   const onAzureBlogAccountChange = (azureBlobAccountName: string) => {
     onOptionsChange({ ...options, jsonData: { ...options.jsonData, azureBlobAccountName } });
   };
@@ -280,38 +289,117 @@ export const AuthEditor = (props: DataSourcePluginOptionsEditorProps<InfinityOpt
                   ></FormField>
                 </div>
                 <div className="gf-form">
-                  <SecretFormField
-                    labelWidth={10}
-                    inputWidth={12}
-                    required
-                    value={secureJsonData.awsAccessKey || ''}
-                    isConfigured={(secureJsonFields && secureJsonFields.awsAccessKey) as boolean}
-                    onReset={() => onResetSecret('awsAccessKey')}
-                    onChange={onUpdateDatasourceSecureJsonDataOption(props, 'awsAccessKey')}
-                    label="Access Key"
-                    aria-label="aws access key"
-                    placeholder="aws access key"
-                    tooltip="aws access key"
+                  <InlineFormLabel tooltip="Credentials used to sign the requests. Default uses the credential chain of the AWS SDK: environment, shared config, EKS web identity and ECS/EC2 roles.">Auth Type</InlineFormLabel>
+                  <RadioButtonGroup<AWSAuthType>
+                    options={[
+                      { value: 'keys', label: 'Access & secret key' },
+                      { value: 'default', label: 'Default credentials' },
+                      { value: 'webIdentity', label: 'Web identity' },
+                    ]}
+                    value={awsAuthType}
+                    onChange={(value = 'keys') => onAwsChange('authType', value)}
                   />
                 </div>
+                {awsAuthType === 'keys' && (
+                  <>
+                    <div className="gf-form">
+                      <SecretFormField
+                        labelWidth={10}
+                        inputWidth={12}
+                        required
+                        value={secureJsonData.awsAccessKey || ''}
+                        isConfigured={(secureJsonFields && secureJsonFields.awsAccessKey) as boolean}
+                        onReset={() => onResetSecret('awsAccessKey')}
+                        onChange={onUpdateDatasourceSecureJsonDataOption(props, 'awsAccessKey')}
+                        label="Access Key"
+                        aria-label="aws access key"
+                        placeholder="aws access key"
+                        tooltip="aws access key"
+                      />
+                    </div>
+                    <div className="gf-form">
+                      <SecretFormField
+                        labelWidth={10}
+                        inputWidth={12}
+                        required
+                        value={secureJsonData.awsSecretKey || ''}
+                        isConfigured={(secureJsonFields && secureJsonFields.awsSecretKey) as boolean}
+                        onReset={() => onResetSecret('awsSecretKey')}
+                        onChange={onUpdateDatasourceSecureJsonDataOption(props, 'awsSecretKey')}
+                        label="Secret Key"
+                        aria-label="aws secret key"
+                        placeholder="aws secret key"
+                        tooltip="aws secret key"
+                      />
+                    </div>
+                    <div className="gf-form">
+                      <SecretFormField
+                        labelWidth={10}
+                        inputWidth={12}
+                        value={secureJsonData.awsSessionToken || ''}
+                        isConfigured={(secureJsonFields && secureJsonFields.awsSessionToken) as boolean}
+                        onReset={() => onResetSecret('awsSessionToken')}
+                        onChange={onUpdateDatasourceSecureJsonDataOption(props, 'awsSessionToken')}
+                        label="Session Token"
+                        aria-label="aws session token"
+                        placeholder="aws session token"
+                        tooltip="Session token of temporary credentials. Leave empty for long-term access keys."
+                      />
+                    </div>
+                  </>
+                )}
+                {awsAuthType === 'webIdentity' && (
+                  <>
+                    <div className="gf-form">
+                      <FormField
+                        label="Role ARN"
+                        placeholder="AWS_ROLE_ARN"
+                        tooltip="Role assumed with the web identity token. Defaults to the AWS_ROLE_ARN environment variable."
+                        labelWidth={10}
+                        inputWidth={24}
+                        value={props.options.jsonData?.aws?.webIdentityRoleArn || ''}
+                        onChange={(e) => onAwsChange('webIdentityRoleArn', e.currentTarget.value)}
+                      ></FormField>
+                    </div>
+                    <div className="gf-form">
+                      <FormField
+                        label="Token File"
+                        placeholder="AWS_WEB_IDENTITY_TOKEN_FILE"
+                        tooltip="Path of the web identity token file. Defaults to the AWS_WEB_IDENTITY_TOKEN_FILE environment variable."
+                        labelWidth={10}
+                        inputWidth={24}
+                        value={props.options.jsonData?.aws?.webIdentityTokenFile || ''}
+                        onChange={(e) => onAwsChange('webIdentityTokenFile', e.currentTarget.value)}
+                      ></FormField>
+                    </div>
+                  </>
+                )}
                 <div className="gf-form">
-                  <SecretFormField
+                  <FormField
+                    label="Assume Role ARN"
+                    placeholder="arn:aws:iam::123456789012:role/name"
+                    tooltip="Optional. Role assumed with the credentials above, for example to access another account."
                     labelWidth={10}
-                    inputWidth={12}
-                    required
-                    value={secureJsonData.awsSecretKey || ''}
-                    isConfigured={(secureJsonFields && secureJsonFields.awsSecretKey) as boolean}
-                    onReset={() => onResetSecret('awsSecretKey')}
-                    onChange={onUpdateDatasourceSecureJsonDataOption(props, 'awsSecretKey')}
-                    label="Secret Key"
-                    aria-label="aws secret key"
-                    placeholder="aws secret key"
-                    tooltip="aws secret key"
-                  />
+                    inputWidth={24}
+                    value={props.options.jsonData?.aws?.assumeRoleArn || ''}
+                    onChange={(e) => onAwsChange('assumeRoleArn', e.currentTarget.value)}
+                  ></FormField>
+                </div>
+                <div className="gf-form">
+                  <FormField
+                    label="External ID"
+                    placeholder="external id"
+                    tooltip="Optional. External ID required by the trust policy of the assumed role."
+                    labelWidth={10}
+                    inputWidth={24}
+                    value={props.options.jsonData?.aws?.externalId || ''}
+                    onChange={(e) => onAwsChange('externalId', e.currentTarget.value)}
+                  ></FormField>
                 </div>
               </>
             )}
             {authType === 'oauth2' && <OAuthInputsEditor {...props} />}
+            {authType === 'loginToken' && <LoginTokenEditor {...props} />}
             {authType === 'azureManagedIdentity' && (
               <div className="gf-form">
                 <p>Azure Managed Identity does not require additional configuration. The datasource will use the Managed Identity associated with the Grafana instance.</p>
@@ -349,6 +437,12 @@ export const AuthEditor = (props: DataSourcePluginOptionsEditorProps<InfinityOpt
           </div>
         </>
       )}
+      {authType === 'oauthPassThru' && !othersOpen && (
+        <>
+          <h5 className={styles.subheading}>Token exchange</h5>
+          <TokenExchangeEditor {...props} />
+        </>
+      )}
       {authType !== 'none' && authType !== 'azureBlob' && !othersOpen && (
         <>
           <h5 className={styles.subheading}>Allowed hosts</h5>
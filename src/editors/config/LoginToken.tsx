@@ -0,0 +1,96 @@
+import { DataSourcePluginOptionsEditorProps, SelectableValue } from '@grafana/data';
+import { InlineFormLabel, Input, RadioButtonGroup, TextArea } from '@grafana/ui';
+import React from 'react';
+import { SecureFieldsEditor } from './../../components/config/SecureFieldsEditor';
+import type { InfinityOptions, LoginTokenOptions } from './../../types';
+
+const loginMethods: Array<SelectableValue<'GET' | 'POST' | 'PUT'>> = [
+  { value: 'GET', label: 'GET' },
+  { value: 'POST', label: 'POST' },
+  { value: 'PUT', label: 'PUT' },
+];
+
+export const LoginTokenEditor = (props: DataSourcePluginOptionsEditorProps<InfinityOptions>) => {
+  const { options, onOptionsChange } = props;
+  const loginToken: LoginTokenOptions = options.jsonData?.loginToken || {};
+  const onLoginTokenChange = <T extends keyof LoginTokenOptions, V extends LoginTokenOptions[T]>(key: T, value: V) => {
+    onOptionsChange({ ...options, jsonData: { ...options.jsonData, loginToken: { ...loginToken, [key]: value } } });
+  };
+  return (
+    <>
+      <div className="gf-form">
+        <InlineFormLabel width={10}>Login URL</InlineFormLabel>
+        <Input onChange={(v) => onLoginTokenChange('url', v.currentTarget.value)} value={loginToken.url} width={30} placeholder={'https://api.example.com/login'} />
+      </div>
+      <div className="gf-form">
+        <InlineFormLabel width={10}>Method</InlineFormLabel>
+        <RadioButtonGroup<'GET' | 'POST' | 'PUT'> options={loginMethods} value={loginToken.method || 'POST'} onChange={(v) => onLoginTokenChange('method', v)}></RadioButtonGroup>
+      </div>
+      {(loginToken.method || 'POST') !== 'GET' && (
+        <>
+          <div className="gf-form">
+            <InlineFormLabel width={10}>Content Type</InlineFormLabel>
+            <Input onChange={(v) => onLoginTokenChange('contentType', v.currentTarget.value)} value={loginToken.contentType} width={30} placeholder={'application/json'} />
+          </div>
+          <div className="gf-form">
+            <InlineFormLabel width={10} tooltip="Body of the login request. {{name}} placeholders are replaced with the secure values below">
+              Body
+            </InlineFormLabel>
+            <TextArea rows={4} onChange={(v) => onLoginTokenChange('body', v.currentTarget.value)} value={loginToken.body} placeholder={'{ "username": "{{username}}", "password": "{{password}}" }'} />
+          </div>
+          <div className="gf-form">
+            <SecureFieldsEditor
+              dataSourceConfig={options}
+              onChange={onOptionsChange}
+              title="Secure values"
+              hideTile={true}
+              label="Secure value"
+              labelWidth={10}
+              secureFieldName="loginTokenSecretName"
+              secureFieldValue="loginTokenSecretValue"
+            />
+          </div>
+        </>
+      )}
+      <div className="gf-form">
+        <InlineFormLabel width={10} tooltip="Selector of the token in the login response. For example data.token">
+          Token Selector
+        </InlineFormLabel>
+        <Input onChange={(v) => onLoginTokenChange('tokenSelector', v.currentTarget.value)} value={loginToken.tokenSelector} width={30} placeholder={'token'} />
+      </div>
+      <div className="gf-form">
+        <InlineFormLabel
+          width={10}
+          tooltip="Optional selector of the token expiry in the login response. The expiry can be the number of seconds the token is valid for, a unix timestamp or a RFC 3339 date time"
+        >
+          Expiry Selector
+        </InlineFormLabel>
+        <Input onChange={(v) => onLoginTokenChange('expirySelector', v.currentTarget.value)} value={loginToken.expirySelector} width={30} placeholder={'(optional) expires_in'} />
+      </div>
+      {!loginToken.expirySelector && (
+        <div className="gf-form">
+          <InlineFormLabel width={10} tooltip="How long the token is used when the login response has no expiry. Leave empty to use the token until the API rejects it">
+            TTL (seconds)
+          </InlineFormLabel>
+          <Input
+            type="number"
+            onChange={(v) => onLoginTokenChange('ttlSeconds', v.currentTarget.valueAsNumber || undefined)}
+            value={loginToken.ttlSeconds || ''}
+            width={30}
+            placeholder={'(optional) 3600'}
+          />
+        </div>
+      )}
+      <div className="gf-form">
+        <InlineFormLabel width={10}>Header Name</InlineFormLabel>
+        <Input onChange={(v) => onLoginTokenChange('headerName', v.currentTarget.value)} value={loginToken.headerName} width={30} placeholder={'Authorization'} />
+      </div>
+      <div className="gf-form">
+        <InlineFormLabel width={10} tooltip="Value of the header. {{token}} is replaced with the token">
+          Header Template
+        </InlineFormLabel>
+        <Input onChange={(v) => onLoginTokenChange('headerTemplate', v.currentTarget.value)} value={loginToken.headerTemplate} width={30} placeholder={'Bearer {{token}}'} />
+      </div>
+    </>
+  );
+};
@@ -0,0 +1,96 @@
+import React, { useState } from 'react';
+import { Alert, Button, InlineFormLabel } from '@grafana/ui';
+import { getBackendSrv } from '@grafana/runtime';
+import type { DataSourcePluginOptionsEditorProps } from '@grafana/data';
+import type { InfinityOptions, InfinitySecureOptions } from './../../types';
+
+const AUTHORIZATION_TIMEOUT_MS = 10 * 60 * 1000;
+
+// waitForCode polls the authorization popup until the authorization server redirects it back to grafana with the code
+const waitForCode = (popup: Window, state: string): Promise<string> => {
+  return new Promise((resolve, reject) => {
+    const started = Date.now();
+    const timer = setInterval(() => {
+      if (popup.closed || Date.now() - started > AUTHORIZATION_TIMEOUT_MS) {
+        clearInterval(timer);
+        reject(new Error('authorization window closed before the authorization was completed'));
+        return;
+      }
+      let params: URLSearchParams;
+      try {
+        // reading the location of the popup fails until it is back on the grafana origin
+        params = new URLSearchParams(popup.location.search);
+      } catch {
+        return;
+      }
+      if (!params.get('code') && !params.get('error')) {
+        return;
+      }
+      clearInterval(timer);
+      popup.close();
+      if (params.get('error')) {
+        reject(new Error(`${params.get('error')}. ${params.get('error_description') || ''}`));
+      } else if (params.get('state') !== state) {
+        reject(new Error('invalid authorization state'));
+      } else {
+        resolve(params.get('code') || '');
+      }
+    }, 500);
+  });
+};
+
+export const OAuthConnect = (props: DataSourcePluginOptionsEditorProps<InfinityOptions>) => {
+  const { options, onOptionsChange } = props;
+  const [error, setError] = useState('');
+  const [connecting, setConnecting] = useState(false);
+  const secureJsonData = (options.secureJsonData || {}) as InfinitySecureOptions;
+  const connected = !!(options.secureJsonFields?.oauth2RefreshToken || secureJsonData.oauth2RefreshToken);
+  const onConnect = async () => {
+    setError('');
+    setConnecting(true);
+    try {
+      const resourceUrl = `/api/datasources/uid/${options.uid}/resources/oauth2`;
+      const { url, state } = await getBackendSrv().post(resourceUrl + '/authorize', {}, { showErrorAlert: false });
+      const popup = window.open(url, 'infinity-oauth2-authorization', 'width=600,height=700');
+      if (!popup) {
+        throw new Error('unable to open the authorization window. allow popups for grafana and try again');
+      }
+      const code = await waitForCode(popup, state);
+      const { refresh_token } = await getBackendSrv().post(resourceUrl + '/token', { code, state }, { showErrorAlert: false });
+      onOptionsChange({
+        ...options,
+        secureJsonFields: { ...options.secureJsonFields, oauth2RefreshToken: false },
+        secureJsonData: { ...options.secureJsonData, oauth2RefreshToken: refresh_token },
+      });
+    } catch (ex: any) {
+      setError(ex?.data?.error || ex?.message || 'authorization failed');
+    } finally {
+      setConnecting(false);
+    }
+  };
+  const onDisconnect = () => {
+    onOptionsChange({
+      ...options,
+      secureJsonFields: { ...options.secureJsonFields, oauth2RefreshToken: false },
+      secureJsonData: { ...options.secureJsonData, oauth2RefreshToken: '' },
+    });
+  };
+  return (
+    <>
+      <div className="gf-form">
+        <InlineFormLabel width={10} tooltip="Save the datasource settings before connecting. Once connected, save the datasource again to store the refresh token">
+          Authorization
+        </InlineFormLabel>
+        <Button variant={connected ? 'secondary' : 'primary'} disabled={connecting} onClick={onConnect}>
+          {connecting ? 'Connecting..' : connected ? 'Reconnect' : 'Connect'}
+        </Button>
+        {connected && (
+          <Button variant="secondary" fill="text" onClick={onDisconnect}>
+            Disconnect
+          </Button>
+        )}
+      </div>
+      {error && <Alert title={error} severity="error" />}
+    </>
+  );
+};
@@ -1,15 +1,24 @@
 import { onUpdateDatasourceSecureJsonDataOption, DataSourcePluginOptionsEditorProps, SelectableValue } from '@grafana/data';
 import { InlineFormLabel, Input, LegacyForms, LinkButton, RadioButtonGroup } from '@grafana/ui';
+import { config } from '@grafana/runtime';
 import React from 'react';
 import { SecureFieldsEditor } from './../../components/config/SecureFieldsEditor';
-import type { InfinityOptions, InfinitySecureOptions, OAuth2Props, OAuth2Type } from './../../types';
+import { OAuthConnect } from './OAuthConnect';
+import type { InfinityOptions, InfinitySecureOptions, OAuth2ClientAuthMethod, OAuth2Props, OAuth2Type } from './../../types';
 
 const oAuthTypes: Array<SelectableValue<OAuth2Type>> = [
   { value: 'client_credentials', label: 'Client Credentials' },
   { value: 'jwt', label: 'JWT' },
+  { value: 'authorization_code', label: 'Authorization Code' },
   { value: 'others', label: 'Others' },
 ];
 
+const clientAuthMethods: Array<SelectableValue<OAuth2ClientAuthMethod>> = [
+  { value: 'client_secret', label: 'Client Secret' },
+  { value: 'private_key_jwt', label: 'Private Key JWT' },
+  { value: 'tls_client_auth', label: 'TLS Client Certificate' },
+];
+
 export const OAuthInputsEditor = (props: DataSourcePluginOptionsEditorProps<InfinityOptions>) => {
   const { options, onOptionsChange } = props;
   const { secureJsonFields } = options;
@@ -18,6 +27,14 @@ export const OAuthInputsEditor = (props: DataSourcePluginOptionsEditorProps<Infi
   const onOAuth2PropsChange = <T extends keyof OAuth2Props, V extends OAuth2Props[T]>(key: T, value: V) => {
     onOptionsChange({ ...options, jsonData: { ...options.jsonData, oauth2: { ...oauth2, [key]: value } } });
   };
+  const onOAuth2TypeChange = (oauth2_type: OAuth2Type) => {
+    let redirect_url = oauth2.redirect_url;
+    if (oauth2_type === 'authorization_code' && !redirect_url) {
+      // the authorization server redirects the authorization window back to this page
+      redirect_url = `${window.location.origin}${config.appSubUrl}/connections/datasources/edit/${options.uid}`;
+    }
+    onOptionsChange({ ...options, jsonData: { ...options.jsonData, oauth2: { ...oauth2, oauth2_type, redirect_url } } });
+  };
   const onResetClientSecret = () => {
     onOptionsChange({
       ...options,
@@ -25,6 +42,13 @@ export const OAuthInputsEditor = (props: DataSourcePluginOptionsEditorProps<Infi
       secureJsonData: { ...options.secureJsonData, oauth2ClientSecret: '' },
     });
   };
+  const onResetClientAssertionKey = () => {
+    onOptionsChange({
+      ...options,
+      secureJsonFields: { ...options.secureJsonFields, oauth2ClientAssertionKey: false },
+      secureJsonData: { ...options.secureJsonData, oauth2ClientAssertionKey: '' },
+    });
+  };
   const onResetJWTPrivateKey = () => {
     onOptionsChange({
       ...options,
@@ -38,7 +62,7 @@ export const OAuthInputsEditor = (props: DataSourcePluginOptionsEditorProps<Infi
         <InlineFormLabel width={10} tooltip="This refers to OAuth2 grant type">
           Grant Type
         </InlineFormLabel>
-        <RadioButtonGroup<OAuth2Type> options={oAuthTypes} onChange={(v) => onOAuth2PropsChange('oauth2_type', v)} value={oauth2.oauth2_type || 'client_credentials'}></RadioButtonGroup>
+        <RadioButtonGroup<OAuth2Type> options={oAuthTypes} onChange={(v) => onOAuth2TypeChange(v)} value={oauth2.oauth2_type || 'client_credentials'}></RadioButtonGroup>
       </div>
       {(oauth2.oauth2_type === 'client_credentials' || !oauth2?.oauth2_type) && (
         <>
@@ -47,47 +71,121 @@ export const OAuthInputsEditor = (props: DataSourcePluginOptionsEditorProps<Infi
               width={10}
               tooltip={
                 <>
-                  {`AuthStyleAutoDetect means to auto-detect which authentication style the provider wants by trying both ways and caching the successful way for the future.`}
+                  {`Client Secret authenticates the client with the client secret.`}
                   <br />
                   <br />
-                  {`AuthStyleInParams sends the "client_id" and "client_secret" in the POST body as application/x-www-form-urlencoded parameters.`}
+                  {`Private Key JWT authenticates the client with a JWT assertion signed by the client private key (RFC 7523).`}
                   <br />
                   <br />
-                  {`AuthStyleInHeader sends the client_id and client_password using HTTP Basic Authorization. This is an optional style described in the OAuth2 RFC 6749 section 2.3.1.`}
+                  {`TLS Client Certificate authenticates the client with the client certificate of the TLS settings (RFC 8705).`}
                 </>
               }
               {...{ interactive: true }}
             >
-              Auth Style
+              Client Auth
             </InlineFormLabel>
-            <RadioButtonGroup
-              options={[
-                { value: 0, label: 'Auto' },
-                { value: 1, label: 'In Params' },
-                { value: 2, label: 'In Header' },
-              ]}
-              onChange={(v) => onOAuth2PropsChange('authStyle', v || 0)}
-              value={oauth2.authStyle || 0}
+            <RadioButtonGroup<OAuth2ClientAuthMethod>
+              options={clientAuthMethods}
+              onChange={(v) => onOAuth2PropsChange('client_auth_method', v)}
+              value={oauth2.client_auth_method || 'client_secret'}
             ></RadioButtonGroup>
           </div>
+          {(oauth2.client_auth_method || 'client_secret') === 'client_secret' && (
+            <div className="gf-form">
+              <InlineFormLabel
+                width={10}
+                tooltip={
+                  <>
+                    {`AuthStyleAutoDetect means to auto-detect which authentication style the provider wants by trying both ways and caching the successful way for the future.`}
+                    <br />
+                    <br />
+                    {`AuthStyleInParams sends the "client_id" and "client_secret" in the POST body as application/x-www-form-urlencoded parameters.`}
+                    <br />
+                    <br />
+                    {`AuthStyleInHeader sends the client_id and client_password using HTTP Basic Authorization. This is an optional style described in the OAuth2 RFC 6749 section 2.3.1.`}
+                  </>
+                }
+                {...{ interactive: true }}
+              >
+                Auth Style
+              </InlineFormLabel>
+              <RadioButtonGroup
+                options={[
+                  { value: 0, label: 'Auto' },
+                  { value: 1, label: 'In Params' },
+                  { value: 2, label: 'In Header' },
+                ]}
+                onChange={(v) => onOAuth2PropsChange('authStyle', v || 0)}
+                value={oauth2.authStyle || 0}
+              ></RadioButtonGroup>
+            </div>
+          )}
           <div className="gf-form">
             <InlineFormLabel width={10}>Client ID</InlineFormLabel>
             <Input onChange={(v) => onOAuth2PropsChange('client_id', v.currentTarget.value)} value={oauth2.client_id} width={30} placeholder={'Client ID'} />
           </div>
-          <div className="gf-form">
-            <LegacyForms.SecretFormField
-              labelWidth={10}
-              inputWidth={15}
-              required
-              value={secureJsonData.oauth2ClientSecret || ''}
-              isConfigured={(secureJsonFields && secureJsonFields.oauth2ClientSecret) as boolean}
-              onReset={onResetClientSecret}
-              onChange={onUpdateDatasourceSecureJsonDataOption(props, 'oauth2ClientSecret')}
-              label="Client Secret"
-              aria-label="client secret"
-              placeholder="Client secret"
-            />
-          </div>
+          {(oauth2.client_auth_method || 'client_secret') === 'client_secret' && (
+            <div className="gf-form">
+              <LegacyForms.SecretFormField
+                labelWidth={10}
+                inputWidth={15}
+                required
+                value={secureJsonData.oauth2ClientSecret || ''}
+                isConfigured={(secureJsonFields && secureJsonFields.oauth2ClientSecret) as boolean}
+                onReset={onResetClientSecret}
+                onChange={onUpdateDatasourceSecureJsonDataOption(props, 'oauth2ClientSecret')}
+                label="Client Secret"
+                aria-label="client secret"
+                placeholder="Client secret"
+              />
+            </div>
+          )}
+          {oauth2.client_auth_method === 'private_key_jwt' && (
+            <>
+              <div className="gf-form">
+                <LegacyForms.SecretFormField
+                  labelWidth={10}
+                  inputWidth={15}
+                  required
+                  value={secureJsonData.oauth2ClientAssertionKey || ''}
+                  tooltip="PEM encoded RSA, ECDSA or Ed25519 private key used to sign the client assertions"
+                  isConfigured={(secureJsonFields && secureJsonFields.oauth2ClientAssertionKey) as boolean}
+                  onReset={onResetClientAssertionKey}
+                  onChange={onUpdateDatasourceSecureJsonDataOption(props, 'oauth2ClientAssertionKey')}
+                  label="Private Key"
+                  aria-label="client assertion private key"
+                  placeholder="Private Key"
+                />
+              </div>
+              <div className="gf-form">
+                <InlineFormLabel width={10} tooltip="Optional key identifier sent in the kid header of the client assertions">
+                  Key ID
+                </InlineFormLabel>
+                <Input
+                  onChange={(v) => onOAuth2PropsChange('client_assertion_key_id', v.currentTarget.value)}
+                  value={oauth2.client_assertion_key_id}
+                  width={30}
+                  placeholder={'(optional) key identifier'}
+                />
+              </div>
+              <div className="gf-form">
+                <InlineFormLabel width={10} tooltip="Optional audience of the client assertions. Defaults to the token URL">
+                  Audience
+                </InlineFormLabel>
+                <Input
+                  onChange={(v) => onOAuth2PropsChange('client_assertion_audience', v.currentTarget.value)}
+                  value={oauth2.client_assertion_audience}
+                  width={30}
+                  placeholder={'(optional) defaults to the token URL'}
+                />
+              </div>
+            </>
+          )}
+          {oauth2.client_auth_method === 'tls_client_auth' && (
+            <div className="gf-form">
+              <p>The client certificate and key of the TLS settings are presented to the token endpoint. Enable the TLS client authentication to provide them.</p>
+            </div>
+          )}
           <div className="gf-form">
             <InlineFormLabel width={10}>Token URL</InlineFormLabel>
             <Input onChange={(v) => onOAuth2PropsChange('token_url', v.currentTarget.value)} value={oauth2.token_url} width={30} placeholder={'Token URL'} />
@@ -169,6 +267,67 @@ export const OAuthInputsEditor = (props: DataSourcePluginOptionsEditorProps<Infi
           </div>
         </>
       )}
+      {oauth2.oauth2_type === 'authorization_code' && (
+        <>
+          <div className="gf-form">
+            <InlineFormLabel width={10}>Client ID</InlineFormLabel>
+            <Input onChange={(v) => onOAuth2PropsChange('client_id', v.currentTarget.value)} value={oauth2.client_id} width={30} placeholder={'Client ID'} />
+          </div>
+          <div className="gf-form">
+            <LegacyForms.SecretFormField
+              labelWidth={10}
+              inputWidth={15}
+              value={secureJsonData.oauth2ClientSecret || ''}
+              isConfigured={(secureJsonFields && secureJsonFields.oauth2ClientSecret) as boolean}
+              onReset={onResetClientSecret}
+              onChange={onUpdateDatasourceSecureJsonDataOption(props, 'oauth2ClientSecret')}
+              label="Client Secret"
+              aria-label="client secret"
+              placeholder="Client secret"
+            />
+          </div>
+          <div className="gf-form">
+            <InlineFormLabel width={10} tooltip="URL of the authorization server the user is sent to when connecting the datasource">
+              Auth URL
+            </InlineFormLabel>
+            <Input onChange={(v) => onOAuth2PropsChange('auth_url', v.currentTarget.value)} value={oauth2.auth_url} width={30} placeholder={'Authorization URL'} />
+          </div>
+          <div className="gf-form">
+            <InlineFormLabel width={10}>Token URL</InlineFormLabel>
+            <Input onChange={(v) => onOAuth2PropsChange('token_url', v.currentTarget.value)} value={oauth2.token_url} width={30} placeholder={'Token URL'} />
+          </div>
+          <div className="gf-form">
+            <InlineFormLabel width={10} tooltip="Redirect URL registered with the authorization server. Defaults to this page">
+              Redirect URL
+            </InlineFormLabel>
+            <Input onChange={(v) => onOAuth2PropsChange('redirect_url', v.currentTarget.value)} value={oauth2.redirect_url} width={30} placeholder={'Redirect URL'} />
+          </div>
+          <div className="gf-form">
+            <InlineFormLabel width={10} tooltip="Most providers only issue refresh tokens when the offline access scope, such as offline_access, is requested">
+              Scopes
+            </InlineFormLabel>
+            <Input
+              onChange={(v) => onOAuth2PropsChange('scopes', (v.currentTarget.value || '').split(','))}
+              value={(oauth2.scopes || []).join(',')}
+              width={30}
+              placeholder={'Comma separated values of scopes'}
+            />
+          </div>
+          <div className="gf-form">
+            <SecureFieldsEditor
+              dataSourceConfig={options}
+              onChange={onOptionsChange}
+              title="Endpoint params"
+              hideTile={true}
+              label="Endpoint param"
+              labelWidth={10}
+              secureFieldName="oauth2EndPointParamsName"
+              secureFieldValue="oauth2EndPointParamsValue"
+            />
+          </div>
+          <OAuthConnect {...props} />
+        </>
+      )}
       {oauth2.oauth2_type === 'others' && (
         <div style={{ margin: '15px', marginInline: '45px', textAlign: 'center' }}>
           <p>
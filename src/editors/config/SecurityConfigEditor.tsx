@@ -1,7 +1,9 @@
 import React from 'react';
-import { InlineLabel, RadioButtonGroup } from '@grafana/ui';
+import { InlineLabel, MultiSelect, RadioButtonGroup } from '@grafana/ui';
 import { DataSourcePluginOptionsEditorProps } from '@grafana/data';
-import type { InfinityOptions, UnsecureQueryHandling } from './../../types';
+import type { InfinityOptions, InfinityURLMethod, UnsecureQueryHandling } from './../../types';
+
+const URL_METHODS: InfinityURLMethod[] = ['GET', 'POST', 'PUT', 'PATCH', 'DELETE', 'HEAD'];
 
 type SecurityConfigEditorProps = DataSourcePluginOptionsEditorProps<InfinityOptions>;
 
@@ -11,6 +13,9 @@ export const SecurityConfigEditor = (props: SecurityConfigEditorProps) => {
   const onUnsecureQueryHandlingChange = (unsecuredQueryHandling: UnsecureQueryHandling = 'warn') => {
     onOptionsChange({ ...options, jsonData: { ...jsonData, unsecuredQueryHandling } });
   };
+  const onAllowedMethodsChange = (allowedMethods: InfinityURLMethod[]) => {
+    onOptionsChange({ ...options, jsonData: { ...jsonData, allowedMethods } });
+  };
   return (
     <>
       <div className="gf-form">
@@ -27,6 +32,18 @@ export const SecurityConfigEditor = (props: SecurityConfigEditorProps) => {
           onChange={(e) => onUnsecureQueryHandlingChange(e || 'warn')}
         />
       </div>
+      <div className="gf-form">
+        <InlineLabel width={20} tooltip={'HTTP methods the queries of this datasource can use. Only GET and POST are allowed when no method is selected'}>
+          Allowed methods
+        </InlineLabel>
+        <MultiSelect<InfinityURLMethod>
+          width={60}
+          placeholder="GET, POST"
+          value={jsonData?.allowedMethods || []}
+          options={URL_METHODS.map((method) => ({ value: method, label: method }))}
+          onChange={(e) => onAllowedMethodsChange(e.map((item) => item.value!))}
+        />
+      </div>
     </>
   );
 };
@@ -0,0 +1,107 @@
+import { onUpdateDatasourceSecureJsonDataOption, DataSourcePluginOptionsEditorProps, SelectableValue } from '@grafana/data';
+import { InlineFormLabel, Input, LegacyForms, RadioButtonGroup } from '@grafana/ui';
+import React from 'react';
+import type { InfinityOptions, InfinitySecureOptions, TokenExchangeOptions, TokenExchangeType } from './../../types';
+
+const tokenExchangeTypes: Array<SelectableValue<TokenExchangeType | ''>> = [
+  { value: '', label: 'None' },
+  { value: 'rfc8693', label: 'Token exchange (RFC 8693)' },
+  { value: 'azureOnBehalfOf', label: 'Azure on-behalf-of' },
+];
+
+const subjectTokens: Array<SelectableValue<'access_token' | 'id_token'>> = [
+  { value: 'access_token', label: 'Access token' },
+  { value: 'id_token', label: 'ID token' },
+];
+
+export const TokenExchangeEditor = (props: DataSourcePluginOptionsEditorProps<InfinityOptions>) => {
+  const { options, onOptionsChange } = props;
+  const { secureJsonFields } = options;
+  const secureJsonData = (options.secureJsonData || {}) as InfinitySecureOptions;
+  const tokenExchange: TokenExchangeOptions = options.jsonData?.tokenExchange || {};
+  const onTokenExchangeChange = <T extends keyof TokenExchangeOptions, V extends TokenExchangeOptions[T]>(key: T, value: V) => {
+    onOptionsChange({ ...options, jsonData: { ...options.jsonData, tokenExchange: { ...tokenExchange, [key]: value } } });
+  };
+  const onResetClientSecret = () => {
+    onOptionsChange({
+      ...options,
+      secureJsonFields: { ...options.secureJsonFields, tokenExchangeClientSecret: false },
+      secureJsonData: { ...options.secureJsonData, tokenExchangeClientSecret: '' },
+    });
+  };
+  return (
+    <>
+      <div className="gf-form">
+        <InlineFormLabel width={10} tooltip="Exchange the token of the signed in user for a token issued for this API before forwarding it">
+          Token exchange
+        </InlineFormLabel>
+        <RadioButtonGroup<TokenExchangeType | ''>
+          options={tokenExchangeTypes}
+          value={tokenExchange.type || ''}
+          onChange={(v) => onTokenExchangeChange('type', v || undefined)}
+        ></RadioButtonGroup>
+      </div>
+      {tokenExchange.type && (
+        <>
+          <div className="gf-form">
+            <InlineFormLabel width={10}>Token URL</InlineFormLabel>
+            <Input onChange={(v) => onTokenExchangeChange('tokenUrl', v.currentTarget.value)} value={tokenExchange.tokenUrl} width={30} placeholder={'Token URL'} />
+          </div>
+          <div className="gf-form">
+            <InlineFormLabel width={10}>Client ID</InlineFormLabel>
+            <Input onChange={(v) => onTokenExchangeChange('clientId', v.currentTarget.value)} value={tokenExchange.clientId} width={30} placeholder={'Client ID'} />
+          </div>
+          <div className="gf-form">
+            <LegacyForms.SecretFormField
+              labelWidth={10}
+              inputWidth={15}
+              required={tokenExchange.type === 'azureOnBehalfOf'}
+              value={secureJsonData.tokenExchangeClientSecret || ''}
+              isConfigured={(secureJsonFields && secureJsonFields.tokenExchangeClientSecret) as boolean}
+              onReset={onResetClientSecret}
+              onChange={onUpdateDatasourceSecureJsonDataOption(props, 'tokenExchangeClientSecret')}
+              label="Client Secret"
+              aria-label="token exchange client secret"
+              placeholder="Client secret"
+            />
+          </div>
+          {tokenExchange.type === 'rfc8693' && (
+            <>
+              <div className="gf-form">
+                <InlineFormLabel width={10} tooltip="The forwarded token of the user to exchange">
+                  Subject token
+                </InlineFormLabel>
+                <RadioButtonGroup<'access_token' | 'id_token'>
+                  options={subjectTokens}
+                  value={tokenExchange.subjectToken || 'access_token'}
+                  onChange={(v) => onTokenExchangeChange('subjectToken', v)}
+                ></RadioButtonGroup>
+              </div>
+              <div className="gf-form">
+                <InlineFormLabel width={10} tooltip="Optional logical name of the API the exchanged token is issued for">
+                  Audience
+                </InlineFormLabel>
+                <Input onChange={(v) => onTokenExchangeChange('audience', v.currentTarget.value)} value={tokenExchange.audience} width={30} placeholder={'Audience'} />
+              </div>
+              <div className="gf-form">
+                <InlineFormLabel width={10} tooltip="Optional URI of the API the exchanged token is issued for">
+                  Resource
+                </InlineFormLabel>
+                <Input onChange={(v) => onTokenExchangeChange('resource', v.currentTarget.value)} value={tokenExchange.resource} width={30} placeholder={'Resource'} />
+              </div>
+            </>
+          )}
+          <div className="gf-form">
+            <InlineFormLabel width={10}>Scopes</InlineFormLabel>
+            <Input
+              onChange={(v) => onTokenExchangeChange('scopes', (v.currentTarget.value || '').split(','))}
+              value={(tokenExchange.scopes || []).join(',')}
+              width={30}
+              placeholder={tokenExchange.type === 'azureOnBehalfOf' ? 'api://my-api/.default' : 'Comma separated values of scopes'}
+            />
+          </div>
+        </>
+      )}
+    </>
+  );
+};
@@ -11,7 +11,7 @@ import { ParseTypeEditor } from './components/ParserType';
 import { GoogleSheetsEditor } from './components/GoogleSheets';
 import { URL, Method } from './query.url';
 import { Datasource } from './../../datasource';
-import { isDataQuery, isInfinityQueryWithUrlSource } from './../../app/utils';
+import { hasBody, isDataQuery, isInfinityQueryWithUrlSource } from './../../app/utils';
 import type { EditorMode, InfinityQuery, InfinityQueryType, InfinityQueryWithURLSource } from '../../types';
 
 export const BasicOptions = (props: {
@@ -74,7 +74,7 @@ export const BasicOptions = (props: {
               e.preventDefault();
             }}
           >
-            {query.url_options?.method === 'GET' ? 'Headers, Request params' : 'Headers, Body, Request params'}
+            {hasBody(query.url_options?.method) ? 'Headers, Body, Request params' : 'Headers, Request params'}
           </Button>
         </EditorRow>
       )}
@@ -1,10 +1,10 @@
 import React from 'react';
-import { InlineLabel, Input, Select } from '@grafana/ui';
+import { InlineLabel, InlineSwitch, Input, Select } from '@grafana/ui';
 import { SelectableValue } from '@grafana/data';
 import { EditorField } from './../../components/extended/EditorField';
 import { EditorRow } from './../../components/extended/EditorRow';
 import { Stack } from './../../components/extended/Stack';
-import type { InfinityQuery, PaginationParamType, PaginationType } from './../../types';
+import type { InfinityQuery, PaginationCursorSource, PaginationParamType, PaginationType } from './../../types';
 
 const paginationTypes: Array<SelectableValue<PaginationType>> = [
   { value: 'none', label: 'None' },
@@ -12,6 +12,8 @@ const paginationTypes: Array<SelectableValue<PaginationType>> = [
   { value: 'page', label: 'Page number' },
   { value: 'cursor', label: 'Cursor' },
   { value: 'list', label: 'List of values' },
+  { value: 'link', label: 'Link header' },
+  { value: 'next_url', label: 'Next URL' },
 ];
 
 const paginationParamTypes: Array<SelectableValue<PaginationParamType>> = [
@@ -22,6 +24,13 @@ const paginationParamTypes: Array<SelectableValue<PaginationParamType>> = [
   { value: 'replace', label: 'Replace URL' },
 ];
 
+const paginationCursorSources: Array<SelectableValue<PaginationCursorSource>> = [
+  { value: 'body', label: 'JSON body', description: 'selector applied to the JSON response' },
+  { value: 'header', label: 'Response header', description: 'name of the response header holding the cursor' },
+  { value: 'xml', label: 'XML body', description: 'selector applied to the XML response, same as the XML root selector' },
+  { value: 'regex', label: 'Regex', description: 'regular expression applied to the raw response. the first capture group is used when present' },
+];
+
 type PaginationEditorProps = {
   query: InfinityQuery;
   onChange: (query: InfinityQuery) => void;
@@ -38,18 +47,22 @@ export const PaginationEditor = (props: PaginationEditorProps) => {
             <Select<PaginationType> width={30} value={query.pagination_mode || 'none'} options={paginationTypes} onChange={(e) => onChange({ ...query, pagination_mode: e.value || 'none' })} />
           </EditorField>
           {query.pagination_mode && query.pagination_mode !== 'none' && (
-            <EditorField label="Max pages" tooltip={'maximum of 5 pages. minimum of 1 page. Default 1'}>
+            <EditorField label="Max pages" tooltip={'minimum of 1 page. Default 1. The maximum is set by the pagination max pages of the datasource config, which defaults to 5 pages'}>
               <Input
                 type={'number'}
                 min={1}
-                max={5}
                 width={30}
                 value={query.pagination_max_pages}
                 onChange={(e) => onChange({ ...query, pagination_max_pages: e.currentTarget.valueAsNumber || 1 })}
-                placeholder="min:1, max:5"
+                placeholder="min:1"
               />
             </EditorField>
           )}
+          {query.pagination_mode && query.pagination_mode !== 'none' && (
+            <EditorField label="Partial results" tooltip={'return the successful pages when some pages fail. the failed pages are shown as warnings. alert rules always fail when a page fails'}>
+              <InlineSwitch value={query.partial_results || false} onChange={(e) => onChange({ ...query, partial_results: e.currentTarget.checked })} />
+            </EditorField>
+          )}
         </Stack>
         {(query.pagination_mode === 'offset' || query.pagination_mode === 'page' || query.pagination_mode === 'cursor') && (
           <>
@@ -142,6 +155,34 @@ export const PaginationEditor = (props: PaginationEditorProps) => {
                   </Stack>
                 </EditorField>
               )}
+              {(query.pagination_mode === 'offset' || query.pagination_mode === 'page') && (
+                <EditorField label="Stop conditions" tooltip="the pagination always stops at an empty page. pages after the last page are not requested">
+                  <Stack>
+                    <InlineLabel width={20} tooltip="stop when a page has fewer rows than the page size">
+                      Stop on short page
+                    </InlineLabel>
+                    <InlineSwitch value={query.pagination_stop_on_short_page || false} onChange={(e) => onChange({ ...query, pagination_stop_on_short_page: e.currentTarget.checked })} />
+                    <InlineLabel width={20} tooltip="selector to extract the total number of rows. stop when the pages reach the total">
+                      Total count path
+                    </InlineLabel>
+                    <Input
+                      width={20}
+                      value={query.pagination_total_count_extraction_path}
+                      onChange={(e) => onChange({ ...query, pagination_total_count_extraction_path: e.currentTarget.value || '' })}
+                      placeholder="total"
+                    ></Input>
+                    <InlineLabel width={20} tooltip="selector to extract a boolean telling whether there are more pages. stop when it is false">
+                      Has more path
+                    </InlineLabel>
+                    <Input
+                      width={20}
+                      value={query.pagination_has_more_extraction_path}
+                      onChange={(e) => onChange({ ...query, pagination_has_more_extraction_path: e.currentTarget.value || '' })}
+                      placeholder="has_more"
+                    ></Input>
+                  </Stack>
+                </EditorField>
+              )}
               {query.pagination_mode === 'cursor' && (
                 <EditorField label="Cursor field">
                   <Stack>
@@ -159,7 +200,16 @@ export const PaginationEditor = (props: PaginationEditorProps) => {
                       value={query.pagination_param_cursor_field_type || 'query'}
                       onChange={(e) => onChange({ ...query, pagination_param_cursor_field_type: e.value || 'query' })}
                     />
-                    <InlineLabel width={20} tooltip="selector to extract the cursor">
+                    <InlineLabel width={20} tooltip="where to extract the cursor from">
+                      Extract from
+                    </InlineLabel>
+                    <Select<PaginationCursorSource>
+                      width={20}
+                      options={paginationCursorSources}
+                      value={query.pagination_param_cursor_extraction_source || 'body'}
+                      onChange={(e) => onChange({ ...query, pagination_param_cursor_extraction_source: e.value || 'body' })}
+                    />
+                    <InlineLabel width={20} tooltip="selector, header name or regex to extract the cursor">
                       Extraction path
                     </InlineLabel>
                     <Input
@@ -207,6 +257,25 @@ export const PaginationEditor = (props: PaginationEditorProps) => {
             </Stack>
           </>
         )}
+        {query.pagination_mode === 'next_url' && (
+          <>
+            <Stack gap={1} wrap={false} direction="column">
+              <EditorField label="Next URL" invalid={!(query.pagination_param_next_url_extraction_path || '').trim()}>
+                <Stack>
+                  <InlineLabel width={20} tooltip="selector to extract the url of the next page. relative urls are resolved against the current page url">
+                    Extraction path
+                  </InlineLabel>
+                  <Input
+                    width={40}
+                    value={query.pagination_param_next_url_extraction_path}
+                    onChange={(e) => onChange({ ...query, pagination_param_next_url_extraction_path: e.currentTarget.value || '' })}
+                    placeholder="_links.next.href"
+                  ></Input>
+                </Stack>
+              </EditorField>
+            </Stack>
+          </>
+        )}
       </Stack>
     </EditorRow>
   );
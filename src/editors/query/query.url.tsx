@@ -3,9 +3,9 @@ import { InlineFormLabel, CodeEditor, Select, Input, RadioButtonGroup, Icon } fr
 import { EditorRow } from './../../components/extended/EditorRow';
 import { EditorField } from './../../components/extended/EditorField';
 import { Stack } from './../../components/extended/Stack';
-import { isDataQuery } from './../../app/utils';
+import { hasBody, isDataQuery } from './../../app/utils';
 import { KeyValueEditor } from './../../components/KeyValuePairEditor';
-import type { InfinityQuery, InfinityQueryType, InfinityQueryWithURLSource, InfinityURLOptions, QueryBodyContentType, QueryBodyType } from './../../types';
+import type { InfinityQuery, InfinityQueryType, InfinityQueryWithURLSource, InfinityURLMethod, InfinityURLOptions, QueryBodyContentType, QueryBodyType } from './../../types';
 import type { SelectableValue } from '@grafana/data';
 import { usePrevious } from 'react-use';
 
@@ -30,11 +30,15 @@ export const Method = ({ query, onChange, onRunQuery }: { query: InfinityQuery;
   if (query.source === 'inline' || query.source === 'azure-blob') {
     return <></>;
   }
-  const URL_METHODS: SelectableValue[] = [
+  const URL_METHODS: Array<SelectableValue<InfinityURLMethod>> = [
     { label: 'GET', value: 'GET' },
     { label: 'POST', value: 'POST' },
+    { label: 'PUT', value: 'PUT' },
+    { label: 'PATCH', value: 'PATCH' },
+    { label: 'DELETE', value: 'DELETE' },
+    { label: 'HEAD', value: 'HEAD', description: 'Returns the status code and headers of the response' },
   ];
-  const onMethodChange = (method: 'GET' | 'POST') => {
+  const onMethodChange = (method: InfinityURLMethod) => {
     if (query.source === 'url') {
       onChange({
         ...query,
@@ -56,7 +60,7 @@ export const Method = ({ query, onChange, onRunQuery }: { query: InfinityQuery;
         value={URL_METHODS.find((e) => e.value === (query.url_options.method || 'GET'))}
         defaultValue={URL_METHODS.find((e) => e.value === 'GET')}
         options={URL_METHODS}
-        onChange={(e) => onMethodChange(e.value)}
+        onChange={(e) => onMethodChange(e.value || 'GET')}
       ></Select>
     </EditorField>
   );
@@ -162,7 +166,7 @@ const Body = ({ query, onChange, onRunQuery }: { query: InfinityQuery; onChange:
   const onURLOptionsChange = <K extends keyof InfinityURLOptions, V extends InfinityURLOptions[K]>(key: K, value: V) => {
     onChange({ ...query, url_options: { ...query.url_options, [key]: value } });
   };
-  return query.url_options?.method === 'POST' ? (
+  return hasBody(query.url_options?.method) ? (
     <>
       <Stack direction="column">
         <EditorField label="Body Type">
@@ -1,4 +1,4 @@
-import { isDataQuery } from './app/utils';
+import { hasBody, isDataQuery } from './app/utils';
 import type { InfinityQuery } from './types';
 
 /**
@@ -13,7 +13,7 @@ import type { InfinityQuery } from './types';
  */
 export const migrateQuery = (query: InfinityQuery): InfinityQuery => {
   let newQuery: InfinityQuery = { ...query };
-  if (isDataQuery(newQuery) && newQuery.source === 'url' && newQuery.url_options.method === 'POST') {
+  if (isDataQuery(newQuery) && newQuery.source === 'url' && hasBody(newQuery.url_options.method)) {
     if (!newQuery.url_options.body_type) {
       if (newQuery.type === 'graphql') {
         newQuery = {
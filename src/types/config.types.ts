@@ -20,7 +20,7 @@
  * - InfinitySecureOptions: added azureManagedIdentity.
  */
 
-import type { InfinityQuery } from './query.types';
+import type { InfinityQuery, InfinityRetryOptions, InfinityURLMethod } from './query.types';
 import type { DataSourceInstanceSettings, DataSourceJsonData } from '@grafana/data';
 
 //#region Config
@@ -31,9 +31,10 @@ export interface GlobalInfinityQuery {
 }
 
 // Added azureManagedIdentity
-export type AuthType = 'none' | 'basicAuth' | 'apiKey' | 'bearerToken' | 'oauthPassThru' | 'digestAuth' | 'aws' | 'azureBlob' | 'oauth2'  | 'azureManagedIdentity';
-export type OAuth2Type = 'client_credentials' | 'jwt' | 'others';
+export type AuthType = 'none' | 'basicAuth' | 'apiKey' | 'bearerToken' | 'oauthPassThru' | 'digestAuth' | 'aws' | 'azureBlob' | 'oauth2'  | 'azureManagedIdentity' | 'azureWorkloadIdentity' | 'azureServicePrincipal' | 'loginToken';
+export type OAuth2Type = 'client_credentials' | 'jwt' | 'authorization_code' | 'others';
 export type APIKeyType = 'header' | 'query';
+export type OAuth2ClientAuthMethod = 'client_secret' | 'private_key_jwt' | 'tls_client_auth';
 export type OAuth2Props = {
   oauth2_type?: OAuth2Type;
   client_id?: string;
@@ -41,13 +42,65 @@ export type OAuth2Props = {
   private_key_id?: string;
   subject?: string;
   token_url?: string;
+  auth_url?: string;
+  redirect_url?: string;
   scopes?: string[];
   authStyle?: number;
+  client_auth_method?: OAuth2ClientAuthMethod;
+  client_assertion_key_id?: string;
+  client_assertion_audience?: string;
 };
+export type AWSAuthType = 'keys' | 'default' | 'webIdentity';
 export type AWSAuthProps = {
-  authType?: 'keys';
+  authType?: AWSAuthType;
   region?: string;
   service?: string;
+  assumeRoleArn?: string;
+  externalId?: string;
+  webIdentityRoleArn?: string;
+  webIdentityTokenFile?: string;
+};
+export type AzureManagedIdentityProps = {
+  resource?: string;
+  clientId?: string;
+  objectId?: string;
+  tenantId?: string;
+};
+export type AzureCredentialProps = {
+  tenantId?: string;
+  clientId?: string;
+  resource?: string;
+};
+export type InfinityCacheOptions = {
+  ttlSeconds?: number;
+  maxSizeMb?: number;
+};
+export type TokenExchangeType = 'rfc8693' | 'azureOnBehalfOf';
+export type TokenExchangeOptions = {
+  type?: TokenExchangeType;
+  tokenUrl?: string;
+  clientId?: string;
+  subjectToken?: 'access_token' | 'id_token';
+  audience?: string;
+  resource?: string;
+  scopes?: string[];
+};
+export type LoginTokenOptions = {
+  url?: string;
+  method?: 'GET' | 'POST' | 'PUT';
+  body?: string;
+  contentType?: string;
+  tokenSelector?: string;
+  expirySelector?: string;
+  ttlSeconds?: number;
+  headerName?: string;
+  headerTemplate?: string;
+};
+export type InfinityRateLimit = {
+  host: string;
+  requestsPerSecond: number;
+  burst?: number;
+  maxWaitMs?: number;
 };
 export type InfinityReferenceData = { name: string; data: string };
 export type ProxyType = 'none' | 'env' | 'url';
@@ -67,15 +120,28 @@ export interface InfinityOptions extends DataSourceJsonData {
   proxy_type?: ProxyType;
   proxy_url?: string;
   oauthPassThru?: boolean;
+  tokenExchange?: TokenExchangeOptions;
+  loginToken?: LoginTokenOptions;
   allowedHosts?: string[];
+  allowedMethods?: InfinityURLMethod[];
+  blockPrivateNetworks?: boolean;
+  privateNetworkExceptions?: string[];
   refData?: InfinityReferenceData[];
   customHealthCheckEnabled?: boolean;
   customHealthCheckUrl?: string;
   azureBlobAccountUrl?: string;
   azureBlobAccountName?: string;
+  azureManagedIdentity?: AzureManagedIdentityProps;
+  azureCredentials?: AzureCredentialProps;
   unsecuredQueryHandling?: UnsecureQueryHandling;
   enableSecureSocksProxy?: boolean;
   pathEncodedUrlsEnabled?: boolean;
+  retry?: InfinityRetryOptions;
+  rateLimits?: InfinityRateLimit[];
+  cache?: InfinityCacheOptions;
+  maxConcurrentQueries?: number;
+  paginationMaxPages?: number;
+  paginationConcurrency?: number;
 }
 
 export interface InfinitySecureOptions {
@@ -87,10 +153,17 @@ export interface InfinitySecureOptions {
   bearerToken?: string;
   awsAccessKey?: string;
   awsSecretKey?: string;
+  awsSessionToken?: string;
   oauth2ClientSecret?: string;
   oauth2JWTPrivateKey?: string;
+  oauth2RefreshToken?: string;
+  oauth2ClientAssertionKey?: string;
   azureBlobAccountKey?: string;
   azureManagedIdentity?: string; // Added to support Azure Manage Identity
+  azureClientSecret?: string;
+  azureClientCertificate?: string;
+  azureClientCertificatePassword?: string;
+  tokenExchangeClientSecret?: string;
 }
 
 export interface SecureField {
@@ -11,8 +11,9 @@ export type QueryBodyContentType = 'text/plain' | 'application/json' | 'applicat
 export type InfinityQueryBase<T extends InfinityQueryType> = { type: T } & DataQuery;
 export type InfinityQueryWithSource<S extends InfinityQuerySources> = { source: S } & DataQuery;
 export type InfinityKV = { key: string; value: string };
+export type InfinityURLMethod = 'GET' | 'POST' | 'PUT' | 'PATCH' | 'DELETE' | 'HEAD';
 export type InfinityURLOptions = {
-  method: 'GET' | 'POST';
+  method: InfinityURLMethod;
   params?: InfinityKV[];
   headers?: InfinityKV[];
   data?: string;
@@ -22,6 +23,13 @@ export type InfinityURLOptions = {
   body_graphql_query?: string;
   body_graphql_variables?: string;
 };
+export type InfinityRetryOptions = {
+  maxAttempts?: number;
+  initialDelayMs?: number;
+  maxDelayMs?: number;
+  statusCodes?: number[];
+  methods?: string[];
+};
 export type InfinityQueryWithReferenceSource<T extends InfinityQueryType> = {
   referenceName: string;
 } & InfinityQueryWithSource<'reference'> &
@@ -29,11 +37,15 @@ export type InfinityQueryWithReferenceSource<T extends InfinityQueryType> = {
 export type InfinityQueryWithURLSource<T extends InfinityQueryType> = {
   url: string;
   url_options: InfinityURLOptions;
+  retry?: InfinityRetryOptions;
+  cache_ttl_seconds?: number;
+  partial_results?: boolean;
 } & InfinityQueryWithSource<'url'> &
   InfinityQueryBase<T>;
 export type InfinityQueryWithAzureBlobSource<T extends InfinityQueryType> = {
   azContainerName: string;
   azBlobName: string;
+  partial_results?: boolean;
 } & InfinityQueryWithSource<'azure-blob'> &
   InfinityQueryBase<T>;
 export type InfinityQueryWithInlineSource<T extends InfinityQueryType> = {
@@ -103,10 +115,16 @@ export type InfinityUQLQuery = { uql: string; format: InfinityQueryFormat } & In
 export type InfinityGROQQuerySource = InfinityQueryWithURLSource<'groq'> | InfinityQueryWithInlineSource<'groq'>;
 export type InfinityGROQQuery = { groq: string; format: InfinityQueryFormat } & InfinityGROQQuerySource & InfinityQueryBase<'groq'>;
 export type InfinityGSheetsQuery = { spreadsheet: string; sheetName?: string; range: string; columns: InfinityColumn[] } & InfinityQueryBase<'google-sheets'>;
-export type PaginationType = 'none' | 'offset' | 'page' | 'cursor' | 'list';
+export type PaginationType = 'none' | 'offset' | 'page' | 'cursor' | 'list' | 'link' | 'next_url';
+export type PaginationCursorSource = 'body' | 'header' | 'xml' | 'regex';
 export type PaginationParamType = 'query' | 'header' | 'body_data' | 'body_json' | 'replace';
 export type PaginationBase<T extends PaginationType> = { pagination_mode?: T; pagination_max_pages?: number };
 export type PaginationNone = {} & PaginationBase<'none'>;
+export type PaginationStopConditions = {
+  pagination_stop_on_short_page?: boolean;
+  pagination_total_count_extraction_path?: string;
+  pagination_has_more_extraction_path?: string;
+};
 export type PaginationOffset = {
   pagination_param_size_field_name?: string;
   pagination_param_size_field_type?: PaginationParamType;
@@ -114,7 +132,7 @@ export type PaginationOffset = {
   pagination_param_offset_field_name?: string;
   pagination_param_offset_field_type?: PaginationParamType;
   pagination_param_offset_value?: number;
-} & PaginationBase<'offset'>;
+} & PaginationStopConditions & PaginationBase<'offset'>;
 export type PaginationPage = {
   pagination_param_size_field_name?: string;
   pagination_param_size_field_type?: PaginationParamType;
@@ -122,7 +140,7 @@ export type PaginationPage = {
   pagination_param_page_field_name?: string;
   pagination_param_page_field_type?: PaginationParamType;
   pagination_param_page_value?: number;
-} & PaginationBase<'page'>;
+} & PaginationStopConditions & PaginationBase<'page'>;
 export type PaginationCursor = {
   pagination_param_size_field_name?: string;
   pagination_param_size_field_type?: PaginationParamType;
@@ -130,13 +148,18 @@ export type PaginationCursor = {
   pagination_param_cursor_field_name?: string;
   pagination_param_cursor_field_type?: PaginationParamType;
   pagination_param_cursor_extraction_path?: string;
+  pagination_param_cursor_extraction_source?: PaginationCursorSource;
 } & PaginationBase<'cursor'>;
 export type PaginationList = {
   pagination_param_list_field_name?: string;
   pagination_param_list_field_type?: PaginationParamType;
   pagination_param_list_value?: string;
 } & PaginationBase<'list'>;
-export type Pagination = PaginationNone | PaginationOffset | PaginationPage | PaginationCursor | PaginationList;
+export type PaginationLink = {} & PaginationBase<'link'>;
+export type PaginationNextURL = {
+  pagination_param_next_url_extraction_path?: string;
+} & PaginationBase<'next_url'>;
+export type Pagination = PaginationNone | PaginationOffset | PaginationPage | PaginationCursor | PaginationList | PaginationLink | PaginationNextURL;
 export type Transformation = 'limit' | 'filterExpression' | 'summarize' | 'computedColumn';
 export type TransformationItem = {
   type: Transformation;